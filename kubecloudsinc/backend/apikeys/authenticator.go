@@ -0,0 +1,155 @@
+package apikeys
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrInvalidKey is returned for unknown, expired and revoked keys alike.
+var ErrInvalidKey = errors.New("API key is invalid, expired or revoked")
+
+// ErrAddressNotAllowed is returned when a key is used from outside its allowed IPs.
+var ErrAddressNotAllowed = errors.New("API key may not be used from this address")
+
+// RateLimitError is returned when a key made too many requests.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("API key rate limit exceeded, retry in %d seconds", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// touchInterval is the least time between two records of a key's last use, so busy
+// keys do not write to the database on every request.
+const touchInterval = time.Minute
+
+// bucket is the token bucket of one key: it holds up to the key's rate limit and
+// refills at that many tokens a minute.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Authenticator checks API keys against Store. Rate limits are counted in memory, so
+// each instance of the service allows a key its full rate.
+type Authenticator struct {
+	Store Store
+
+	mu      sync.Mutex
+	buckets map[int]*bucket
+	touched map[int]time.Time
+}
+
+func NewAuthenticator(store Store) *Authenticator {
+	return &Authenticator{Store: store, buckets: make(map[int]*bucket), touched: make(map[int]time.Time)}
+}
+
+// Authenticate returns the key when it is known, neither expired nor revoked, allowed
+// from ip and within its rate limit, and records its use.
+func (a *Authenticator) Authenticate(ctx context.Context, key, ip string) (*schema.APIKey, error) {
+	record, err := a.Store.GetByHash(ctx, HashKey(key))
+	if errors.Is(err, dbs.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if record.RevokedAt != nil || (record.ExpiresAt != nil && !now.Before(*record.ExpiresAt)) {
+		return nil, ErrInvalidKey
+	}
+	if !IPAllowed(record.AllowedIps, ip) {
+		log.Printf("API key %s used from %s, which it is not allowed from", record.Name, ip)
+		return nil, ErrAddressNotAllowed
+	}
+	if retryAfter, ok := a.take(record.KeyId, record.RateLimit, now); !ok {
+		return nil, &RateLimitError{RetryAfter: retryAfter}
+	}
+	a.touch(ctx, record.KeyId, now, ip)
+	return record, nil
+}
+
+// take removes a token from the bucket of a key, or says how long until one is there.
+func (a *Authenticator) take(keyId, perMinute int, now time.Time) (time.Duration, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rate := float64(perMinute) / time.Minute.Seconds()
+	b, ok := a.buckets[keyId]
+	if !ok {
+		b = &bucket{tokens: float64(perMinute), updated: now}
+		a.buckets[keyId] = b
+	}
+	b.tokens = math.Min(float64(perMinute), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// touch records the use of a key at most once per touchInterval. A failure is only
+// logged; it must not fail the request.
+func (a *Authenticator) touch(ctx context.Context, keyId int, now time.Time, ip string) {
+	a.mu.Lock()
+	due := now.Sub(a.touched[keyId]) >= touchInterval
+	if due {
+		a.touched[keyId] = now
+	}
+	a.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := a.Store.Touch(ctx, keyId, now, ip); err != nil {
+		log.Printf("Error recording the use of API key %d: %v", keyId, err)
+	}
+}
+
+// IPAllowed reports whether ip is in one of the CIDR ranges of allowed, or whether
+// allowed is empty.
+func IPAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, cidr := range allowed {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeIPs turns addresses and CIDR ranges into CIDR ranges, rejecting anything
+// else.
+func NormalizeIPs(entries []string) ([]string, error) {
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if addr := net.ParseIP(entry); addr != nil {
+			bits := 128
+			if addr.To4() != nil {
+				addr, bits = addr.To4(), 32
+			}
+			normalized = append(normalized, (&net.IPNet{IP: addr, Mask: net.CIDRMask(bits, bits)}).String())
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+		}
+		normalized = append(normalized, network.String())
+	}
+	return normalized, nil
+}
@@ -0,0 +1,88 @@
+package apikeys
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// createKey stores a key built by configure and returns it in clear.
+func createKey(t *testing.T, store *MemoryStore, name string, configure func(*schema.APIKey)) string {
+	t.Helper()
+	key, hash, prefix, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := schema.APIKey{Name: name, Prefix: prefix, KeyHash: hash, Role: "viewer", Scopes: []string{"employee:read"}, RateLimit: DefaultRateLimit}
+	if configure != nil {
+		configure(&record)
+	}
+	if _, err := store.Create(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	a := NewAuthenticator(store)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+
+	valid := createKey(t, store, "valid", func(k *schema.APIKey) { k.ExpiresAt = &future })
+	expired := createKey(t, store, "expired", func(k *schema.APIKey) { k.ExpiresAt = &past })
+	revoked := createKey(t, store, "revoked", nil)
+	records, _ := store.List(ctx)
+	for _, record := range records {
+		if record.Name == "revoked" {
+			if err := store.Revoke(ctx, record.KeyId); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	restricted := createKey(t, store, "restricted", func(k *schema.APIKey) { k.AllowedIps = []string{"10.0.0.0/8"} })
+
+	tests := []struct {
+		name    string
+		key     string
+		ip      string
+		wantErr error
+	}{
+		{"valid key", valid, "203.0.113.7", nil},
+		{"expired key", expired, "203.0.113.7", ErrInvalidKey},
+		{"revoked key", revoked, "203.0.113.7", ErrInvalidKey},
+		{"unknown key", "kck_unknown", "203.0.113.7", ErrInvalidKey},
+		{"allowed address", restricted, "10.1.2.3", nil},
+		{"refused address", restricted, "203.0.113.7", ErrAddressNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := a.Authenticate(ctx, tt.key, tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate returned %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && record.Role != "viewer" {
+				t.Fatalf("Authenticate returned role %q, want viewer", record.Role)
+			}
+		})
+	}
+}
+
+func TestAuthenticateEnforcesTheRateLimit(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	a := NewAuthenticator(store)
+	key := createKey(t, store, "busy", func(k *schema.APIKey) { k.RateLimit = 2 })
+
+	for i := 0; i < 2; i++ {
+		if _, err := a.Authenticate(ctx, key, "203.0.113.7"); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	var rateErr *RateLimitError
+	if _, err := a.Authenticate(ctx, key, "203.0.113.7"); !errors.As(err, &rateErr) || rateErr.RetryAfter <= 0 {
+		t.Fatalf("third request returned %v, want a RateLimitError with a Retry-After", err)
+	}
+}
@@ -0,0 +1,38 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// keyPrefix starts every key, so leaked keys are easy to recognise in logs and by
+// secret scanners.
+const keyPrefix = "kck_"
+
+// shownPrefixLength is how much of a key is kept in clear to tell keys apart.
+const shownPrefixLength = 12
+
+// DefaultRateLimit is the requests per minute of keys created without a rateLimit.
+const DefaultRateLimit = 600
+
+// MaxRateLimit is the highest rateLimit a key can have.
+const MaxRateLimit = 100000
+
+// NewKey returns a new key of 32 random bytes, its hash and the prefix shown to admins.
+func NewKey() (key, hash, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashKey(key), key[:shownPrefixLength], nil
+}
+
+// HashKey returns the SHA-256 of a key in hex, under which it is stored. Keys are
+// random, so a plain hash is enough to make a stolen table useless.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,93 @@
+package apikeys
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps API keys in memory, next to a users.MemoryStore for local
+// development. Its contents are lost on restart.
+type MemoryStore struct {
+	mu     sync.Mutex
+	keys   map[int]schema.APIKey
+	nextId int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[int]schema.APIKey), nextId: 1}
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]schema.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]schema.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, keyId int) (*schema.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[keyId]
+	if !ok {
+		return nil, dbs.ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+func (s *MemoryStore) GetByHash(ctx context.Context, keyHash string) (*schema.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.keys {
+		if key.KeyHash == keyHash {
+			return &key, nil
+		}
+	}
+	return nil, dbs.ErrAPIKeyNotFound
+}
+
+func (s *MemoryStore) Create(ctx context.Context, key schema.APIKey) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.keys {
+		if other.Name == key.Name || other.KeyHash == key.KeyHash {
+			return 0, dbs.ErrAPIKeyExists
+		}
+	}
+	key.KeyId = s.nextId
+	s.keys[key.KeyId] = key
+	s.nextId++
+	return key.KeyId, nil
+}
+
+func (s *MemoryStore) Revoke(ctx context.Context, keyId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[keyId]
+	if !ok || key.RevokedAt != nil {
+		return dbs.ErrAPIKeyNotFound
+	}
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	s.keys[keyId] = key
+	return nil
+}
+
+func (s *MemoryStore) Touch(ctx context.Context, keyId int, usedAt time.Time, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[keyId]
+	if !ok {
+		return dbs.ErrAPIKeyNotFound
+	}
+	key.LastUsedAt, key.LastUsedIp = &usedAt, &ip
+	s.keys[keyId] = key
+	return nil
+}
@@ -0,0 +1,60 @@
+// Package apikeys keeps the API keys of service clients and checks them on each
+// request: expiry, revocation, the allowed addresses and the rate limit. Keys are only
+// ever kept as SHA-256 hashes.
+package apikeys
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// Store keeps API keys. Unknown keys fail with dbs.ErrAPIKeyNotFound and duplicate
+// names with dbs.ErrAPIKeyExists, whatever the implementation.
+type Store interface {
+	// List returns every key ordered by name, revoked ones included.
+	List(ctx context.Context) ([]schema.APIKey, error)
+	// Get returns the key with the given ID.
+	Get(ctx context.Context, keyId int) (*schema.APIKey, error)
+	// GetByHash returns the key whose SHA-256 hash is keyHash.
+	GetByHash(ctx context.Context, keyHash string) (*schema.APIKey, error)
+	// Create stores key, whose KeyHash must be set, and returns its ID.
+	Create(ctx context.Context, key schema.APIKey) (int, error)
+	// Revoke stops a key from working; revoking it again fails with ErrAPIKeyNotFound.
+	Revoke(ctx context.Context, keyId int) error
+	// Touch records when and from which address a key was last used.
+	Touch(ctx context.Context, keyId int, usedAt time.Time, ip string) error
+}
+
+// SQLStore keeps API keys in the api_keys table created by migrations/010_api_keys.sql.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+func (s *SQLStore) List(ctx context.Context) ([]schema.APIKey, error) {
+	return dbs.QueryAPIKeys(newrelic.FromContext(ctx), s.DB)
+}
+
+func (s *SQLStore) Get(ctx context.Context, keyId int) (*schema.APIKey, error) {
+	return dbs.GetAPIKey(newrelic.FromContext(ctx), s.DB, keyId)
+}
+
+func (s *SQLStore) GetByHash(ctx context.Context, keyHash string) (*schema.APIKey, error) {
+	return dbs.GetAPIKeyByHash(newrelic.FromContext(ctx), s.DB, keyHash)
+}
+
+func (s *SQLStore) Create(ctx context.Context, key schema.APIKey) (int, error) {
+	return dbs.InsertAPIKey(newrelic.FromContext(ctx), s.DB, key)
+}
+
+func (s *SQLStore) Revoke(ctx context.Context, keyId int) error {
+	return dbs.RevokeAPIKey(newrelic.FromContext(ctx), s.DB, keyId)
+}
+
+func (s *SQLStore) Touch(ctx context.Context, keyId int, usedAt time.Time, ip string) error {
+	return dbs.TouchAPIKey(newrelic.FromContext(ctx), s.DB, keyId, usedAt, ip)
+}
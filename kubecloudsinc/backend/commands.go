@@ -0,0 +1,160 @@
+package main
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/oidc/mockidp"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/term"
+)
+
+// commands are the subcommands of the binary. Without one it runs the service.
+var commands = map[string]func(args []string) error{
+	"create-admin":  createAdmin,
+	"hash-password": hashPassword,
+	"mock-idp":      mockIdp,
+}
+
+// createAdmin adds an admin user to the app_users table, so the first admin can log in
+// and create everyone else. The password is read from the terminal, or from the first
+// line of stdin when it is not a terminal.
+func createAdmin(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := fs.String("username", "", "login name of the new admin (required)")
+	employeeId := fs.Int("employee-id", 0, "employee record of the new admin, if any")
+	fs.Parse(args)
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+
+	_ = godotenv.Load()
+	if err := policy.LoadPassword(os.Getenv("PASSWORD_POLICY_FILE")); err != nil {
+		return err
+	}
+	password, err := readNewPassword(*username)
+	if err != nil {
+		return err
+	}
+	hash, err := users.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	dsn := os.Getenv("DATABASE_DSN")
+	if dsn == "" {
+		return errors.New("DATABASE_DSN is not set")
+	}
+	if err := dbs.InitDB(dsn); err != nil {
+		return err
+	}
+	user := schema.User{Username: *username, Role: "admin", PasswordHash: hash}
+	if *employeeId > 0 {
+		user.EmployeeId = employeeId
+	}
+	store := &users.SQLStore{DB: dbs.DB}
+	userId, err := store.Create(context.Background(), user)
+	if errors.Is(err, dbs.ErrUserExists) {
+		return fmt.Errorf("user %s already exists", *username)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created admin %s with user ID %d\n", *username, userId)
+	return nil
+}
+
+// hashPassword prints the bcrypt hash of a password for the passwordHash entries of
+// USERS_FILE.
+func hashPassword(args []string) error {
+	fs := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	username := fs.String("username", "", "user the password is for, checked by rejectUsername")
+	fs.Parse(args)
+
+	_ = godotenv.Load()
+	if err := policy.LoadPassword(os.Getenv("PASSWORD_POLICY_FILE")); err != nil {
+		return err
+	}
+	password, err := readNewPassword(*username)
+	if err != nil {
+		return err
+	}
+	hash, err := users.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+// mockIdp serves a mock OpenID Connect provider, so the OIDC sign-in can be tried and
+// tested without a real one. It must only ever listen locally.
+func mockIdp(args []string) error {
+	fs := flag.NewFlagSet("mock-idp", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:9400", "address to listen on")
+	issuer := fs.String("issuer", "", "issuer URL, by default http://<addr>")
+	clientId := fs.String("client-id", "employee-api", "client ID of the API")
+	clientSecret := fs.String("client-secret", "", "client secret of the API; empty accepts a public client")
+	usersPath := fs.String("users", "", "YAML file of users and their groups, by default the built-in ones")
+	fs.Parse(args)
+	if *issuer == "" {
+		*issuer = "http://" + *addr
+	}
+
+	idpUsers, err := mockidp.LoadUsers(*usersPath)
+	if err != nil {
+		return err
+	}
+	idp, err := mockidp.New(*issuer, *clientId, *clientSecret, idpUsers)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Mock IdP %s serving %d users for client %s\n", idp.Issuer, len(idpUsers), *clientId)
+	return http.ListenAndServe(*addr, idp)
+}
+
+// readNewPassword prompts twice without echo on a terminal, and otherwise reads one
+// line from stdin so the commands can be scripted. The password of username must
+// satisfy the password policy.
+func readNewPassword(username string) (string, error) {
+	var password string
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		first, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprint(os.Stderr, "Repeat password: ")
+		second, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(first) != string(second) {
+			return "", errors.New("the passwords do not match")
+		}
+		password = string(first)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read the password from stdin: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if err := users.ValidatePassword(username, password); err != nil {
+		return "", err
+	}
+	return password, nil
+}
@@ -0,0 +1,184 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrAPIKeyNotFound is returned when no API key has the requested ID or hash, and
+// when revoking a key that is already revoked.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrAPIKeyExists is returned when an API key name is already taken.
+var ErrAPIKeyExists = errors.New("API key name already exists")
+
+const apiKeyColumns = "key_id, name, prefix, key_hash, role, scopes, allowed_ips, rate_limit, expires_at, last_used_at, last_used_ip, created_by, created_at, revoked_at"
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*schema.APIKey, error) {
+	var key schema.APIKey
+	var scopes string
+	var allowedIps sql.NullString
+	err := row.Scan(&key.KeyId, &key.Name, &key.Prefix, &key.KeyHash, &key.Role, &scopes, &allowedIps, &key.RateLimit,
+		&key.ExpiresAt, &key.LastUsedAt, &key.LastUsedIp, &key.CreatedBy, &key.CreatedAt, &key.RevokedAt)
+	key.Scopes = splitList(scopes)
+	key.AllowedIps = splitList(allowedIps.String)
+	return &key, err
+}
+
+// splitList reads a comma-separated column; empty means none.
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// InsertAPIKey stores a new API key by its hash and returns its ID.
+func InsertAPIKey(txn *newrelic.Transaction, db *sql.DB, key schema.APIKey) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "api_keys",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	var keyId int
+	query := `INSERT INTO api_keys (name, prefix, key_hash, role, scopes, allowed_ips, rate_limit, expires_at, created_by, created_at)
+		VALUES (:1, :2, :3, :4, :5, :6, :7, :8, :9, :10) RETURNING key_id INTO :11`
+	_, err := db.ExecContext(ctx, query, key.Name, key.Prefix, key.KeyHash, key.Role, strings.Join(key.Scopes, ","),
+		strings.Join(key.AllowedIps, ","), key.RateLimit, key.ExpiresAt, key.CreatedBy, key.CreatedAt, sql.Out{Dest: &keyId})
+	if isUniqueViolation(err) {
+		return 0, ErrAPIKeyExists
+	}
+	if err != nil {
+		log.Printf("Failed to insert API key: %v", err)
+		return 0, fmt.Errorf("failed to insert API key: %v", err)
+	}
+	return keyId, nil
+}
+
+// QueryAPIKeys lists all API keys by name, revoked ones included.
+func QueryAPIKeys(txn *newrelic.Transaction, db *sql.DB) ([]schema.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "api_keys",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	rows, err := db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	keys := []schema.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKey reads an API key by ID.
+func GetAPIKey(txn *newrelic.Transaction, db *sql.DB, keyId int) (*schema.APIKey, error) {
+	return getAPIKey(txn, db, "key_id", keyId)
+}
+
+// GetAPIKeyByHash reads the API key whose SHA-256 hash is keyHash.
+func GetAPIKeyByHash(txn *newrelic.Transaction, db *sql.DB, keyHash string) (*schema.APIKey, error) {
+	return getAPIKey(txn, db, "key_hash", keyHash)
+}
+
+func getAPIKey(txn *newrelic.Transaction, db *sql.DB, column string, value interface{}) (*schema.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "api_keys",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	key, err := scanAPIKey(db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE "+column+" = :1", value))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key: %v", err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey stops an API key from working. Revoking it twice fails with
+// ErrAPIKeyNotFound.
+func RevokeAPIKey(txn *newrelic.Transaction, db *sql.DB, keyId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "api_keys",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	result, err := db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = :1 WHERE key_id = :2 AND revoked_at IS NULL`, time.Now().UTC(), keyId)
+	if err != nil {
+		log.Printf("Failed to revoke API key %d: %v", keyId, err)
+		return fmt.Errorf("failed to revoke API key %d: %v", keyId, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for API key %d: %v", keyId, err)
+	}
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records when and from where an API key was last used.
+func TouchAPIKey(txn *newrelic.Transaction, db *sql.DB, keyId int, usedAt time.Time, ip string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "api_keys",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	_, err := db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = :1, last_used_ip = :2 WHERE key_id = :3`, usedAt, ip, keyId)
+	if err != nil {
+		return fmt.Errorf("failed to record the use of API key %d: %v", keyId, err)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	EmployeeId int
+	Actor      string
+	Field      string
+	From       *time.Time
+	To         *time.Time
+}
+
+// employeeFields lists the JSON names of schema.Employee in declaration order, which
+// is the order field changes are reported in.
+var employeeFields = func() []string {
+	t := reflect.TypeOf(schema.Employee{})
+	fields := make([]string, t.NumField())
+	for i := range fields {
+		fields[i] = strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return fields
+}()
+
+// IsAuditField reports whether field is a schema.Employee field name.
+func IsAuditField(field string) bool {
+	for _, f := range employeeFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// diffEmployees returns the fields whose values differ between before and after. A
+// nil image counts as all fields being empty, so creations and deletions list every
+// field that had a value.
+func diffEmployees(before, after *schema.Employee) ([]schema.FieldChange, error) {
+	oldValues, err := employeeValues(before)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := employeeValues(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []schema.FieldChange
+	for _, field := range employeeFields {
+		oldValue, newValue := oldValues[field], newValues[field]
+		if bytes.Equal(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, schema.FieldChange{Field: field, Before: oldValue, After: newValue})
+	}
+	return changes, nil
+}
+
+// employeeValues returns the JSON encoding of each set field; null fields are left out.
+func employeeValues(emp *schema.Employee) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage)
+	if emp == nil {
+		return values, nil
+	}
+	data, err := json.Marshal(emp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode employee: %v", err)
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode employee: %v", err)
+	}
+	for field, value := range values {
+		if string(value) == "null" {
+			delete(values, field)
+		}
+	}
+	return values, nil
+}
+
+// insertAuditEntry appends an audit entry with its field-level diff as part of the
+// caller's transaction, so the entry exists exactly when the mutation commits.
+func insertAuditEntry(ctx context.Context, tx *sql.Tx, action string, employeeId int, before, after *schema.Employee, actor schema.Actor, occurredAt time.Time) error {
+	changes, err := diffEmployees(before, after)
+	if err != nil {
+		return err
+	}
+
+	var auditId int64
+	query := `INSERT INTO audit_log (occurred_at, actor_username, actor_role, endpoint, action, employee_id)
+              VALUES (:1, :2, :3, :4, :5, :6) RETURNING audit_id INTO :7`
+	_, err = tx.ExecContext(ctx, query, occurredAt, actor.Username, actor.Role, truncate(actor.Endpoint, 200), action, employeeId, sql.Out{Dest: &auditId})
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %v", err)
+	}
+
+	for _, change := range changes {
+		_, err := tx.ExecContext(ctx, `INSERT INTO audit_log_changes (audit_id, field, old_value, new_value) VALUES (:1, :2, :3, :4)`,
+			auditId, change.Field, nullableJSON(change.Before), nullableJSON(change.After))
+		if err != nil {
+			return fmt.Errorf("failed to write audit change for %s: %v", change.Field, err)
+		}
+	}
+	return nil
+}
+
+func nullableJSON(value json.RawMessage) interface{} {
+	if value == nil {
+		return nil
+	}
+	return string(value)
+}
+
+// QueryAuditLog returns up to limit audit entries matching filter, newest first, each
+// with all of its field changes. Filtering by field selects the entries that changed
+// that field.
+func QueryAuditLog(txn *newrelic.Transaction, db *sql.DB, filter AuditFilter, limit int) ([]schema.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "audit_log",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	query := `SELECT a.audit_id, a.occurred_at, a.actor_username, a.actor_role, a.endpoint, a.action, a.employee_id FROM audit_log a WHERE 1=1`
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		query += fmt.Sprintf(condition, len(args))
+	}
+	if filter.EmployeeId > 0 {
+		addCondition(" AND a.employee_id = :%d", filter.EmployeeId)
+	}
+	if filter.Actor != "" {
+		addCondition(" AND a.actor_username = :%d", filter.Actor)
+	}
+	if filter.Field != "" {
+		addCondition(" AND EXISTS (SELECT 1 FROM audit_log_changes c WHERE c.audit_id = a.audit_id AND c.field = :%d)", filter.Field)
+	}
+	if filter.From != nil {
+		addCondition(" AND a.occurred_at >= :%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition(" AND a.occurred_at < :%d", *filter.To)
+	}
+	addCondition(" ORDER BY a.occurred_at DESC, a.audit_id DESC FETCH FIRST :%d ROWS ONLY", limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	entries := []schema.AuditEntry{}
+	index := make(map[int64]int)
+	for rows.Next() {
+		var entry schema.AuditEntry
+		var username, role, endpoint sql.NullString
+		if err := rows.Scan(&entry.AuditId, &entry.OccurredAt, &username, &role, &endpoint, &entry.Action, &entry.EmployeeId); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		entry.Actor = schema.Actor{Username: username.String, Role: role.String}
+		entry.Endpoint = endpoint.String
+		entry.Changes = []schema.FieldChange{}
+		index[entry.AuditId] = len(entries)
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	if err := loadAuditChanges(ctx, db, entries, index); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// loadAuditChanges fills in the field changes of entries with one query.
+func loadAuditChanges(ctx context.Context, db *sql.DB, entries []schema.AuditEntry, index map[int64]int) error {
+	placeholders := make([]string, len(entries))
+	args := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		placeholders[i] = fmt.Sprintf(":%d", i+1)
+		args[i] = entry.AuditId
+	}
+	query := `SELECT audit_id, field, old_value, new_value FROM audit_log_changes WHERE audit_id IN (` + strings.Join(placeholders, ", ") + `)`
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to read audit changes: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var auditId int64
+		var field string
+		var oldValue, newValue sql.NullString
+		if err := rows.Scan(&auditId, &field, &oldValue, &newValue); err != nil {
+			return fmt.Errorf("failed to scan audit change: %v", err)
+		}
+		change := schema.FieldChange{Field: field}
+		if oldValue.Valid {
+			change.Before = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			change.After = json.RawMessage(newValue.String)
+		}
+		entry := &entries[index[auditId]]
+		entry.Changes = append(entry.Changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating audit changes: %v", err)
+	}
+
+	for i := range entries {
+		sortFieldChanges(entries[i].Changes)
+	}
+	return nil
+}
+
+// sortFieldChanges puts changes read back from the database into field declaration
+// order, the order they were recorded in.
+func sortFieldChanges(changes []schema.FieldChange) {
+	order := make(map[string]int, len(employeeFields))
+	for i, field := range employeeFields {
+		order[field] = i
+	}
+	sort.SliceStable(changes, func(a, b int) bool { return order[changes[a].Field] < order[changes[b].Field] })
+}
@@ -0,0 +1,128 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrBulkLimitExceeded is returned when a bulk update filter matches more employees
+// than the caller allows.
+var ErrBulkLimitExceeded = errors.New("bulk update limit exceeded")
+
+// BulkUpdateEmployees updates several employees in one transaction. The employees are
+// either given by ID or, when employeeIds is nil, selected with filter. Each row is
+// locked and passed to apply, which returns the full record to store; the write then
+// goes through the same full-replace path as UpdateEmployeeDB, including change events.
+//
+// Only employees in filter.Scope are updated, also when they are given by ID; the
+// others fail as not found.
+//
+// Every employee is attempted so the response reports all failures at once. The
+// transaction is only committed when none failed and dryRun is not set; otherwise it
+// is rolled back, so a dry run also surfaces database constraint violations.
+func BulkUpdateEmployees(txn *newrelic.Transaction, db *sql.DB, employeeIds []int, filter EmployeeFilter, limit int, dryRun bool, actor schema.Actor, apply func(before schema.Employee) (Employees, error)) (*schema.BulkUpdateResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employees",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if employeeIds == nil {
+		employeeIds, err = selectEmployeeIdsTx(ctx, tx, filter, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	inScope, err := filter.Scope.membersTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Bulk updating %d employees (dryRun=%t)", len(employeeIds), dryRun)
+
+	resp := &schema.BulkUpdateResponse{
+		DryRun:  dryRun,
+		Matched: len(employeeIds),
+		Results: make([]schema.BulkUpdateResult, 0, len(employeeIds)),
+	}
+	for _, employeeId := range employeeIds {
+		result := schema.BulkUpdateResult{EmployeeId: employeeId, Status: schema.BulkValid}
+		var before *schema.Employee
+		err := ErrEmployeeNotFound
+		if inScope == nil || inScope[employeeId] {
+			before, err = selectEmployeeTx(ctx, tx, employeeId, true)
+		}
+		if err == nil {
+			result.Before = before
+			var emp Employees
+			if emp, err = apply(*before); err == nil {
+				result.After, err = updateEmployeeTx(ctx, tx, employeeId, emp, before, actor)
+			}
+		}
+		if err != nil {
+			result.Status = schema.BulkFailed
+			result.Error = err.Error()
+			resp.Failed++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	if resp.Failed > 0 || dryRun {
+		log.Printf("Bulk update rolled back: %d of %d failed, dryRun=%t", resp.Failed, resp.Matched, dryRun)
+		return resp, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	resp.Applied = true
+	for i := range resp.Results {
+		resp.Results[i].Status = schema.BulkUpdated
+	}
+	return resp, nil
+}
+
+// selectEmployeeIdsTx locks and returns the IDs of the employees matching filter.
+func selectEmployeeIdsTx(ctx context.Context, tx *sql.Tx, filter EmployeeFilter, limit int) ([]int, error) {
+	conditions, args := filter.where()
+	rows, err := tx.QueryContext(ctx, "SELECT employee_id FROM employees WHERE 1=1"+conditions+" ORDER BY employee_id FOR UPDATE", args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	employeeIds := []int{}
+	for rows.Next() {
+		var employeeId int
+		if err := rows.Scan(&employeeId); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		employeeIds = append(employeeIds, employeeId)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	if len(employeeIds) > limit {
+		return nil, fmt.Errorf("%w: the filter matches %d employees, at most %d can be updated at once", ErrBulkLimitExceeded, len(employeeIds), limit)
+	}
+	return employeeIds, nil
+}
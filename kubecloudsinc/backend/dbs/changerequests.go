@@ -0,0 +1,267 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrChangeRequestNotFound is returned when no change request has the given ID.
+var ErrChangeRequestNotFound = errors.New("change request not found")
+
+// ErrChangeRequestClosed is returned when a request that was already approved or
+// rejected is reviewed again.
+var ErrChangeRequestClosed = errors.New("change request has already been reviewed")
+
+const changeRequestColumns = `change_request_id, employee_id, changes, changed_fields, restricted_fields, status, requested_by, requested_role, requested_at, reviewed_by, reviewed_at, review_comment`
+
+// InsertChangeRequest stores the changes to changedFields to be applied once an admin
+// approves them.
+func InsertChangeRequest(txn *newrelic.Transaction, db *sql.DB, employeeId int, changes schema.Employee, changedFields, restrictedFields []string, actor schema.Actor) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_change_requests",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	if err := checkEmployeeExistence(db, employeeId, "", Scope{}); err != nil {
+		return 0, err
+	}
+
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode change request: %v", err)
+	}
+
+	var changeRequestId int
+	query := `INSERT INTO employee_change_requests (employee_id, changes, changed_fields, restricted_fields, requested_by, requested_role)
+              VALUES (:1, :2, :3, :4, :5, :6) RETURNING change_request_id INTO :7`
+	_, err = db.ExecContext(ctx, query, employeeId, string(payload), strings.Join(changedFields, ","), strings.Join(restrictedFields, ","), actor.Username, actor.Role, sql.Out{Dest: &changeRequestId})
+	if err != nil {
+		log.Printf("Failed to insert change request: %v", err)
+		return 0, fmt.Errorf("failed to insert change request: %v", err)
+	}
+	log.Printf("Change request %d created for employee %d by %s", changeRequestId, employeeId, actor.Username)
+	return changeRequestId, nil
+}
+
+// QueryChangeRequests lists change requests, oldest first, optionally filtered by
+// status and employee.
+func QueryChangeRequests(txn *newrelic.Transaction, db *sql.DB, status string, employeeId int) ([]schema.ChangeRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_change_requests",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	query := "SELECT " + changeRequestColumns + " FROM employee_change_requests WHERE 1=1"
+	var args []interface{}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = :%d", len(args))
+	}
+	if employeeId > 0 {
+		args = append(args, employeeId)
+		query += fmt.Sprintf(" AND employee_id = :%d", len(args))
+	}
+	query += " ORDER BY change_request_id"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	requests := []schema.ChangeRequest{}
+	for rows.Next() {
+		request, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return requests, nil
+}
+
+func GetChangeRequest(txn *newrelic.Transaction, db *sql.DB, changeRequestId int) (*schema.ChangeRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_change_requests",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	row := db.QueryRowContext(ctx, "SELECT "+changeRequestColumns+" FROM employee_change_requests WHERE change_request_id = :1", changeRequestId)
+	request, err := scanChangeRequest(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChangeRequestNotFound
+	}
+	return request, err
+}
+
+// ApproveChangeRequest applies the requested changes and marks the request approved in
+// one transaction. The request and the employee are locked, apply merges the changes
+// into the current record, and the write goes through the same path as
+// UpdateEmployeeDB. The editor who made the request is recorded as the actor of the
+// update and the approving admin as the reviewer. If it fails, the request stays
+// pending.
+func ApproveChangeRequest(txn *newrelic.Transaction, db *sql.DB, changeRequestId int, comment *string, actor schema.Actor, apply func(before schema.Employee, request schema.ChangeRequest) (Employees, error)) (*schema.ChangeRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_change_requests",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	request, err := selectPendingChangeRequestTx(ctx, tx, changeRequestId)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := selectEmployeeTx(ctx, tx, request.EmployeeId, true)
+	if err != nil {
+		return nil, err
+	}
+	emp, err := apply(*before, *request)
+	if err != nil {
+		return nil, err
+	}
+	author := request.RequestedBy
+	author.Endpoint = fmt.Sprintf("change request %d approved by %s", changeRequestId, actor.Username)
+	if _, err := updateEmployeeTx(ctx, tx, request.EmployeeId, emp, before, author); err != nil {
+		return nil, err
+	}
+
+	if err := reviewChangeRequestTx(ctx, tx, request, schema.ChangeRequestApproved, comment, actor); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Change request %d approved by %s", changeRequestId, actor.Username)
+	return request, nil
+}
+
+// RejectChangeRequest closes a pending request without touching the employee.
+func RejectChangeRequest(txn *newrelic.Transaction, db *sql.DB, changeRequestId int, comment *string, actor schema.Actor) (*schema.ChangeRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_change_requests",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	request, err := selectPendingChangeRequestTx(ctx, tx, changeRequestId)
+	if err != nil {
+		return nil, err
+	}
+	if err := reviewChangeRequestTx(ctx, tx, request, schema.ChangeRequestRejected, comment, actor); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Change request %d rejected by %s", changeRequestId, actor.Username)
+	return request, nil
+}
+
+// selectPendingChangeRequestTx locks a change request and checks it is still pending.
+func selectPendingChangeRequestTx(ctx context.Context, tx *sql.Tx, changeRequestId int) (*schema.ChangeRequest, error) {
+	row := tx.QueryRowContext(ctx, "SELECT "+changeRequestColumns+" FROM employee_change_requests WHERE change_request_id = :1 FOR UPDATE", changeRequestId)
+	request, err := scanChangeRequest(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChangeRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != schema.ChangeRequestPending {
+		return nil, fmt.Errorf("%w: request %d is %s", ErrChangeRequestClosed, changeRequestId, request.Status)
+	}
+	return request, nil
+}
+
+// reviewChangeRequestTx records the decision and updates request to match.
+func reviewChangeRequestTx(ctx context.Context, tx *sql.Tx, request *schema.ChangeRequest, status string, comment *string, actor schema.Actor) error {
+	reviewedAt := time.Now().UTC()
+	_, err := tx.ExecContext(ctx, `UPDATE employee_change_requests SET status = :1, reviewed_by = :2, reviewed_at = :3, review_comment = :4 WHERE change_request_id = :5`,
+		status, actor.Username, reviewedAt, comment, request.ChangeRequestId)
+	if err != nil {
+		return fmt.Errorf("failed to update change request: %v", err)
+	}
+	request.Status = status
+	request.ReviewedBy = &actor.Username
+	request.ReviewedAt = &reviewedAt
+	request.ReviewComment = comment
+	return nil
+}
+
+func scanChangeRequest(row interface{ Scan(...interface{}) error }) (*schema.ChangeRequest, error) {
+	var request schema.ChangeRequest
+	var payload, changedFields, restrictedFields string
+	var requestedBy, requestedRole sql.NullString
+	err := row.Scan(&request.ChangeRequestId, &request.EmployeeId, &payload, &changedFields, &restrictedFields, &request.Status, &requestedBy, &requestedRole,
+		&request.RequestedAt, &request.ReviewedBy, &request.ReviewedAt, &request.ReviewComment)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan change request: %v", err)
+	}
+	if err := json.Unmarshal([]byte(payload), &request.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode change request %d: %v", request.ChangeRequestId, err)
+	}
+	request.ChangedFields = strings.Split(changedFields, ",")
+	request.RestrictedFields = strings.Split(restrictedFields, ",")
+	request.RequestedBy = schema.Actor{Username: requestedBy.String, Role: requestedRole.String}
+	return &request, nil
+}
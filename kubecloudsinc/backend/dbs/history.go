@@ -0,0 +1,306 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrEmployeeNotEmployed is returned when an employee existed at some point but not
+// at the requested time.
+var ErrEmployeeNotEmployed = fmt.Errorf("%w at the requested time", ErrEmployeeNotFound)
+
+// jobSpan is one JOB_HISTORY row: the employee held JobId in DepartmentId from Start
+// until End.
+type jobSpan struct {
+	Start        time.Time
+	End          time.Time
+	JobId        *string
+	DepartmentId *int
+}
+
+// GetEmployeeAsOf reconstructs an employee as it was just before asOf. Starting from the current
+// record, or nothing if the employee was deleted, the audit entries after asOf are
+// undone newest first. JOB_HISTORY then supplies the job and department for periods
+// it covers, which also reach back before the audit log existed.
+func GetEmployeeAsOf(txn *newrelic.Transaction, db *sql.DB, employeeId int, asOf time.Time) (*schema.EmployeeAsOf, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "audit_log",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	current, err := selectEmployeeTx(ctx, tx, employeeId, false)
+	if err != nil && !errors.Is(err, ErrEmployeeNotFound) {
+		return nil, err
+	}
+	values, err := employeeValues(current)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := queryEmployeeAudit(ctx, tx, employeeId)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil && len(entries) == 0 {
+		return nil, ErrEmployeeNotFound
+	}
+	for i := len(entries) - 1; i >= 0 && !entries[i].OccurredAt.Before(asOf); i-- {
+		if entries[i].Action == schema.EventEmployeeCreated {
+			return nil, ErrEmployeeNotEmployed
+		}
+		for _, change := range entries[i].Changes {
+			if change.Before == nil {
+				delete(values, change.Field)
+			} else {
+				values[change.Field] = change.Before
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil, ErrEmployeeNotEmployed
+	}
+
+	var emp schema.Employee
+	data, _ := json.Marshal(values)
+	if err := json.Unmarshal(data, &emp); err != nil {
+		return nil, fmt.Errorf("failed to rebuild employee: %v", err)
+	}
+	if emp.HireDate != nil {
+		if hireDate, err := time.Parse(time.RFC3339, *emp.HireDate); err == nil && !hireDate.Before(asOf) {
+			return nil, ErrEmployeeNotEmployed
+		}
+	}
+
+	spans, err := queryJobSpans(ctx, tx, employeeId)
+	if err != nil {
+		return nil, err
+	}
+	for _, span := range spans {
+		if span.Start.Before(asOf) && !span.End.Before(asOf) {
+			emp.JobId = span.JobId
+			emp.DepartmentId = span.DepartmentId
+			break
+		}
+	}
+
+	// Changes before the first audit entry are only known from JOB_HISTORY
+	var auditStart sql.NullTime
+	if err := tx.QueryRowContext(ctx, `SELECT MIN(occurred_at) FROM audit_log`).Scan(&auditStart); err != nil {
+		return nil, fmt.Errorf("failed to read audit log start: %v", err)
+	}
+
+	return &schema.EmployeeAsOf{
+		Employee: emp,
+		Complete: auditStart.Valid && !asOf.Before(auditStart.Time),
+	}, nil
+}
+
+// QueryEmployeeTimeline lists everything known about an employee's history in
+// chronological order: the hire, job changes from JOB_HISTORY and every audited
+// mutation. JOB_HISTORY rows that an audit entry already reports are left out.
+func QueryEmployeeTimeline(txn *newrelic.Transaction, db *sql.DB, employeeId int) ([]schema.TimelineEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "audit_log",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	current, err := selectEmployeeTx(ctx, tx, employeeId, false)
+	if err != nil && !errors.Is(err, ErrEmployeeNotFound) {
+		return nil, err
+	}
+	entries, err := queryEmployeeAudit(ctx, tx, employeeId)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil && len(entries) == 0 {
+		return nil, ErrEmployeeNotFound
+	}
+	spans, err := queryJobSpans(ctx, tx, employeeId)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := []schema.TimelineEvent{}
+
+	// The hire date comes from the current record or, for a deleted employee, the
+	// last image in the audit log
+	hireDate := ""
+	if current != nil && current.HireDate != nil {
+		hireDate = *current.HireDate
+	} else {
+		for _, entry := range entries {
+			for _, change := range entry.Changes {
+				if change.Field == "hireDate" && change.Before != nil {
+					json.Unmarshal(change.Before, &hireDate)
+				}
+			}
+		}
+	}
+	if hired, err := time.Parse(time.RFC3339, hireDate); err == nil {
+		timeline = append(timeline, schema.TimelineEvent{OccurredAt: hired, Source: schema.TimelineHire, Action: "employee.hired"})
+	}
+
+	// Audit entries that changed the job or department on the same day as a
+	// JOB_HISTORY row describe the same event
+	auditedJobDays := make(map[string]bool)
+	for _, entry := range entries {
+		for _, change := range entry.Changes {
+			if entry.Action == schema.EventEmployeeUpdated && (change.Field == "jobId" || change.Field == "departmentId") {
+				auditedJobDays[entry.OccurredAt.Format("2006-01-02")] = true
+			}
+		}
+	}
+	for i, span := range spans {
+		if auditedJobDays[span.End.Format("2006-01-02")] {
+			continue
+		}
+		// The job that followed is the next span or, after the last one, the current job
+		var nextJobId *string
+		var nextDepartmentId *int
+		if i+1 < len(spans) {
+			nextJobId, nextDepartmentId = spans[i+1].JobId, spans[i+1].DepartmentId
+		} else if current != nil {
+			nextJobId, nextDepartmentId = current.JobId, current.DepartmentId
+		}
+		var changes []schema.FieldChange
+		if change := fieldChange("jobId", span.JobId, nextJobId); change != nil {
+			changes = append(changes, *change)
+		}
+		if change := fieldChange("departmentId", span.DepartmentId, nextDepartmentId); change != nil {
+			changes = append(changes, *change)
+		}
+		timeline = append(timeline, schema.TimelineEvent{OccurredAt: span.End, Source: schema.TimelineJobHistory, Action: "job.changed", Changes: changes})
+	}
+
+	for _, entry := range entries {
+		actor := entry.Actor
+		timeline = append(timeline, schema.TimelineEvent{
+			OccurredAt: entry.OccurredAt,
+			Source:     schema.TimelineAudit,
+			Action:     entry.Action,
+			Actor:      &actor,
+			Endpoint:   entry.Endpoint,
+			Changes:    entry.Changes,
+		})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].OccurredAt.Before(timeline[j].OccurredAt) })
+	for i := range timeline {
+		if timeline[i].Changes == nil {
+			timeline[i].Changes = []schema.FieldChange{}
+		}
+	}
+	return timeline, nil
+}
+
+func fieldChange(field string, before, after interface{}) *schema.FieldChange {
+	oldValue, _ := json.Marshal(before)
+	newValue, _ := json.Marshal(after)
+	if string(oldValue) == string(newValue) {
+		return nil
+	}
+	return &schema.FieldChange{Field: field, Before: oldValue, After: newValue}
+}
+
+// queryEmployeeAudit returns all audit entries for an employee, oldest first, with
+// their field changes.
+func queryEmployeeAudit(ctx context.Context, tx *sql.Tx, employeeId int) ([]schema.AuditEntry, error) {
+	query := `SELECT a.audit_id, a.occurred_at, a.actor_username, a.actor_role, a.endpoint, a.action, c.field, c.old_value, c.new_value
+              FROM audit_log a
+              LEFT JOIN audit_log_changes c ON c.audit_id = a.audit_id
+              WHERE a.employee_id = :1
+              ORDER BY a.occurred_at, a.audit_id`
+	rows, err := tx.QueryContext(ctx, query, employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []schema.AuditEntry
+	for rows.Next() {
+		var entry schema.AuditEntry
+		var username, role, endpoint, field, oldValue, newValue sql.NullString
+		if err := rows.Scan(&entry.AuditId, &entry.OccurredAt, &username, &role, &endpoint, &entry.Action, &field, &oldValue, &newValue); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		if len(entries) == 0 || entries[len(entries)-1].AuditId != entry.AuditId {
+			entry.EmployeeId = employeeId
+			entry.Actor = schema.Actor{Username: username.String, Role: role.String}
+			entry.Endpoint = endpoint.String
+			entry.Changes = []schema.FieldChange{}
+			entries = append(entries, entry)
+		}
+		if !field.Valid {
+			continue
+		}
+		change := schema.FieldChange{Field: field.String}
+		if oldValue.Valid {
+			change.Before = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			change.After = json.RawMessage(newValue.String)
+		}
+		last := &entries[len(entries)-1]
+		last.Changes = append(last.Changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %v", err)
+	}
+	for i := range entries {
+		sortFieldChanges(entries[i].Changes)
+	}
+	return entries, nil
+}
+
+func queryJobSpans(ctx context.Context, tx *sql.Tx, employeeId int) ([]jobSpan, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT start_date, end_date, job_id, department_id FROM job_history WHERE employee_id = :1 ORDER BY start_date`, employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job history: %v", err)
+	}
+	defer rows.Close()
+
+	var spans []jobSpan
+	for rows.Next() {
+		var span jobSpan
+		if err := rows.Scan(&span.Start, &span.End, &span.JobId, &span.DepartmentId); err != nil {
+			return nil, fmt.Errorf("failed to scan job history: %v", err)
+		}
+		spans = append(spans, span)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating job history: %v", err)
+	}
+	return spans, nil
+}
@@ -0,0 +1,281 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Batched lookups over the HR tables. Each function resolves a whole set of keys in
+// as few round trips as possible so callers such as the GraphQL loaders avoid N+1
+// queries. Oracle limits IN lists to 1000 entries, so keys are sent in chunks.
+
+const maxInListSize = 1000
+
+// inList returns ":1, :2, ..." for n bind variables, numbered after the bound
+// variables already in the query.
+func inList(bound, n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf(":%d", bound+i+1)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// queryIn runs query once per chunk of keys, substituting the IN list for %s, and
+// passes every row to scan.
+func queryIn[K any](ctx context.Context, db *sql.DB, query string, keys []K, scan func(*sql.Rows) error) error {
+	return queryInAfter(ctx, db, query, nil, keys, scan)
+}
+
+// queryInAfter is queryIn for queries that bind args ahead of the IN list.
+func queryInAfter[K any](ctx context.Context, db *sql.DB, query string, bound []interface{}, keys []K, scan func(*sql.Rows) error) error {
+	for start := 0; start < len(keys); start += maxInListSize {
+		end := start + maxInListSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunk := keys[start:end]
+		args := append([]interface{}{}, bound...)
+		for _, k := range chunk {
+			args = append(args, k)
+		}
+
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(query, inList(len(bound), len(chunk))), args...)
+		if err != nil {
+			return fmt.Errorf("query failed: %v", err)
+		}
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan row: %v", err)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("error iterating rows: %v", err)
+		}
+	}
+	return nil
+}
+
+func scanEmployee(rows *sql.Rows) (schema.Employee, error) {
+	var emp schema.Employee
+	err := rows.Scan(&emp.EmployeeId, &emp.FirstName, &emp.LastName, &emp.Email, &emp.Phone, &emp.HireDate, &emp.JobId, &emp.Salary, &emp.CommissionPct, &emp.ManagerId, &emp.DepartmentId)
+	return emp, err
+}
+
+// EmployeeFilter narrows QueryEmployeePage and BulkUpdateEmployees. Zero values are
+// ignored. Scope is the caller's rather than part of the search.
+type EmployeeFilter struct {
+	DepartmentId int
+	ManagerId    int
+	JobId        string
+	Scope        Scope
+}
+
+// IsZero reports whether the filter matches every employee in its scope.
+func (f EmployeeFilter) IsZero() bool {
+	return f == EmployeeFilter{Scope: f.Scope}
+}
+
+// where returns the " AND ..." conditions for the filter and their bind values.
+func (f EmployeeFilter) where() (string, []interface{}) {
+	var conditions string
+	var args []interface{}
+	if f.DepartmentId > 0 {
+		args = append(args, f.DepartmentId)
+		conditions += fmt.Sprintf(" AND department_id = :%d", len(args))
+	}
+	if f.ManagerId > 0 {
+		args = append(args, f.ManagerId)
+		conditions += fmt.Sprintf(" AND manager_id = :%d", len(args))
+	}
+	if f.JobId != "" {
+		args = append(args, f.JobId)
+		conditions += fmt.Sprintf(" AND job_id = :%d", len(args))
+	}
+	condition, scopeArgs := f.Scope.condition("employee_id", len(args))
+	return conditions + condition, append(args, scopeArgs...)
+}
+
+// QueryEmployeePage returns one page of employees ordered by ID.
+func QueryEmployeePage(ctx context.Context, db *sql.DB, filter EmployeeFilter, limit, offset int) ([]schema.Employee, error) {
+	conditions, args := filter.where()
+	query := "SELECT " + employeeColumns + " FROM employees WHERE 1=1" + conditions
+	args = append(args, offset, limit)
+	query += fmt.Sprintf(" ORDER BY employee_id OFFSET :%d ROWS FETCH NEXT :%d ROWS ONLY", len(args)-1, len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	employees := []schema.Employee{}
+	for rows.Next() {
+		emp, err := scanEmployee(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		employees = append(employees, emp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return employees, nil
+}
+
+func QueryEmployeesByIds(ctx context.Context, db *sql.DB, employeeIds []int, scope Scope) ([]schema.Employee, error) {
+	var employees []schema.Employee
+	condition, args := scope.condition("employee_id", 0)
+	err := queryInAfter(ctx, db, "SELECT "+employeeColumns+" FROM employees WHERE 1=1"+condition+" AND employee_id IN (%s)", args, employeeIds, func(rows *sql.Rows) error {
+		emp, err := scanEmployee(rows)
+		employees = append(employees, emp)
+		return err
+	})
+	return employees, err
+}
+
+// QueryManagers returns every employee in scope that has at least one direct report.
+func QueryManagers(ctx context.Context, db *sql.DB, scope Scope) ([]schema.Employee, error) {
+	employees := []schema.Employee{}
+	condition, args := scope.condition("employee_id", 0)
+	err := queryAll(ctx, db, "SELECT "+employeeColumns+" FROM employees WHERE employee_id IN (SELECT manager_id FROM employees)"+condition+" ORDER BY employee_id", args, func(rows *sql.Rows) error {
+		emp, err := scanEmployee(rows)
+		employees = append(employees, emp)
+		return err
+	})
+	return employees, err
+}
+
+func QueryEmployeesByManagerIds(ctx context.Context, db *sql.DB, managerIds []int, scope Scope) ([]schema.Employee, error) {
+	var employees []schema.Employee
+	condition, args := scope.condition("employee_id", 0)
+	err := queryInAfter(ctx, db, "SELECT "+employeeColumns+" FROM employees WHERE 1=1"+condition+" AND manager_id IN (%s) ORDER BY employee_id", args, managerIds, func(rows *sql.Rows) error {
+		emp, err := scanEmployee(rows)
+		employees = append(employees, emp)
+		return err
+	})
+	return employees, err
+}
+
+func QueryEmployeesByDepartmentIds(ctx context.Context, db *sql.DB, departmentIds []int, scope Scope) ([]schema.Employee, error) {
+	var employees []schema.Employee
+	condition, args := scope.condition("employee_id", 0)
+	err := queryInAfter(ctx, db, "SELECT "+employeeColumns+" FROM employees WHERE 1=1"+condition+" AND department_id IN (%s) ORDER BY employee_id", args, departmentIds, func(rows *sql.Rows) error {
+		emp, err := scanEmployee(rows)
+		employees = append(employees, emp)
+		return err
+	})
+	return employees, err
+}
+
+func QueryJobHistoryByEmployeeIds(ctx context.Context, db *sql.DB, employeeIds []int) ([]schema.JobHistoryRecord, error) {
+	var history []schema.JobHistoryRecord
+	err := queryIn(ctx, db, "SELECT employee_id, start_date, end_date, job_id, department_id FROM job_history WHERE employee_id IN (%s) ORDER BY start_date", employeeIds, func(rows *sql.Rows) error {
+		var h schema.JobHistoryRecord
+		err := rows.Scan(&h.EmployeeId, &h.StartDate, &h.EndDate, &h.JobId, &h.DepartmentId)
+		history = append(history, h)
+		return err
+	})
+	return history, err
+}
+
+// QueryJobs returns the jobs with the given IDs, or every job when jobIds is nil.
+func QueryJobs(ctx context.Context, db *sql.DB, jobIds []string) ([]schema.JobRecord, error) {
+	var jobs []schema.JobRecord
+	scan := func(rows *sql.Rows) error {
+		var j schema.JobRecord
+		err := rows.Scan(&j.JobId, &j.JobTitle, &j.MinSalary, &j.MaxSalary)
+		jobs = append(jobs, j)
+		return err
+	}
+	var err error
+	if jobIds == nil {
+		err = queryAll(ctx, db, "SELECT job_id, job_title, min_salary, max_salary FROM jobs ORDER BY job_id", nil, scan)
+	} else {
+		err = queryIn(ctx, db, "SELECT job_id, job_title, min_salary, max_salary FROM jobs WHERE job_id IN (%s)", jobIds, scan)
+	}
+	return jobs, err
+}
+
+// QueryDepartments returns the departments with the given IDs, or every department
+// when departmentIds is nil.
+func QueryDepartments(ctx context.Context, db *sql.DB, departmentIds []int) ([]schema.DepartmentRecord, error) {
+	var departments []schema.DepartmentRecord
+	scan := func(rows *sql.Rows) error {
+		var d schema.DepartmentRecord
+		err := rows.Scan(&d.DepartmentId, &d.DepartmentName, &d.ManagerId, &d.LocationId)
+		departments = append(departments, d)
+		return err
+	}
+	var err error
+	if departmentIds == nil {
+		err = queryAll(ctx, db, "SELECT department_id, department_name, manager_id, location_id FROM departments ORDER BY department_id", nil, scan)
+	} else {
+		err = queryIn(ctx, db, "SELECT department_id, department_name, manager_id, location_id FROM departments WHERE department_id IN (%s)", departmentIds, scan)
+	}
+	return departments, err
+}
+
+// QueryLocations returns the locations with the given IDs, or every location when
+// locationIds is nil.
+func QueryLocations(ctx context.Context, db *sql.DB, locationIds []int) ([]schema.LocationRecord, error) {
+	var locations []schema.LocationRecord
+	scan := func(rows *sql.Rows) error {
+		var l schema.LocationRecord
+		err := rows.Scan(&l.LocationId, &l.StreetAddress, &l.PostalCode, &l.City, &l.StateProvince, &l.CountryId)
+		locations = append(locations, l)
+		return err
+	}
+	var err error
+	if locationIds == nil {
+		err = queryAll(ctx, db, "SELECT location_id, street_address, postal_code, city, state_province, country_id FROM locations ORDER BY location_id", nil, scan)
+	} else {
+		err = queryIn(ctx, db, "SELECT location_id, street_address, postal_code, city, state_province, country_id FROM locations WHERE location_id IN (%s)", locationIds, scan)
+	}
+	return locations, err
+}
+
+func QueryCountriesByIds(ctx context.Context, db *sql.DB, countryIds []string) ([]schema.CountryRecord, error) {
+	var countries []schema.CountryRecord
+	err := queryIn(ctx, db, "SELECT country_id, country_name, region_id FROM countries WHERE country_id IN (%s)", countryIds, func(rows *sql.Rows) error {
+		var c schema.CountryRecord
+		err := rows.Scan(&c.CountryId, &c.CountryName, &c.RegionId)
+		countries = append(countries, c)
+		return err
+	})
+	return countries, err
+}
+
+func QueryRegionsByIds(ctx context.Context, db *sql.DB, regionIds []int) ([]schema.Region, error) {
+	var regions []schema.Region
+	err := queryIn(ctx, db, "SELECT region_id, region_name FROM regions WHERE region_id IN (%s)", regionIds, func(rows *sql.Rows) error {
+		var r schema.Region
+		err := rows.Scan(&r.RegionId, &r.RegionName)
+		regions = append(regions, r)
+		return err
+	})
+	return regions, err
+}
+
+func queryAll(ctx context.Context, db *sql.DB, query string, args []interface{}, scan func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+	return nil
+}
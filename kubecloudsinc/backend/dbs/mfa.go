@@ -0,0 +1,271 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrMFANotFound is returned when a user has no second factor, or none awaiting
+// confirmation when enabling one.
+var ErrMFANotFound = errors.New("MFA is not set up for this user")
+
+// ErrMFAEnabled is returned when starting an enrollment for a user whose MFA is
+// already enabled.
+var ErrMFAEnabled = errors.New("MFA is already enabled")
+
+// ErrMFACodeUsed is returned for a TOTP code of a time step that was already used.
+var ErrMFACodeUsed = errors.New("MFA code was already used")
+
+// ErrRecoveryCodeInvalid is returned for unknown and used recovery codes.
+var ErrRecoveryCodeInvalid = errors.New("recovery code is invalid or was already used")
+
+// GetUserMFA reads the second factor of a user and counts its unused recovery codes.
+// The secret is returned as stored.
+func GetUserMFA(txn *newrelic.Transaction, db *sql.DB, userId int) (*schema.UserMFA, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "user_mfa",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	mfa := schema.UserMFA{UserId: userId}
+	query := `SELECT m.secret, m.enabled_at, m.last_step,
+              (SELECT COUNT(*) FROM user_recovery_codes c WHERE c.user_id = m.user_id AND c.used_at IS NULL)
+              FROM user_mfa m WHERE m.user_id = :1`
+	err := db.QueryRowContext(ctx, query, userId).Scan(&mfa.Secret, &mfa.EnabledAt, &mfa.LastStep, &mfa.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return nil, ErrMFANotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the MFA of user %d: %v", userId, err)
+	}
+	mfa.Enabled = mfa.EnabledAt != nil
+	return &mfa, nil
+}
+
+// SetMFASecret stores the secret of a new enrollment, replacing one that was not
+// confirmed. It fails with ErrMFAEnabled when the user's MFA is enabled.
+func SetMFASecret(txn *newrelic.Transaction, db *sql.DB, userId int, secret string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "user_mfa",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, `UPDATE user_mfa SET secret = :1, created_at = :2, last_step = 0 WHERE user_id = :3 AND enabled_at IS NULL`,
+		secret, now, userId)
+	if err != nil {
+		return fmt.Errorf("failed to store the MFA secret of user %d: %v", userId, err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected for user %d: %v", userId, err)
+	} else if rowsAffected > 0 {
+		return nil
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO user_mfa (user_id, secret, created_at) VALUES (:1, :2, :3)`, userId, secret, now)
+	if isUniqueViolation(err) {
+		return ErrMFAEnabled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store the MFA secret of user %d: %v", userId, err)
+	}
+	return nil
+}
+
+// EnableMFA confirms the enrollment of a user, accepting the code of step, and stores
+// the hashes of the user's recovery codes.
+func EnableMFA(txn *newrelic.Transaction, db *sql.DB, userId int, step int64, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "user_mfa",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE user_mfa SET enabled_at = :1, last_step = :2 WHERE user_id = :3 AND enabled_at IS NULL`,
+		time.Now().UTC(), step, userId)
+	if err != nil {
+		return fmt.Errorf("failed to enable the MFA of user %d: %v", userId, err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected for user %d: %v", userId, err)
+	} else if rowsAffected == 0 {
+		return ErrMFANotFound
+	}
+	if err := replaceRecoveryCodesTx(ctx, tx, userId, codeHashes); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// UseMFAStep records that the code of step was used. Codes of that step and earlier
+// ones fail with ErrMFACodeUsed from then on.
+func UseMFAStep(txn *newrelic.Transaction, db *sql.DB, userId int, step int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "user_mfa",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	result, err := db.ExecContext(ctx, `UPDATE user_mfa SET last_step = :1 WHERE user_id = :2 AND enabled_at IS NOT NULL AND last_step < :3`,
+		step, userId, step)
+	if err != nil {
+		return fmt.Errorf("failed to record the MFA code of user %d: %v", userId, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for user %d: %v", userId, err)
+	}
+	if rowsAffected == 0 {
+		return ErrMFACodeUsed
+	}
+	return nil
+}
+
+// UseRecoveryCode spends the recovery code of a user with SHA-256 hash codeHash.
+func UseRecoveryCode(txn *newrelic.Transaction, db *sql.DB, userId int, codeHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "user_recovery_codes",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	result, err := db.ExecContext(ctx, `UPDATE user_recovery_codes SET used_at = :1 WHERE code_hash = :2 AND user_id = :3 AND used_at IS NULL`,
+		time.Now().UTC(), codeHash, userId)
+	if err != nil {
+		return fmt.Errorf("failed to use a recovery code of user %d: %v", userId, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for user %d: %v", userId, err)
+	}
+	if rowsAffected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps every recovery code of a user for the codes with the
+// given hashes.
+func ReplaceRecoveryCodes(txn *newrelic.Transaction, db *sql.DB, userId int, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "user_recovery_codes",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodesTx(ctx, tx, userId, codeHashes); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodesTx(ctx context.Context, tx *sql.Tx, userId int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = :1`, userId); err != nil {
+		return fmt.Errorf("failed to delete the recovery codes of user %d: %v", userId, err)
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO user_recovery_codes (code_hash, user_id) VALUES (:1, :2)`, codeHash, userId); err != nil {
+			return fmt.Errorf("failed to store a recovery code of user %d: %v", userId, err)
+		}
+	}
+	return nil
+}
+
+// DeleteUserMFA removes the second factor and recovery codes of a user.
+func DeleteUserMFA(txn *newrelic.Transaction, db *sql.DB, userId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "user_mfa",
+		Operation:  "DELETE",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = :1`, userId); err != nil {
+		return fmt.Errorf("failed to delete the recovery codes of user %d: %v", userId, err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = :1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete the MFA of user %d: %v", userId, err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected for user %d: %v", userId, err)
+	} else if rowsAffected == 0 {
+		return ErrMFANotFound
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
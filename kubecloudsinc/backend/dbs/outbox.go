@@ -0,0 +1,283 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const employeeColumns = `employee_id, first_name, last_name, email, phone_number, hire_date, job_id, salary, commission_pct, manager_id, department_id`
+
+// selectEmployeeTx reads a single employee inside an open transaction. When forUpdate
+// is set the row stays locked until the transaction ends, so the before image of a
+// change event cannot be modified concurrently.
+func selectEmployeeTx(ctx context.Context, tx *sql.Tx, employeeId int, forUpdate bool) (*schema.Employee, error) {
+	query := "SELECT " + employeeColumns + " FROM employees WHERE employee_id = :1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	var emp schema.Employee
+	err := tx.QueryRowContext(ctx, query, employeeId).Scan(&emp.EmployeeId, &emp.FirstName, &emp.LastName, &emp.Email, &emp.Phone, &emp.HireDate, &emp.JobId, &emp.Salary, &emp.CommissionPct, &emp.ManagerId, &emp.DepartmentId)
+	if err == sql.ErrNoRows {
+		return nil, ErrEmployeeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read employee %d: %v", employeeId, err)
+	}
+	return &emp, nil
+}
+
+// recordEmployeeChange writes the audit entry and the change events for an employee
+// mutation into the outbox as part of the caller's transaction, so they only become
+// visible once the mutation itself commits. Moving an employee between departments
+// additionally emits a members-changed event for each affected department.
+func recordEmployeeChange(ctx context.Context, tx *sql.Tx, eventType string, employeeId int, before, after *schema.Employee, actor schema.Actor) error {
+	occurredAt := time.Now().UTC()
+	if err := insertAuditEntry(ctx, tx, eventType, employeeId, before, after, actor, occurredAt); err != nil {
+		return err
+	}
+	err := insertOutboxEvent(ctx, tx, schema.ChangeEvent{
+		Type:       eventType,
+		EmployeeId: employeeId,
+		Before:     before,
+		After:      after,
+		Actor:      actor,
+		OccurredAt: occurredAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	var oldDept, newDept *int
+	if before != nil {
+		oldDept = before.DepartmentId
+	}
+	if after != nil {
+		newDept = after.DepartmentId
+	}
+	if oldDept != nil && newDept != nil && *oldDept == *newDept {
+		return nil
+	}
+	for _, departmentId := range []*int{oldDept, newDept} {
+		if departmentId == nil {
+			continue
+		}
+		err := insertOutboxEvent(ctx, tx, schema.ChangeEvent{
+			Type:         schema.EventDepartmentMembersChanged,
+			EmployeeId:   employeeId,
+			DepartmentId: departmentId,
+			Actor:        actor,
+			OccurredAt:   occurredAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, event schema.ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode change event: %v", err)
+	}
+
+	query := `INSERT INTO employee_outbox (event_type, employee_id, actor, payload, created_at) VALUES (:1, :2, :3, :4, :5)`
+	if _, err := tx.ExecContext(ctx, query, event.Type, event.EmployeeId, event.Actor.Username, string(payload), event.OccurredAt); err != nil {
+		return fmt.Errorf("failed to write outbox event: %v", err)
+	}
+	return nil
+}
+
+// RelayOutbox locks the oldest batchSize unpublished events and hands them to publish
+// one at a time in event ID order. Published events are marked as such; the first
+// failure is recorded on its row and ends the batch, as the sink is most likely down.
+// SKIP LOCKED lets several replicas relay concurrently without double publishing.
+//
+// Delivery is at-least-once and not ordered across batches: identity values are
+// allocated before commit, so an event of a long transaction can be published after
+// events with higher IDs, and replicas publish their batches concurrently. An event
+// published before a failed commit is published again.
+// The batch is picked in an ordered subquery because ROWNUM in the locking query would
+// cut an arbitrary set of rows before sorting, and Oracle refuses FOR UPDATE on a
+// query with a row limit.
+func RelayOutbox(db *sql.DB, batchSize int, publish func(schema.ChangeEvent) error) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT event_id, payload FROM employee_outbox
+              WHERE event_id IN (
+                  SELECT event_id FROM (
+                      SELECT event_id FROM employee_outbox
+                      WHERE published_at IS NULL
+                      ORDER BY event_id
+                  ) WHERE ROWNUM <= :1
+              ) AND published_at IS NULL
+              ORDER BY event_id
+              FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %v", err)
+	}
+
+	var events []schema.ChangeEvent
+	for rows.Next() {
+		var eventId int64
+		var payload string
+		if err := rows.Scan(&eventId, &payload); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox row: %v", err)
+		}
+		var event schema.ChangeEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to decode outbox event %d: %v", eventId, err)
+		}
+		event.EventId = eventId
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating outbox rows: %v", err)
+	}
+
+	published := 0
+	for _, event := range events {
+		if pubErr := publish(event); pubErr != nil {
+			log.Printf("Failed to publish outbox event %d: %v", event.EventId, pubErr)
+			_, err := tx.ExecContext(ctx, `UPDATE employee_outbox SET attempts = attempts + 1, last_error = :1 WHERE event_id = :2`, truncate(pubErr.Error(), 4000), event.EventId)
+			if err != nil {
+				return published, fmt.Errorf("failed to record publish failure: %v", err)
+			}
+			break
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE employee_outbox SET published_at = :1, attempts = attempts + 1, last_error = NULL WHERE event_id = :2`, time.Now().UTC(), event.EventId)
+		if err != nil {
+			return published, fmt.Errorf("failed to mark outbox event %d as published: %v", event.EventId, err)
+		}
+		published++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return published, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return published, nil
+}
+
+// QueryOutboxEventsAfter returns committed events with an ID greater than afterId in
+// ID order, whether or not the relay has published them yet. Identity values are
+// allocated before commit, so an event of a transaction that is still open can appear
+// after higher IDs were read; callers track the IDs they skipped and read them again
+// with QueryOutboxEventsById. An afterId of zero returns the most recent limit events
+// instead.
+func QueryOutboxEventsAfter(db *sql.DB, afterId int64, limit int) ([]schema.ChangeEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var query string
+	if afterId > 0 {
+		query = `SELECT event_id, payload FROM (
+                     SELECT event_id, payload FROM employee_outbox
+                     WHERE event_id > :1
+                     ORDER BY event_id
+                 ) WHERE ROWNUM <= :2`
+	} else {
+		query = `SELECT event_id, payload FROM (
+                     SELECT event_id, payload FROM (
+                         SELECT event_id, payload FROM employee_outbox
+                         WHERE event_id > :1
+                         ORDER BY event_id DESC
+                     ) WHERE ROWNUM <= :2
+                 ) ORDER BY event_id`
+	}
+
+	rows, err := db.QueryContext(ctx, query, afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %v", err)
+	}
+	defer rows.Close()
+	return scanOutboxEvents(rows)
+}
+
+// maxInListBinds is the most expressions Oracle accepts in an IN list.
+const maxInListBinds = 1000
+
+// QueryOutboxEventsById returns the committed events among eventIds in ID order. IDs
+// without a committed event are left out.
+func QueryOutboxEventsById(db *sql.DB, eventIds []int64) ([]schema.ChangeEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var events []schema.ChangeEvent
+	for start := 0; start < len(eventIds); start += maxInListBinds {
+		chunk := eventIds[start:min(start+maxInListBinds, len(eventIds))]
+		placeholders := make([]string, len(chunk))
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			placeholders[i] = ":" + strconv.Itoa(i+1)
+			args[i] = id
+		}
+
+		query := `SELECT event_id, payload FROM employee_outbox WHERE event_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY event_id`
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outbox: %v", err)
+		}
+		found, err := scanOutboxEvents(rows)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, found...)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].EventId < events[j].EventId })
+	return events, nil
+}
+
+func scanOutboxEvents(rows *sql.Rows) ([]schema.ChangeEvent, error) {
+	var events []schema.ChangeEvent
+	for rows.Next() {
+		var eventId int64
+		var payload string
+		if err := rows.Scan(&eventId, &payload); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox row: %v", err)
+		}
+		var event schema.ChangeEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox event %d: %v", eventId, err)
+		}
+		event.EventId = eventId
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox rows: %v", err)
+	}
+	return events, nil
+}
+
+// truncate cuts s to at most max bytes, backing up to the start of a rune so the
+// result stays valid UTF-8.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,287 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrPendingChangeNotFound is returned when no pending change with the given ID
+// exists for the employee.
+var ErrPendingChangeNotFound = errors.New("pending change not found")
+
+// ErrPendingChangeClosed is returned when a change that was already applied,
+// cancelled or failed is cancelled again.
+var ErrPendingChangeClosed = errors.New("pending change is no longer pending")
+
+// ErrEffectiveDateInPast is returned when a change is scheduled before the database's
+// current date, the date ApplyDuePendingChanges compares with.
+var ErrEffectiveDateInPast = errors.New("effective date cannot be in the past")
+
+const pendingChangeColumns = `change_id, employee_id, TO_CHAR(effective_date, 'YYYY-MM-DD'), changes, status, created_by, created_role, created_at, cancelled_by, applied_at, last_error`
+
+// InsertPendingChange stores changes to be applied to the employee on effectiveDate
+// (YYYY-MM-DD).
+func InsertPendingChange(txn *newrelic.Transaction, db *sql.DB, employeeId int, effectiveDate string, changes schema.Employee, actor schema.Actor) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_pending_changes",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	if err := checkEmployeeExistence(db, employeeId, "", Scope{}); err != nil {
+		return 0, err
+	}
+
+	var inPast int
+	err := db.QueryRowContext(ctx, `SELECT CASE WHEN TO_DATE(:1, 'YYYY-MM-DD') < TRUNC(SYSDATE) THEN 1 ELSE 0 END FROM dual`, effectiveDate).Scan(&inPast)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check effective date: %v", err)
+	}
+	if inPast == 1 {
+		return 0, ErrEffectiveDateInPast
+	}
+
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode pending change: %v", err)
+	}
+
+	var changeId int
+	query := `INSERT INTO employee_pending_changes (employee_id, changes, effective_date, created_by, created_role)
+              VALUES (:1, :2, TO_DATE(:3, 'YYYY-MM-DD'), :4, :5) RETURNING change_id INTO :6`
+	_, err = db.ExecContext(ctx, query, employeeId, string(payload), effectiveDate, actor.Username, actor.Role, sql.Out{Dest: &changeId})
+	if err != nil {
+		log.Printf("Failed to insert pending change: %v", err)
+		return 0, fmt.Errorf("failed to insert pending change: %v", err)
+	}
+	log.Printf("Pending change %d scheduled for employee %d on %s", changeId, employeeId, effectiveDate)
+	return changeId, nil
+}
+
+// QueryPendingChanges lists an employee's scheduled changes by effective date,
+// optionally filtered by status.
+func QueryPendingChanges(txn *newrelic.Transaction, db *sql.DB, employeeId int, status string) ([]schema.PendingChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_pending_changes",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	if err := checkEmployeeExistence(db, employeeId, "", Scope{}); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + pendingChangeColumns + ` FROM employee_pending_changes
+              WHERE employee_id = :1 AND (:2 IS NULL OR status = :3)
+              ORDER BY effective_date, change_id`
+	var statusParam interface{}
+	if status != "" {
+		statusParam = status
+	}
+	rows, err := db.QueryContext(ctx, query, employeeId, statusParam, statusParam)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	changes := []schema.PendingChange{}
+	for rows.Next() {
+		change, err := scanPendingChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, *change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return changes, nil
+}
+
+func GetPendingChange(txn *newrelic.Transaction, db *sql.DB, employeeId, changeId int) (*schema.PendingChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_pending_changes",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	row := db.QueryRowContext(ctx, "SELECT "+pendingChangeColumns+" FROM employee_pending_changes WHERE change_id = :1 AND employee_id = :2", changeId, employeeId)
+	change, err := scanPendingChange(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPendingChangeNotFound
+	}
+	return change, err
+}
+
+// CancelPendingChange marks a pending change as cancelled. Changes that were already
+// applied, cancelled or failed are left alone and reported as ErrPendingChangeClosed.
+func CancelPendingChange(txn *newrelic.Transaction, db *sql.DB, employeeId, changeId int, actor schema.Actor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employee_pending_changes",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	result, err := db.ExecContext(ctx, `UPDATE employee_pending_changes SET status = 'cancelled', cancelled_by = :1
+              WHERE change_id = :2 AND employee_id = :3 AND status = 'pending'`, actor.Username, changeId, employeeId)
+	if err != nil {
+		return fmt.Errorf("failed to cancel pending change: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Pending change %d for employee %d cancelled by %s", changeId, employeeId, actor.Username)
+		return nil
+	}
+
+	// Nothing was updated: either the change does not exist or it is no longer pending
+	var status string
+	err = db.QueryRowContext(ctx, `SELECT status FROM employee_pending_changes WHERE change_id = :1 AND employee_id = :2`, changeId, employeeId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrPendingChangeNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read pending change: %v", err)
+	}
+	return fmt.Errorf("%w: change %d is %s", ErrPendingChangeClosed, changeId, status)
+}
+
+// ApplyDuePendingChanges applies up to batchSize pending changes whose effective date
+// has been reached, oldest first. Each change runs in its own transaction: the pending
+// row and the employee are locked, apply merges the changes into the current record,
+// and the write goes through the same path as UpdateEmployeeDB, so the job history
+// trigger and change events behave exactly as for a live update. The submitter is
+// recorded as the actor. A change that cannot be applied is marked failed and does not
+// hold up the others.
+func ApplyDuePendingChanges(db *sql.DB, batchSize int, apply func(before, changes schema.Employee) (Employees, error)) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `SELECT change_id FROM employee_pending_changes
+              WHERE status = 'pending' AND effective_date <= TRUNC(SYSDATE)
+              ORDER BY effective_date, change_id
+              FETCH FIRST :1 ROWS ONLY`, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read due pending changes: %v", err)
+	}
+	var changeIds []int
+	for rows.Next() {
+		var changeId int
+		if err := rows.Scan(&changeId); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan pending change: %v", err)
+		}
+		changeIds = append(changeIds, changeId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating pending changes: %v", err)
+	}
+
+	applied := 0
+	for _, changeId := range changeIds {
+		ok, applyErr := applyPendingChange(ctx, db, changeId, apply)
+		if applyErr != nil {
+			log.Printf("Pending change %d failed: %v", changeId, applyErr)
+			_, err := db.ExecContext(ctx, `UPDATE employee_pending_changes SET status = 'failed', last_error = :1 WHERE change_id = :2 AND status = 'pending'`,
+				truncate(applyErr.Error(), 4000), changeId)
+			if err != nil {
+				return applied, fmt.Errorf("failed to mark pending change %d as failed: %v", changeId, err)
+			}
+			continue
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// applyPendingChange applies a single due change. It reports false without an error
+// when another instance has already taken or finished the change.
+func applyPendingChange(ctx context.Context, db *sql.DB, changeId int, apply func(before, changes schema.Employee) (Employees, error)) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, "SELECT "+pendingChangeColumns+` FROM employee_pending_changes
+              WHERE change_id = :1 AND status = 'pending'
+              FOR UPDATE SKIP LOCKED`, changeId)
+	change, err := scanPendingChange(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	before, err := selectEmployeeTx(ctx, tx, change.EmployeeId, true)
+	if err != nil {
+		return false, err
+	}
+	emp, err := apply(*before, change.Changes)
+	if err != nil {
+		return false, err
+	}
+	actor := change.CreatedBy
+	actor.Endpoint = fmt.Sprintf("scheduler: pending change %d", changeId)
+	if _, err := updateEmployeeTx(ctx, tx, change.EmployeeId, emp, before, actor); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE employee_pending_changes SET status = 'applied', applied_at = SYSTIMESTAMP, last_error = NULL WHERE change_id = :1`, changeId); err != nil {
+		return false, fmt.Errorf("failed to mark pending change as applied: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	log.Printf("Pending change %d applied to employee %d", changeId, change.EmployeeId)
+	return true, nil
+}
+
+func scanPendingChange(row interface{ Scan(...interface{}) error }) (*schema.PendingChange, error) {
+	var change schema.PendingChange
+	var payload string
+	var createdBy, createdRole sql.NullString
+	err := row.Scan(&change.ChangeId, &change.EmployeeId, &change.EffectiveDate, &payload, &change.Status, &createdBy, &createdRole,
+		&change.CreatedAt, &change.CancelledBy, &change.AppliedAt, &change.LastError)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan pending change: %v", err)
+	}
+	if err := json.Unmarshal([]byte(payload), &change.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode pending change %d: %v", change.ChangeId, err)
+	}
+	change.CreatedBy = schema.Actor{Username: createdBy.String, Role: createdRole.String}
+	return &change, nil
+}
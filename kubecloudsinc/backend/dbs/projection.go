@@ -0,0 +1,496 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// Field projection (?fields=) and relation expansion (?expand=) for employee reads.
+// Only the selected columns are read and only the expanded relations are joined, so
+// a names-only list view costs a single-table scan of three columns.
+
+// Record is a JSON object that keeps its keys in the order they were selected.
+type Record []Field
+
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func (r Record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type columnKind int
+
+const (
+	intColumn columnKind = iota
+	floatColumn
+	stringColumn
+)
+
+type column struct {
+	key  string
+	expr string
+	kind columnKind
+}
+
+// relation is an expandable to-one join. The first column is the related key; a NULL
+// there means there is no related row and the relation is rendered as null.
+type relation struct {
+	key      string
+	join     string
+	columns  []column
+	children []*relation
+}
+
+var employeeFieldColumns = []column{
+	{"employeeId", "e.employee_id", intColumn},
+	{"firstName", "e.first_name", stringColumn},
+	{"lastName", "e.last_name", stringColumn},
+	{"email", "e.email", stringColumn},
+	{"phone", "e.phone_number", stringColumn},
+	{"hireDate", "e.hire_date", stringColumn},
+	{"jobId", "e.job_id", stringColumn},
+	{"salary", "e.salary", floatColumn},
+	{"commissionPct", "e.commission_pct", floatColumn},
+	{"managerId", "e.manager_id", intColumn},
+	{"departmentId", "e.department_id", intColumn},
+}
+
+var profileFieldColumns = []column{
+	{"employeeId", "e.employee_id", intColumn},
+	{"firstName", "e.first_name", stringColumn},
+	{"lastName", "e.last_name", stringColumn},
+	{"email", "e.email", stringColumn},
+	{"phone", "e.phone_number", stringColumn},
+	{"salary", "e.salary", floatColumn},
+	{"commissionPct", "e.commission_pct", floatColumn},
+	{"managerId", "e.manager_id", intColumn},
+}
+
+var managerRelation = &relation{
+	key:  "manager",
+	join: "LEFT JOIN employees m ON m.employee_id = e.manager_id",
+	columns: []column{
+		{"managerId", "m.employee_id", intColumn},
+		{"managerFirst", "m.first_name", stringColumn},
+		{"managerLast", "m.last_name", stringColumn},
+	},
+}
+
+var jobRelation = &relation{
+	key:  "job",
+	join: "LEFT JOIN jobs j ON j.job_id = e.job_id",
+	columns: []column{
+		{"jobId", "j.job_id", stringColumn},
+		{"jobTitle", "j.job_title", stringColumn},
+		{"minSalary", "j.min_salary", floatColumn},
+		{"maxSalary", "j.max_salary", floatColumn},
+	},
+}
+
+var departmentRelation = &relation{
+	key:  "department",
+	join: "LEFT JOIN departments d ON d.department_id = e.department_id",
+	columns: []column{
+		{"departmentId", "d.department_id", intColumn},
+		{"departmentName", "d.department_name", stringColumn},
+	},
+	children: []*relation{{
+		key:  "location",
+		join: "LEFT JOIN locations l ON l.location_id = d.location_id",
+		columns: []column{
+			{"locationId", "l.location_id", intColumn},
+			{"streetAddress", "l.street_address", stringColumn},
+			{"postalCode", "l.postal_code", stringColumn},
+			{"city", "l.city", stringColumn},
+			{"stateProvince", "l.state_province", stringColumn},
+		},
+		children: []*relation{{
+			key:  "country",
+			join: "LEFT JOIN countries c ON c.country_id = l.country_id",
+			columns: []column{
+				{"countryId", "c.country_id", stringColumn},
+				{"countryName", "c.country_name", stringColumn},
+			},
+			children: []*relation{{
+				key:  "region",
+				join: "LEFT JOIN regions r ON r.region_id = c.region_id",
+				columns: []column{
+					{"regionId", "r.region_id", intColumn},
+					{"regionName", "r.region_name", stringColumn},
+				},
+			}},
+		}},
+	}},
+}
+
+// profileJobsExpansion is the one-to-many part of the profile, the current job with
+// the employee's job history. It is read with separate queries.
+const profileJobsExpansion = "jobs"
+
+// Projection selects the fields and relations returned by an employee read.
+type Projection struct {
+	columns   []column
+	relations []*relation
+	expand    map[string]bool
+}
+
+// NewEmployeeProjection validates fields and expand paths for employee lists. Empty
+// fields selects every field; expanding a nested path such as
+// "department.location.country" also expands its parents.
+func NewEmployeeProjection(fields, expand []string) (Projection, error) {
+	return newProjection(fields, expand, employeeFieldColumns, []*relation{managerRelation, jobRelation, departmentRelation}, nil)
+}
+
+// NewProfileProjection validates fields and expand paths for the employee profile.
+// Expanded relations are returned under job_details, as in the full profile.
+func NewProfileProjection(fields, expand []string) (Projection, error) {
+	return newProjection(fields, expand, profileFieldColumns, []*relation{managerRelation, departmentRelation}, []string{profileJobsExpansion})
+}
+
+func newProjection(fields, expand []string, columns []column, relations []*relation, extra []string) (Projection, error) {
+	p := Projection{relations: relations, expand: make(map[string]bool)}
+
+	if len(fields) == 0 {
+		p.columns = columns
+	}
+	selected := make(map[string]bool)
+	for _, name := range fields {
+		if selected[name] {
+			continue
+		}
+		c, ok := findColumn(columns, name)
+		if !ok {
+			available := make([]string, len(columns))
+			for i, c := range columns {
+				available[i] = c.key
+			}
+			return Projection{}, fmt.Errorf("unknown field %q; available fields: %s", name, strings.Join(available, ", "))
+		}
+		selected[name] = true
+		p.columns = append(p.columns, c)
+	}
+
+	valid := make(map[string]bool)
+	for _, name := range extra {
+		valid[name] = true
+	}
+	for _, rel := range relations {
+		collectPaths(rel, "", valid)
+	}
+	for _, path := range expand {
+		if !valid[path] {
+			available := make([]string, 0, len(valid))
+			for name := range valid {
+				available = append(available, name)
+			}
+			sort.Strings(available)
+			return Projection{}, fmt.Errorf("unknown expansion %q; available expansions: %s", path, strings.Join(available, ", "))
+		}
+		parts := strings.Split(path, ".")
+		for i := range parts {
+			p.expand[strings.Join(parts[:i+1], ".")] = true
+		}
+	}
+	return p, nil
+}
+
+func findColumn(columns []column, key string) (column, bool) {
+	for _, c := range columns {
+		if c.key == key {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+func collectPaths(rel *relation, prefix string, paths map[string]bool) {
+	path := prefix + rel.key
+	paths[path] = true
+	for _, child := range rel.children {
+		collectPaths(child, path+".", paths)
+	}
+}
+
+// queryPlan is the select list and joins for a projection, with the scan targets
+// and the functions that turn one scanned row back into JSON.
+type queryPlan struct {
+	exprs     []string
+	joins     []string
+	dests     []interface{}
+	fields    func() Record
+	relations func() Record
+}
+
+func (p Projection) plan() *queryPlan {
+	qp := &queryPlan{}
+	columnDests := qp.addColumns(p.columns)
+	var relationFields []func() Field
+	for _, rel := range p.relations {
+		if f := qp.addRelation(rel, "", p.expand); f != nil {
+			relationFields = append(relationFields, f)
+		}
+	}
+	qp.fields = func() Record {
+		return recordOf(p.columns, columnDests)
+	}
+	qp.relations = func() Record {
+		var record Record
+		for _, f := range relationFields {
+			record = append(record, f())
+		}
+		return record
+	}
+	return qp
+}
+
+func (qp *queryPlan) addColumns(columns []column) []interface{} {
+	dests := make([]interface{}, len(columns))
+	for i, c := range columns {
+		switch c.kind {
+		case intColumn:
+			dests[i] = &sql.NullInt64{}
+		case floatColumn:
+			dests[i] = &sql.NullFloat64{}
+		default:
+			dests[i] = &sql.NullString{}
+		}
+		qp.exprs = append(qp.exprs, c.expr)
+	}
+	qp.dests = append(qp.dests, dests...)
+	return dests
+}
+
+func (qp *queryPlan) addRelation(rel *relation, prefix string, expand map[string]bool) func() Field {
+	path := prefix + rel.key
+	if !expand[path] {
+		return nil
+	}
+	qp.joins = append(qp.joins, rel.join)
+	dests := qp.addColumns(rel.columns)
+	var children []func() Field
+	for _, child := range rel.children {
+		if f := qp.addRelation(child, path+".", expand); f != nil {
+			children = append(children, f)
+		}
+	}
+	return func() Field {
+		if nullValue(dests[0]) == nil {
+			return Field{rel.key, nil}
+		}
+		record := recordOf(rel.columns, dests)
+		for _, f := range children {
+			record = append(record, f())
+		}
+		return Field{rel.key, record}
+	}
+}
+
+func recordOf(columns []column, dests []interface{}) Record {
+	record := make(Record, len(columns))
+	for i, c := range columns {
+		record[i] = Field{c.key, nullValue(dests[i])}
+	}
+	return record
+}
+
+func nullValue(dest interface{}) interface{} {
+	switch v := dest.(type) {
+	case *sql.NullInt64:
+		if v.Valid {
+			return v.Int64
+		}
+	case *sql.NullFloat64:
+		if v.Valid {
+			return v.Float64
+		}
+	case *sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+	}
+	return nil
+}
+
+// QueryEmployeesProjected lists employees in scope, or finds them by ID and/or last
+// name like QueryEmployee, returning only the projected fields and expansions.
+func QueryEmployeesProjected(txn *newrelic.Transaction, db *sql.DB, employeeId int, lastName string, scope Scope, p Projection) ([]Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employees",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	if employeeId > 0 || lastName != "" {
+		if err := checkEmployeeExistence(db, employeeId, lastName, scope); err != nil {
+			return nil, err
+		}
+	}
+
+	qp := p.plan()
+	query := "SELECT " + strings.Join(qp.exprs, ", ") + " FROM employees e"
+	if len(qp.joins) > 0 {
+		query += " " + strings.Join(qp.joins, " ")
+	}
+	var conditions []string
+	var args []interface{}
+	if employeeId > 0 {
+		args = append(args, employeeId)
+		conditions = append(conditions, fmt.Sprintf("e.employee_id = :%d", len(args)))
+	}
+	if lastName != "" {
+		args = append(args, "%"+lastName+"%")
+		conditions = append(conditions, fmt.Sprintf("e.last_name LIKE :%d", len(args)))
+	}
+	if predicate, scopeArgs := scope.predicate("e.employee_id", len(args)); predicate != "" {
+		conditions = append(conditions, predicate)
+		args = append(args, scopeArgs...)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY e.employee_id"
+
+	log.Println("Projected Query:", query)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("Query failed: %v", err)
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	records := []Record{}
+	for rows.Next() {
+		if err := rows.Scan(qp.dests...); err != nil {
+			log.Printf("Error scanning row: %v", err)
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		records = append(records, append(qp.fields(), qp.relations()...))
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("Error iterating rows: %v", err)
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return records, nil
+}
+
+// GetEmployeeProfileProjected reads the projected profile fields and only the
+// expanded parts of job_details.
+func GetEmployeeProfileProjected(txn *newrelic.Transaction, db *sql.DB, employeeId int, p Projection) (Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employees",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	// The field list is never empty: no ?fields= selects every field
+	qp := p.plan()
+	query := "SELECT " + strings.Join(qp.exprs, ", ") + " FROM employees e"
+	if len(qp.joins) > 0 {
+		query += " " + strings.Join(qp.joins, " ")
+	}
+	query += " WHERE e.employee_id = :1"
+
+	log.Println("Projected Query:", query)
+	err := db.QueryRowContext(ctx, query, employeeId).Scan(qp.dests...)
+	if err == sql.ErrNoRows {
+		return nil, ErrEmployeeNotFound
+	}
+	if err != nil {
+		log.Printf("Query failed: %v", err)
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	record := qp.fields()
+	jobDetails := qp.relations()
+
+	if p.expand[profileJobsExpansion] {
+		jobs, err := queryProfileJobs(ctx, db, employeeId)
+		if err != nil {
+			return nil, err
+		}
+		jobDetails = append(Record{{"jobs", jobs}}, jobDetails...)
+	}
+	if len(p.expand) > 0 {
+		record = append(record, Field{"job_details", jobDetails})
+	}
+	return record, nil
+}
+
+// queryProfileJobs returns the current job with the employee's job history, in the
+// shape of schema.JobDetails.Jobs.
+func queryProfileJobs(ctx context.Context, db *sql.DB, employeeId int) ([]*schema.Job, error) {
+	job := &schema.Job{JobHistory: []*schema.JobHistory{}}
+	err := db.QueryRowContext(ctx, `SELECT e.job_id, j.job_title, e.hire_date, e.salary, e.department_id, d.department_name
+		FROM employees e
+		LEFT JOIN jobs j ON j.job_id = e.job_id
+		LEFT JOIN departments d ON d.department_id = e.department_id
+		WHERE e.employee_id = :1`, employeeId).Scan(&job.JobId, &job.JobTitle, &job.HireDate, &job.Salary, &job.DepartmentId, &job.DepartmentName)
+	if err == sql.ErrNoRows {
+		return nil, ErrEmployeeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current job: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT jh.job_id, j.job_title, jh.start_date, jh.end_date
+		FROM job_history jh
+		LEFT JOIN jobs j ON j.job_id = jh.job_id
+		WHERE jh.employee_id = :1
+		ORDER BY jh.start_date`, employeeId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job history: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h schema.JobHistory
+		if err := rows.Scan(&h.JobId, &h.JobTitle, &h.StartDate, &h.EndDate); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		job.JobHistory = append(job.JobHistory, &h)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return []*schema.Job{job}, nil
+}
@@ -20,6 +20,9 @@ var (
 	DB *sql.DB
 )
 
+// ErrEmployeeNotFound is returned when the requested employee does not exist.
+var ErrEmployeeNotFound = errors.New("employee not found")
+
 type Employees schema.Employee
 type EmployeeProfile schema.EmployeeProfile
 
@@ -46,7 +49,8 @@ func InitDB(dsn string) error {
 	return nil
 }
 
-func QueryEmployees(txn *newrelic.Transaction, db *sql.DB) ([]Employees, error) {
+// QueryEmployees returns every employee in scope.
+func QueryEmployees(txn *newrelic.Transaction, db *sql.DB, scope Scope) ([]Employees, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
@@ -60,17 +64,19 @@ func QueryEmployees(txn *newrelic.Transaction, db *sql.DB) ([]Employees, error)
 
 	log.Println("Making a DB call to get all employees")
 	// Define the SQL query
-	query := `SELECT employee_id, first_name, last_name, email, phone_number, hire_date, job_id, salary, commission_pct, manager_id, department_id FROM employees`
+	query := `SELECT employee_id, first_name, last_name, email, phone_number, hire_date, job_id, salary, commission_pct, manager_id, department_id FROM employees WHERE 1=1`
+	condition, args := scope.condition("employee_id", 0)
+	query += condition
 
 	// Execute the query
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %v", err)
 	}
 	defer rows.Close()
 
 	// Iterate over the rows and scan the results into the Employee struct
-	var employees []Employees
+	employees := []Employees{}
 	for rows.Next() {
 		var emp Employees
 		// Assuming commission_pct can be null, it's handled as sql.NullFloat64
@@ -93,7 +99,7 @@ func QueryEmployees(txn *newrelic.Transaction, db *sql.DB) ([]Employees, error)
 	return employees, nil
 }
 
-func QueryEmployee(txn *newrelic.Transaction, db *sql.DB, employeeId int, lastName string) ([]Employees, error) {
+func QueryEmployee(txn *newrelic.Transaction, db *sql.DB, employeeId int, lastName string, scope Scope) ([]Employees, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
@@ -108,7 +114,7 @@ func QueryEmployee(txn *newrelic.Transaction, db *sql.DB, employeeId int, lastNa
 	log.Println("Making a DB call to get employee")
 
 	// First, check if the employee exists
-	err := checkEmployeeExistence(db, employeeId, lastName)
+	err := checkEmployeeExistence(db, employeeId, lastName, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +136,12 @@ func QueryEmployee(txn *newrelic.Transaction, db *sql.DB, employeeId int, lastNa
 		queryParams = append(queryParams, "%"+lastName+"%")
 	}
 
+	// Employees outside the caller's scope are never matched; :1 and :2 are taken
+	if predicate, args := scope.predicate("employee_id", 2); predicate != "" {
+		conditions = append(conditions, predicate)
+		queryParams = append(queryParams, args...)
+	}
+
 	// If there are conditions, append them to the baseQuery
 	if len(conditions) > 0 {
 		baseQuery += " WHERE " + strings.Join(conditions, " AND ")
@@ -151,7 +163,7 @@ func QueryEmployee(txn *newrelic.Transaction, db *sql.DB, employeeId int, lastNa
 	}
 	defer rows.Close()
 
-	var employees []Employees
+	employees := []Employees{}
 	for rows.Next() {
 		var emp Employees
 		err := rows.Scan(&emp.EmployeeId, &emp.FirstName, &emp.LastName, &emp.Email, &emp.Phone, &emp.HireDate, &emp.JobId, &emp.Salary, &emp.CommissionPct, &emp.ManagerId, &emp.DepartmentId)
@@ -170,7 +182,7 @@ func QueryEmployee(txn *newrelic.Transaction, db *sql.DB, employeeId int, lastNa
 	return employees, nil
 }
 
-func InsertEmployee(txn *newrelic.Transaction, db *sql.DB, emp Employees) (int, error) {
+func InsertEmployee(txn *newrelic.Transaction, db *sql.DB, emp Employees, actor schema.Actor) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
@@ -204,19 +216,43 @@ func InsertEmployee(txn *newrelic.Transaction, db *sql.DB, emp Employees) (int,
 		sql.Out{Dest: &returnedEmployeeId}, // For capturing the RETURNING value
 	}
 
-	if _, err := db.ExecContext(ctx, query, args...); err != nil {
+	if emp.EmployeeId == nil {
+		return 0, errors.New("failed to insert employee")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
 		log.Printf("Failed to insert employee: %v", err)
 		return 0, fmt.Errorf("failed to insert employee: %v", err)
 	}
 
-	if emp.EmployeeId != nil {
-		return *emp.EmployeeId, nil
+	after, err := selectEmployeeTx(ctx, tx, *emp.EmployeeId, false)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordEmployeeChange(ctx, tx, schema.EventEmployeeCreated, *emp.EmployeeId, nil, after, actor); err != nil {
+		log.Printf("Failed to record change event: %v", err)
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	return 0, errors.New("failed to insert employee")
+	return *emp.EmployeeId, nil
 }
 
-func UpdateEmployeeDB(txn *newrelic.Transaction, db *sql.DB, employeeId int, emp Employees) error {
+// UpdateEmployeeDB replaces the stored employee with emp. check judges the update
+// against the locked before image; when it returns false or an error, nothing is
+// written and that error is returned.
+func UpdateEmployeeDB(txn *newrelic.Transaction, db *sql.DB, employeeId int, emp Employees, actor schema.Actor, check func(before schema.Employee) (bool, error)) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
@@ -230,11 +266,43 @@ func UpdateEmployeeDB(txn *newrelic.Transaction, db *sql.DB, employeeId int, emp
 
 	log.Printf("Making a DB call to update employeeId: %d", employeeId)
 	// First, check if the employee exists
-	err := checkEmployeeExistence(db, employeeId, "")
+	err := checkEmployeeExistence(db, employeeId, "", Scope{})
 	if err != nil {
 		return err
 	}
 
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	before, err := selectEmployeeTx(ctx, tx, employeeId, true)
+	if err != nil {
+		return err
+	}
+	if ok, err := check(*before); !ok || err != nil {
+		return err
+	}
+
+	if _, err := updateEmployeeTx(ctx, tx, employeeId, emp, before, actor); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Employee with ID %d updated successfully", employeeId)
+	return nil
+}
+
+// updateEmployeeTx replaces every column of a locked employee row with emp inside tx,
+// except hire_date which is only written when set, and records the change event
+// against the before image. It returns the after image.
+func updateEmployeeTx(ctx context.Context, tx *sql.Tx, employeeId int, emp Employees, before *schema.Employee, actor schema.Actor) (*schema.Employee, error) {
 	// Initialize the base query and argument counter
 	query := "UPDATE employees SET "
 	var args []interface{}
@@ -257,11 +325,14 @@ func UpdateEmployeeDB(txn *newrelic.Transaction, db *sql.DB, employeeId int, emp
 	addUpdate(emp.Email, "email")
 	addUpdate(emp.Phone, "phone_number")
 	if emp.HireDate != nil && *emp.HireDate != "" {
-		layout := "2006-01-02 15:04:05"
-		hireDate, err := time.Parse(layout, *emp.HireDate)
+		hireDate, err := time.Parse("2006-01-02 15:04:05", *emp.HireDate)
+		if err != nil {
+			// Dates without a time are accepted by validation as well
+			hireDate, err = time.Parse("2006-01-02", *emp.HireDate)
+		}
 		if err != nil {
 			log.Printf("Error parsing hire date '%s': %v", *emp.HireDate, err)
-			return fmt.Errorf("error parsing hire date: %v", err)
+			return nil, fmt.Errorf("error parsing hire date: %v", err)
 		}
 		addUpdate(hireDate, "hire_date")
 	}
@@ -273,7 +344,7 @@ func UpdateEmployeeDB(txn *newrelic.Transaction, db *sql.DB, employeeId int, emp
 
 	// If no fields were updated, return an error
 	if len(updates) == 0 {
-		return errors.New("no fields provided for update")
+		return nil, errors.New("no fields provided for update")
 	}
 
 	// Finalize the query by appending the WHERE clause
@@ -284,30 +355,38 @@ func UpdateEmployeeDB(txn *newrelic.Transaction, db *sql.DB, employeeId int, emp
 	// Debugging: Log the final query and parameters
 	debugQuery := DebugQuery(query, args)
 	log.Println("Debug Query Update:", debugQuery)
+
 	// Execute the update
-	result, err := db.ExecContext(ctx, query, args...)
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		log.Printf("Failed to update employee: %v", err)
-		return fmt.Errorf("failed to update employee: %v", err)
+		return nil, fmt.Errorf("failed to update employee: %v", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		log.Printf("Error getting rows affected: %v", err)
-		return fmt.Errorf("error getting rows affected: %v", err)
+		return nil, fmt.Errorf("error getting rows affected: %v", err)
 	}
 
 	if rowsAffected == 0 {
 		log.Printf("No rows updated, employee with ID %d may not exist or no new data provided", employeeId)
 		// Instead of returning an error, you can return a specific message indicating success but no update was needed.
-		return fmt.Errorf("no update needed or employee with ID %d not found", employeeId)
+		return nil, fmt.Errorf("no update needed or employee with ID %d not found", employeeId)
 	}
 
-	log.Printf("Employee with ID %d updated successfully, %d rows affected", employeeId, rowsAffected)
-	return nil
+	after, err := selectEmployeeTx(ctx, tx, employeeId, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordEmployeeChange(ctx, tx, schema.EventEmployeeUpdated, employeeId, before, after, actor); err != nil {
+		log.Printf("Failed to record change event: %v", err)
+		return nil, err
+	}
+	return after, nil
 }
 
-func DeleteEmployeeByID(txn *newrelic.Transaction, db *sql.DB, employeeId int) error {
+func DeleteEmployeeByID(txn *newrelic.Transaction, db *sql.DB, employeeId int, actor schema.Actor) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
@@ -320,7 +399,7 @@ func DeleteEmployeeByID(txn *newrelic.Transaction, db *sql.DB, employeeId int) e
 	defer segment.End()
 
 	// First, check if the employee exists
-	err := checkEmployeeExistence(db, employeeId, "")
+	err := checkEmployeeExistence(db, employeeId, "", Scope{})
 	if err != nil {
 		return err
 	}
@@ -332,6 +411,14 @@ func DeleteEmployeeByID(txn *newrelic.Transaction, db *sql.DB, employeeId int) e
 		return fmt.Errorf("failed to start transaction: %v", err)
 	}
 
+	before, err := selectEmployeeTx(ctx, tx, employeeId, true)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+		return err
+	}
+
 	_, err = tx.ExecContext(ctx, "DELETE FROM employees WHERE employee_id = :1", employeeId)
 	if err != nil {
 		rbErr := tx.Rollback()
@@ -342,6 +429,14 @@ func DeleteEmployeeByID(txn *newrelic.Transaction, db *sql.DB, employeeId int) e
 		return fmt.Errorf("failed to delete employee: %v", err)
 	}
 
+	if err = recordEmployeeChange(ctx, tx, schema.EventEmployeeDeleted, employeeId, before, nil, actor); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			log.Printf("Failed to rollback transaction: %v", rbErr)
+		}
+		log.Printf("Failed to record change event: %v", err)
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		log.Printf("Failed to commit transaction: %v", err)
 		return fmt.Errorf("failed to commit transaction: %v", err)
@@ -366,7 +461,7 @@ func GetEmployeeProfile(txn *newrelic.Transaction, db *sql.DB, employeeId int) (
 	log.Printf("Making a DB call to fetch employee profile with ID: %d", employeeId)
 
 	// First, check if the employee exists
-	err := checkEmployeeExistence(db, employeeId, "")
+	err := checkEmployeeExistence(db, employeeId, "", Scope{})
 	if err != nil {
 		return nil, err
 	}
@@ -577,7 +672,7 @@ WHERE
 	return employeeProfile, nil
 }
 
-func checkEmployeeExistence(db *sql.DB, employeeId int, lastName string) error {
+func checkEmployeeExistence(db *sql.DB, employeeId int, lastName string, scope Scope) error {
 	// Initialize the SQL query string and parameters slice
 	query := "SELECT COUNT(employee_id) FROM employees WHERE 1=1"
 	var params []interface{}
@@ -591,6 +686,9 @@ func checkEmployeeExistence(db *sql.DB, employeeId int, lastName string) error {
 		query += " AND last_name = :2"
 		params = append(params, lastName)
 	}
+	condition, args := scope.condition("employee_id", 2)
+	query += condition
+	params = append(params, args...)
 
 	// Execute the query
 	var count int
@@ -600,16 +698,24 @@ func checkEmployeeExistence(db *sql.DB, employeeId int, lastName string) error {
 		return fmt.Errorf("error checking employee existence: %v", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("employee not found")
+		return ErrEmployeeNotFound
 	}
 	return nil
 }
 
 func PrintEmployees(employees []Employees) {
 	for _, emp := range employees {
-		fmt.Printf("ID: %d, Name: %s %s, Email: %s, Phone: %s, Hire Date: %s, Job ID: %s, Salary: %.2f, Commission Pct: %v, Manager ID: %d, Department ID: %d\n",
-			emp.EmployeeId, emp.FirstName, emp.LastName, emp.Email, emp.Phone, emp.HireDate, emp.JobId, emp.Salary, emp.CommissionPct, emp.ManagerId, emp.DepartmentId)
+		fmt.Printf("ID: %v, Name: %v %v, Email: %v, Phone: %v, Hire Date: %v, Job ID: %v, Salary: %v, Commission Pct: %v, Manager ID: %v, Department ID: %v\n",
+			deref(emp.EmployeeId), deref(emp.FirstName), deref(emp.LastName), deref(emp.Email), deref(emp.Phone), deref(emp.HireDate), deref(emp.JobId), deref(emp.Salary), deref(emp.CommissionPct), deref(emp.ManagerId), deref(emp.DepartmentId))
+	}
+}
+
+// deref returns the value behind p, or nil when p is nil.
+func deref[T any](p *T) interface{} {
+	if p == nil {
+		return nil
 	}
+	return *p
 }
 
 func DebugQuery(query string, params []interface{}) string {
@@ -0,0 +1,139 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// Scope limits the employees a query reaches. The zero Scope reaches every employee.
+// A Scope with a Root reaches that employee and everyone reporting to them, directly
+// or indirectly; with ReportsOnly the root employee is left out.
+type Scope struct {
+	Root        int
+	ReportsOnly bool
+}
+
+// IsZero reports whether the scope reaches every employee.
+func (s Scope) IsZero() bool {
+	return s.Root == 0
+}
+
+// tree returns a subquery selecting the employee IDs in the scope, binding Root as
+// :n. NOCYCLE keeps a reporting loop in the data from failing the query.
+func (s Scope) tree(n int) string {
+	start := "employee_id"
+	if s.ReportsOnly {
+		start = "manager_id"
+	}
+	return fmt.Sprintf("SELECT employee_id FROM employees START WITH %s = :%d CONNECT BY NOCYCLE PRIOR employee_id = manager_id", start, n)
+}
+
+// predicate returns "column IN (...)" limiting column to the scope and its bind value,
+// numbered after the bound variables already in the query. It is empty for the zero
+// Scope.
+func (s Scope) predicate(column string, bound int) (string, []interface{}) {
+	if s.IsZero() {
+		return "", nil
+	}
+	return fmt.Sprintf("%s IN (%s)", column, s.tree(bound+1)), []interface{}{s.Root}
+}
+
+// condition is predicate as an " AND ..." suffix for a WHERE clause.
+func (s Scope) condition(column string, bound int) (string, []interface{}) {
+	predicate, args := s.predicate(column, bound)
+	if predicate == "" {
+		return "", nil
+	}
+	return " AND " + predicate, args
+}
+
+// membersTx returns the IDs in the scope, or nil for the zero Scope.
+func (s Scope) membersTx(ctx context.Context, tx *sql.Tx) (map[int]bool, error) {
+	if s.IsZero() {
+		return nil, nil
+	}
+	rows, err := tx.QueryContext(ctx, s.tree(1), s.Root)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	return scanMembers(rows)
+}
+
+// QueryScopeMembers returns the IDs of the employees in scope, or nil for the zero
+// Scope, which reaches every employee.
+func QueryScopeMembers(txn *newrelic.Transaction, db *sql.DB, scope Scope) (map[int]bool, error) {
+	if scope.IsZero() {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employees",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	rows, err := db.QueryContext(ctx, scope.tree(1), scope.Root)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	return scanMembers(rows)
+}
+
+func scanMembers(rows *sql.Rows) (map[int]bool, error) {
+	defer rows.Close()
+	members := make(map[int]bool)
+	for rows.Next() {
+		var employeeId int
+		if err := rows.Scan(&employeeId); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		members[employeeId] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return members, nil
+}
+
+// GetEmployee reads one employee. Employees outside scope are reported as
+// ErrEmployeeNotFound, so callers cannot tell them apart from missing ones.
+func GetEmployee(txn *newrelic.Transaction, db *sql.DB, employeeId int, scope Scope) (*schema.Employee, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "employees",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	condition, args := scope.condition("employee_id", 1)
+	query := "SELECT " + employeeColumns + " FROM employees WHERE employee_id = :1" + condition
+	var emp schema.Employee
+	err := db.QueryRowContext(ctx, query, append([]interface{}{employeeId}, args...)...).Scan(&emp.EmployeeId, &emp.FirstName, &emp.LastName, &emp.Email, &emp.Phone, &emp.HireDate, &emp.JobId, &emp.Salary, &emp.CommissionPct, &emp.ManagerId, &emp.DepartmentId)
+	if err == sql.ErrNoRows {
+		return nil, ErrEmployeeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read employee %d: %v", employeeId, err)
+	}
+	return &emp, nil
+}
+
+// EmployeeInScope returns ErrEmployeeNotFound when the employee does not exist or lies
+// outside scope.
+func EmployeeInScope(txn *newrelic.Transaction, db *sql.DB, employeeId int, scope Scope) error {
+	_, err := GetEmployee(txn, db, employeeId, scope)
+	return err
+}
@@ -0,0 +1,108 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// SecurityEventFilter narrows a security event query. Zero values match everything.
+type SecurityEventFilter struct {
+	Type      string
+	Username  string
+	IpAddress string
+	From      *time.Time
+	To        *time.Time
+}
+
+// InsertSecurityEvent appends a security event.
+func InsertSecurityEvent(txn *newrelic.Transaction, db *sql.DB, event schema.SecurityEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "security_events",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	query := `INSERT INTO security_events (occurred_at, event_type, username, ip_address, actor_username, detail)
+              VALUES (:1, :2, :3, :4, :5, :6)`
+	_, err := db.ExecContext(ctx, query, event.OccurredAt, event.Type, truncatePtr(event.Username, 100), event.IpAddress,
+		event.Actor, truncate(event.Detail, 400))
+	if err != nil {
+		return fmt.Errorf("failed to write security event: %v", err)
+	}
+	return nil
+}
+
+// truncatePtr truncates an optional value, such as a username an attacker chose.
+func truncatePtr(s *string, max int) *string {
+	if s == nil {
+		return nil
+	}
+	t := truncate(*s, max)
+	return &t
+}
+
+// QuerySecurityEvents returns up to limit security events matching filter, newest first.
+func QuerySecurityEvents(txn *newrelic.Transaction, db *sql.DB, filter SecurityEventFilter, limit int) ([]schema.SecurityEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "security_events",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	query := `SELECT event_id, occurred_at, event_type, username, ip_address, actor_username, detail FROM security_events WHERE 1=1`
+	var args []interface{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		query += fmt.Sprintf(condition, len(args))
+	}
+	if filter.Type != "" {
+		addCondition(" AND event_type = :%d", filter.Type)
+	}
+	if filter.Username != "" {
+		addCondition(" AND username = :%d", filter.Username)
+	}
+	if filter.IpAddress != "" {
+		addCondition(" AND ip_address = :%d", filter.IpAddress)
+	}
+	if filter.From != nil {
+		addCondition(" AND occurred_at >= :%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition(" AND occurred_at < :%d", *filter.To)
+	}
+	addCondition(" ORDER BY occurred_at DESC, event_id DESC FETCH FIRST :%d ROWS ONLY", limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	events := []schema.SecurityEvent{}
+	for rows.Next() {
+		var event schema.SecurityEvent
+		if err := rows.Scan(&event.EventId, &event.OccurredAt, &event.Type, &event.Username, &event.IpAddress, &event.Actor, &event.Detail); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return events, nil
+}
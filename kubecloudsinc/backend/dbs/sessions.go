@@ -0,0 +1,254 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrRefreshTokenInvalid is returned for unknown or expired refresh tokens and for the
+// tokens of revoked or expired sessions.
+var ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+
+// ErrRefreshTokenReused is returned when a refresh token that was already exchanged is
+// presented again. Its session has been revoked by then.
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+// InsertSession starts a session with its first refresh token.
+func InsertSession(txn *newrelic.Transaction, db *sql.DB, session schema.Session, token schema.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "auth_sessions",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO auth_sessions (session_id, user_id, created_at, expires_at) VALUES (:1, :2, :3, :4)`,
+		session.SessionId, session.UserId, session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert session: %v", err)
+	}
+	if err := insertRefreshTokenTx(ctx, tx, session.SessionId, token); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken exchanges the refresh token with hash tokenHash for next, in the
+// same session, and returns the session. A token that was already exchanged revokes
+// the whole session, since either the client or someone who stole the token is
+// replaying it, and fails with ErrRefreshTokenReused.
+func RotateRefreshToken(txn *newrelic.Transaction, db *sql.DB, tokenHash string, next schema.RefreshToken) (*schema.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "auth_refresh_tokens",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var sessionId string
+	var expiresAt time.Time
+	var usedAt *time.Time
+	err = tx.QueryRowContext(ctx, `SELECT session_id, expires_at, used_at FROM auth_refresh_tokens WHERE token_hash = :1 FOR UPDATE`, tokenHash).Scan(&sessionId, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refresh token: %v", err)
+	}
+
+	var session schema.Session
+	err = tx.QueryRowContext(ctx, `SELECT session_id, user_id, created_at, expires_at, revoked_at, revoke_reason FROM auth_sessions WHERE session_id = :1 FOR UPDATE`, sessionId).
+		Scan(&session.SessionId, &session.UserId, &session.CreatedAt, &session.ExpiresAt, &session.RevokedAt, &session.RevokeReason)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %v", sessionId, err)
+	}
+	now := time.Now().UTC()
+	if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if usedAt != nil {
+		if err := revokeSessionsTx(ctx, tx, "session_id", sessionId, schema.SessionRevokedReuse); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Failed to commit transaction: %v", err)
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		log.Printf("Refresh token reused in session %s of user %d; session revoked", sessionId, session.UserId)
+		return nil, ErrRefreshTokenReused
+	}
+	if !now.Before(expiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE auth_refresh_tokens SET used_at = :1 WHERE token_hash = :2`, now, tokenHash); err != nil {
+		return nil, fmt.Errorf("failed to update refresh token: %v", err)
+	}
+	if err := insertRefreshTokenTx(ctx, tx, sessionId, next); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return &session, nil
+}
+
+// RevokeSession ends a session: its refresh tokens stop working and the access tokens
+// issued in it are denied until they expire.
+func RevokeSession(txn *newrelic.Transaction, db *sql.DB, sessionId string, reason string) error {
+	return revokeSessions(txn, db, "session_id", sessionId, reason)
+}
+
+// RevokeUserSessions ends every session of a user.
+func RevokeUserSessions(txn *newrelic.Transaction, db *sql.DB, userId int, reason string) error {
+	return revokeSessions(txn, db, "user_id", userId, reason)
+}
+
+func revokeSessions(txn *newrelic.Transaction, db *sql.DB, column string, value interface{}, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "auth_sessions",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to start transaction: %v", err)
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := revokeSessionsTx(ctx, tx, column, value, reason); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit transaction: %v", err)
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// revokeSessionsTx revokes the sessions whose column, session_id or user_id, equals
+// value and denies the access tokens issued in them that have not expired yet.
+func revokeSessionsTx(ctx context.Context, tx *sql.Tx, column string, value interface{}, reason string) error {
+	now := time.Now().UTC()
+	deny := fmt.Sprintf(`INSERT INTO auth_revoked_tokens (jti, expires_at)
+              SELECT t.access_jti, t.access_expires_at FROM auth_refresh_tokens t JOIN auth_sessions s ON s.session_id = t.session_id
+              WHERE s.%s = :1 AND t.access_expires_at > :2
+              AND NOT EXISTS (SELECT 1 FROM auth_revoked_tokens r WHERE r.jti = t.access_jti)`, column)
+	if _, err := tx.ExecContext(ctx, deny, value, now); err != nil {
+		return fmt.Errorf("failed to deny access tokens: %v", err)
+	}
+	revoke := fmt.Sprintf(`UPDATE auth_sessions SET revoked_at = :1, revoke_reason = :2 WHERE %s = :3 AND revoked_at IS NULL`, column)
+	if _, err := tx.ExecContext(ctx, revoke, now, reason, value); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether the access token with the given jti is denied.
+func IsAccessTokenRevoked(txn *newrelic.Transaction, db *sql.DB, jti string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "auth_revoked_tokens",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM auth_revoked_tokens WHERE jti = :1`, jti).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check access token: %v", err)
+	}
+	return count > 0, nil
+}
+
+// PruneSessions deletes expired sessions, refresh tokens and denied access tokens, and
+// sessions revoked more than a day ago, returning the number of rows deleted.
+func PruneSessions(txn *newrelic.Transaction, db *sql.DB) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "auth_sessions",
+		Operation:  "DELETE",
+	}
+	defer segment.End()
+
+	now := time.Now().UTC()
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`DELETE FROM auth_revoked_tokens WHERE expires_at <= :1`, []interface{}{now}},
+		{`DELETE FROM auth_sessions WHERE expires_at <= :1 OR revoked_at <= :2`, []interface{}{now, now.Add(-24 * time.Hour)}},
+		{`DELETE FROM auth_refresh_tokens WHERE expires_at <= :1 AND access_expires_at <= :2`, []interface{}{now, now}},
+	}
+	var deleted int64
+	for _, statement := range statements {
+		result, err := db.ExecContext(ctx, statement.query, statement.args...)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune sessions: %v", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to get rows affected: %v", err)
+		}
+		deleted += rowsAffected
+	}
+	return deleted, nil
+}
+
+func insertRefreshTokenTx(ctx context.Context, tx *sql.Tx, sessionId string, token schema.RefreshToken) error {
+	query := `INSERT INTO auth_refresh_tokens (token_hash, session_id, access_jti, access_expires_at, issued_at, expires_at) VALUES (:1, :2, :3, :4, :5, :6)`
+	_, err := tx.ExecContext(ctx, query, token.TokenHash, sessionId, token.AccessJti, token.AccessExpiresAt, token.IssuedAt, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %v", err)
+	}
+	return nil
+}
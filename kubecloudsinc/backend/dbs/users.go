@@ -0,0 +1,245 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/godror/godror"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrUserNotFound is returned when no user has the requested username or ID.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists is returned when a username or OpenID Connect subject is already taken.
+var ErrUserExists = errors.New("username already exists")
+
+const userColumns = "user_id, username, password_hash, role, employee_id, disabled, oidc_subject, password_changed_at, created_at, updated_at"
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*schema.User, error) {
+	var user schema.User
+	var disabled int
+	err := row.Scan(&user.UserId, &user.Username, &user.PasswordHash, &user.Role, &user.EmployeeId, &disabled, &user.OidcSubject, &user.PasswordChangedAt, &user.CreatedAt, &user.UpdatedAt)
+	user.Disabled = disabled != 0
+	return &user, err
+}
+
+// QueryUsers lists all users by username, including their password hashes.
+func QueryUsers(txn *newrelic.Transaction, db *sql.DB) ([]schema.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	rows, err := db.QueryContext(ctx, "SELECT "+userColumns+" FROM app_users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	users := []schema.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		users = append(users, *user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return users, nil
+}
+
+// GetUser reads a user by ID, including the password hash.
+func GetUser(txn *newrelic.Transaction, db *sql.DB, userId int) (*schema.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	user, err := scanUser(db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM app_users WHERE user_id = :1", userId))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user %d: %v", userId, err)
+	}
+	return user, nil
+}
+
+// GetUserByUsername reads a user, including the password hash.
+func GetUserByUsername(txn *newrelic.Transaction, db *sql.DB, username string) (*schema.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	user, err := scanUser(db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM app_users WHERE username = :1", username))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user %s: %v", username, err)
+	}
+	return user, nil
+}
+
+// GetUserBySubject reads the user linked to an OpenID Connect subject.
+func GetUserBySubject(txn *newrelic.Transaction, db *sql.DB, subject string) (*schema.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	user, err := scanUser(db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM app_users WHERE oidc_subject = :1", subject))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the user of subject %s: %v", subject, err)
+	}
+	return user, nil
+}
+
+// InsertUser stores a new user with its password hash and returns the user ID.
+func InsertUser(txn *newrelic.Transaction, db *sql.DB, user schema.User) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	var userId int
+	query := `INSERT INTO app_users (username, password_hash, role, employee_id, oidc_subject) VALUES (:1, :2, :3, :4, :5) RETURNING user_id INTO :6`
+	_, err := db.ExecContext(ctx, query, user.Username, user.PasswordHash, user.Role, user.EmployeeId, user.OidcSubject, sql.Out{Dest: &userId})
+	if isUniqueViolation(err) {
+		return 0, ErrUserExists
+	}
+	if err != nil {
+		log.Printf("Failed to insert user: %v", err)
+		return 0, fmt.Errorf("failed to insert user: %v", err)
+	}
+	return userId, nil
+}
+
+// UpdateUser replaces the role, employee link and disabled flag of a user.
+func UpdateUser(txn *newrelic.Transaction, db *sql.DB, user schema.User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	disabled := 0
+	if user.Disabled {
+		disabled = 1
+	}
+	query := `UPDATE app_users SET role = :1, employee_id = :2, disabled = :3, updated_at = SYSTIMESTAMP WHERE user_id = :4`
+	result, err := db.ExecContext(ctx, query, user.Role, user.EmployeeId, disabled, user.UserId)
+	if err != nil {
+		log.Printf("Failed to update user %d: %v", user.UserId, err)
+		return fmt.Errorf("failed to update user %d: %v", user.UserId, err)
+	}
+	return userAffected(result, user.UserId)
+}
+
+// UpdateUserPassword stores a new password hash for a user.
+func UpdateUserPassword(txn *newrelic.Transaction, db *sql.DB, userId int, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	query := `UPDATE app_users SET password_hash = :1, password_changed_at = SYSTIMESTAMP, updated_at = SYSTIMESTAMP WHERE user_id = :2`
+	result, err := db.ExecContext(ctx, query, passwordHash, userId)
+	if err != nil {
+		log.Printf("Failed to update the password of user %d: %v", userId, err)
+		return fmt.Errorf("failed to update the password of user %d: %v", userId, err)
+	}
+	return userAffected(result, userId)
+}
+
+// DeleteUser removes a user.
+func DeleteUser(txn *newrelic.Transaction, db *sql.DB, userId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "app_users",
+		Operation:  "DELETE",
+	}
+	defer segment.End()
+
+	result, err := db.ExecContext(ctx, `DELETE FROM app_users WHERE user_id = :1`, userId)
+	if err != nil {
+		log.Printf("Failed to delete user %d: %v", userId, err)
+		return fmt.Errorf("failed to delete user %d: %v", userId, err)
+	}
+	return userAffected(result, userId)
+}
+
+// userAffected returns ErrUserNotFound when a statement on one user changed no row.
+func userAffected(result sql.Result, userId int) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected for user %d: %v", userId, err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is ORA-00001, a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	oraErr, ok := godror.AsOraErr(err)
+	return ok && oraErr.Code() == 1
+}
@@ -0,0 +1,318 @@
+package dbs
+
+import (
+	schema "autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ErrWebhookSubscriptionNotFound is returned when no subscription has the given ID.
+var ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// ErrWebhookDeliveryNotFound is returned when no delivery has the given ID.
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+// ErrWebhookDeliveryNotRequeueable is returned when a delivery that is still pending or
+// being sent is redelivered.
+var ErrWebhookDeliveryNotRequeueable = errors.New("webhook delivery is still pending or being sent")
+
+func InsertWebhookSubscription(txn *newrelic.Transaction, db *sql.DB, sub schema.WebhookSubscription) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "webhook_subscriptions",
+		Operation:  "INSERT",
+	}
+	defer segment.End()
+
+	var subscriptionId int
+	query := `INSERT INTO webhook_subscriptions (url, event_types, secret, created_by) VALUES (:1, :2, :3, :4) RETURNING subscription_id INTO :5`
+	_, err := db.ExecContext(ctx, query, *sub.URL, strings.Join(sub.EventTypes, ","), *sub.Secret, sub.CreatedBy, sql.Out{Dest: &subscriptionId})
+	if err != nil {
+		log.Printf("Failed to insert webhook subscription: %v", err)
+		return 0, fmt.Errorf("failed to insert webhook subscription: %v", err)
+	}
+	return subscriptionId, nil
+}
+
+// QueryWebhookSubscriptions lists all subscriptions. Secrets are never returned.
+func QueryWebhookSubscriptions(txn *newrelic.Transaction, db *sql.DB) ([]schema.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "webhook_subscriptions",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	rows, err := db.QueryContext(ctx, `SELECT subscription_id, url, event_types, active, created_by, created_at FROM webhook_subscriptions ORDER BY subscription_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []schema.WebhookSubscription{}
+	for rows.Next() {
+		var sub schema.WebhookSubscription
+		var eventTypes string
+		var active int
+		if err := rows.Scan(&sub.SubscriptionId, &sub.URL, &eventTypes, &active, &sub.CreatedBy, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		isActive := active == 1
+		sub.Active = &isActive
+		sub.EventTypes = strings.Split(eventTypes, ",")
+		subscriptions = append(subscriptions, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return subscriptions, nil
+}
+
+func DeleteWebhookSubscription(txn *newrelic.Transaction, db *sql.DB, subscriptionId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "webhook_subscriptions",
+		Operation:  "DELETE",
+	}
+	defer segment.End()
+
+	result, err := db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE subscription_id = :1`, subscriptionId)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrWebhookSubscriptionNotFound
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries creates one pending delivery per active subscription that
+// listens for the event's type. Re-enqueueing the same event is a no-op, which keeps
+// the outbox relay's at-least-once retries from producing duplicate webhooks.
+func EnqueueWebhookDeliveries(db *sql.DB, event schema.ChangeEvent) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode change event: %v", err)
+	}
+
+	query := `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, next_attempt_at)
+              SELECT s.subscription_id, :1, :2, :3, SYSTIMESTAMP
+              FROM webhook_subscriptions s
+              WHERE s.active = 1
+                AND (',' || s.event_types || ',' LIKE '%,' || :4 || ',%' OR ',' || s.event_types || ',' LIKE '%,*,%')
+                AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.subscription_id = s.subscription_id AND d.event_id = :5)`
+	result, err := db.ExecContext(ctx, query, event.EventId, event.Type, string(payload), event.Type, event.EventId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %v", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// ProcessWebhookDeliveries claims up to batchSize due deliveries and calls deliver for
+// each. A successful delivery is marked delivered; on failure nextAttempt decides when
+// to retry, and a nil time moves the delivery to the dead-letter list.
+//
+// No transaction is open while the endpoints are called: the claim marks the rows
+// in_flight for lease and commits, and each result is recorded on its own. A worker
+// that dies mid-batch leaves its deliveries in_flight until the lease runs out, when
+// another worker claims them again.
+func ProcessWebhookDeliveries(db *sql.DB, batchSize int, lease time.Duration, deliver func(schema.WebhookDelivery) (int, error), nextAttempt func(attempts int) *time.Time) (int, error) {
+	deliveries, err := claimWebhookDeliveries(db, batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range deliveries {
+		statusCode, deliverErr := deliver(d)
+		if err := recordWebhookDelivery(db, d, statusCode, deliverErr, nextAttempt); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// claimWebhookDeliveries locks up to batchSize due deliveries, and in_flight ones whose
+// lease ran out, and marks them in_flight until the lease ends. Rows of SKIP LOCKED are
+// locked as they are fetched, so the batch is cut by fetching rather than by ROWNUM,
+// which would count the rows other workers hold.
+func claimWebhookDeliveries(db *sql.DB, batchSize int, lease time.Duration) ([]schema.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT d.delivery_id, d.subscription_id, d.event_id, d.event_type, d.attempts, d.payload, s.url, s.secret
+              FROM webhook_deliveries d
+              JOIN webhook_subscriptions s ON s.subscription_id = d.subscription_id
+              WHERE d.status IN ('pending', 'in_flight') AND d.next_attempt_at <= SYSTIMESTAMP
+              ORDER BY d.next_attempt_at
+              FOR UPDATE OF d.status SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %v", err)
+	}
+
+	var deliveries []schema.WebhookDelivery
+	for len(deliveries) < batchSize && rows.Next() {
+		var d schema.WebhookDelivery
+		if err := rows.Scan(&d.DeliveryId, &d.SubscriptionId, &d.EventId, &d.EventType, &d.Attempts, &d.Payload, &d.URL, &d.Secret); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan webhook delivery: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %v", err)
+	}
+
+	for _, d := range deliveries {
+		_, err := tx.ExecContext(ctx, `UPDATE webhook_deliveries SET status = 'in_flight', next_attempt_at = SYSTIMESTAMP + NUMTODSINTERVAL(:1, 'SECOND') WHERE delivery_id = :2`,
+			lease.Seconds(), d.DeliveryId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim webhook delivery %d: %v", d.DeliveryId, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return deliveries, nil
+}
+
+// recordWebhookDelivery stores the result of one attempt of a claimed delivery. A
+// delivery that is no longer in_flight was requeued meanwhile and is left alone.
+func recordWebhookDelivery(db *sql.DB, d schema.WebhookDelivery, statusCode int, deliverErr error, nextAttempt func(attempts int) *time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	attempts := d.Attempts + 1
+	var code interface{}
+	if statusCode > 0 {
+		code = statusCode
+	}
+
+	var err error
+	if deliverErr == nil {
+		_, err = db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = 'delivered', attempts = :1, last_status_code = :2, last_error = NULL, next_attempt_at = NULL, delivered_at = SYSTIMESTAMP WHERE delivery_id = :3 AND status = 'in_flight'`,
+			attempts, code, d.DeliveryId)
+	} else if next := nextAttempt(attempts); next != nil {
+		_, err = db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = 'pending', attempts = :1, last_status_code = :2, last_error = :3, next_attempt_at = :4 WHERE delivery_id = :5 AND status = 'in_flight'`,
+			attempts, code, truncate(deliverErr.Error(), 4000), *next, d.DeliveryId)
+	} else {
+		log.Printf("Webhook delivery %d moved to dead-letter list after %d attempts", d.DeliveryId, attempts)
+		_, err = db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = 'dead', attempts = :1, last_status_code = :2, last_error = :3, next_attempt_at = NULL WHERE delivery_id = :4 AND status = 'in_flight'`,
+			attempts, code, truncate(deliverErr.Error(), 4000), d.DeliveryId)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery %d: %v", d.DeliveryId, err)
+	}
+	return nil
+}
+
+// QueryWebhookDeliveries lists deliveries, optionally filtered by status, newest first.
+func QueryWebhookDeliveries(txn *newrelic.Transaction, db *sql.DB, status string, limit int) ([]schema.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "webhook_deliveries",
+		Operation:  "SELECT",
+	}
+	defer segment.End()
+
+	query := `SELECT delivery_id, subscription_id, event_id, event_type, status, attempts, last_status_code, last_error, next_attempt_at, created_at, delivered_at
+              FROM webhook_deliveries
+              WHERE (:1 IS NULL OR status = :2)
+              ORDER BY delivery_id DESC
+              FETCH FIRST :3 ROWS ONLY`
+	var statusParam interface{}
+	if status != "" {
+		statusParam = status
+	}
+	rows, err := db.QueryContext(ctx, query, statusParam, statusParam, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []schema.WebhookDelivery{}
+	for rows.Next() {
+		var d schema.WebhookDelivery
+		if err := rows.Scan(&d.DeliveryId, &d.SubscriptionId, &d.EventId, &d.EventType, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return deliveries, nil
+}
+
+// RequeueWebhookDelivery makes a delivered or dead delivery pending again with a fresh
+// retry budget so the worker sends it on its next poll. Deliveries that are pending or
+// in flight are left alone, so a dispatcher holding the lease keeps its attempt, and
+// reported as ErrWebhookDeliveryNotRequeueable.
+func RequeueWebhookDelivery(txn *newrelic.Transaction, db *sql.DB, deliveryId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	segment := newrelic.DatastoreSegment{
+		StartTime:  txn.StartSegmentNow(),
+		Product:    newrelic.DatastoreOracle,
+		Collection: "webhook_deliveries",
+		Operation:  "UPDATE",
+	}
+	defer segment.End()
+
+	result, err := db.ExecContext(ctx, `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = SYSTIMESTAMP, delivered_at = NULL
+              WHERE delivery_id = :1 AND status IN ('delivered', 'dead')`, deliveryId)
+	if err != nil {
+		return fmt.Errorf("failed to requeue webhook delivery: %v", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	// Nothing was updated: either the delivery does not exist or it is not finished
+	var status string
+	err = db.QueryRowContext(ctx, `SELECT status FROM webhook_deliveries WHERE delivery_id = :1`, deliveryId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read webhook delivery: %v", err)
+	}
+	return fmt.Errorf("%w: delivery %d is %s", ErrWebhookDeliveryNotRequeueable, deliveryId, status)
+}
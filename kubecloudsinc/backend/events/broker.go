@@ -0,0 +1,198 @@
+package events
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// Broker fans change events out to live subscribers such as the /v2/events stream.
+// Every replica tails the outbox table itself, so a client sees all changes no matter
+// which replica it is connected to. The most recent events are kept in a bounded
+// replay buffer for Last-Event-ID resume.
+//
+// Identity values are allocated before commit, so an event of a long transaction can
+// become visible after events with higher IDs were sent. The broker remembers the IDs
+// it skipped and looks for them again on every poll for GapTimeout, measured on its
+// own clock; rolled back transactions and identity caches leave gaps that never fill.
+// Events are sent, and kept in the buffer, in the order they became visible, so a late
+// event follows events with higher IDs.
+type Broker struct {
+	DB         *sql.DB
+	Interval   time.Duration
+	GapTimeout time.Duration
+	Capacity   int
+
+	mu          sync.Mutex
+	buffer      []schema.ChangeEvent
+	lastId      int64
+	gaps        map[int64]time.Time // IDs below lastId not seen yet, with when they were skipped
+	truncated   bool                // the buffer does not reach back to the first event ever written
+	loaded      bool
+	subscribers map[chan schema.ChangeEvent]struct{}
+}
+
+// maxGaps bounds the skipped IDs the broker looks for; a larger jump of the identity
+// column is taken as a gap that will not fill.
+const maxGaps = 1000
+
+func NewBroker(db *sql.DB) *Broker {
+	return &Broker{
+		DB:          db,
+		Interval:    time.Second,
+		GapTimeout:  5 * time.Minute,
+		Capacity:    1000,
+		gaps:        make(map[int64]time.Time),
+		subscribers: make(map[chan schema.ChangeEvent]struct{}),
+	}
+}
+
+// Run tails the outbox until ctx is cancelled. The first poll backfills the replay
+// buffer with the latest Capacity events.
+func (b *Broker) Run(ctx context.Context) {
+	log.Printf("Event broker started, polling every %s", b.Interval)
+	for {
+		late, events, err := b.poll()
+		if err != nil {
+			log.Printf("Event broker error: %v", err)
+		}
+		for _, event := range late {
+			b.broadcast(event)
+		}
+		for _, event := range events {
+			b.broadcast(event)
+		}
+		b.mu.Lock()
+		if err == nil && !b.loaded {
+			// The backfill may not reach back to the first event ever written
+			b.truncated = len(events) == b.Capacity
+			b.loaded = true
+		}
+		b.mu.Unlock()
+		if err == nil && len(events) == b.Capacity {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Event broker stopped")
+			return
+		case <-time.After(b.Interval):
+		}
+	}
+}
+
+// poll reads the skipped IDs that committed since the last poll, and the events after
+// the highest ID seen. Gaps older than GapTimeout are given up first.
+func (b *Broker) poll() (late, events []schema.ChangeEvent, err error) {
+	b.mu.Lock()
+	afterId := b.lastId
+	var missing []int64
+	for id, skippedAt := range b.gaps {
+		if time.Since(skippedAt) > b.GapTimeout {
+			delete(b.gaps, id)
+			continue
+		}
+		missing = append(missing, id)
+	}
+	b.mu.Unlock()
+
+	if len(missing) > 0 {
+		late, err = dbs.QueryOutboxEventsById(b.DB, missing)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	events, err = dbs.QueryOutboxEventsAfter(b.DB, afterId, b.Capacity)
+	if err != nil {
+		return late, nil, err
+	}
+	return late, events, nil
+}
+
+func (b *Broker) broadcast(event schema.ChangeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch _, skipped := b.gaps[event.EventId]; {
+	case event.EventId > b.lastId:
+		// IDs before the first event of the backfill are not gaps; those of an outbox
+		// that was empty at startup are
+		if b.lastId > 0 || b.loaded {
+			for id := max(b.lastId+1, event.EventId-maxGaps); id < event.EventId && len(b.gaps) < maxGaps; id++ {
+				b.gaps[id] = time.Now()
+			}
+		}
+		b.lastId = event.EventId
+	case skipped:
+		delete(b.gaps, event.EventId)
+	default:
+		return
+	}
+
+	b.buffer = append(b.buffer, event)
+	if len(b.buffer) > b.Capacity {
+		b.buffer = b.buffer[len(b.buffer)-b.Capacity:]
+		b.truncated = true
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// A subscriber that cannot keep up is disconnected; it can resume with
+			// Last-Event-ID from the replay buffer.
+			close(ch)
+			delete(b.subscribers, ch)
+		}
+	}
+}
+
+// Subscribe registers a live subscriber. When lastEventId is set, the buffered events
+// after it are returned for replay; complete is false if some of the requested events
+// have already left the buffer and the client must reload its state. The returned
+// channel is closed when the subscriber falls behind or unsubscribe is called.
+//
+// The replay holds the events that became visible after lastEventId, and every
+// buffered event with a higher ID, which another replica may have sent later. An event
+// can therefore be sent twice; clients de-duplicate on the event ID.
+func (b *Broker) Subscribe(lastEventId int64) (replay []schema.ChangeEvent, complete bool, ch <-chan schema.ChangeEvent, unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastEventId > 0 {
+		position := -1
+		for i, event := range b.buffer {
+			if event.EventId == lastEventId {
+				position = i
+				break
+			}
+		}
+		if position < 0 {
+			// An event this replica has not seen yet, or one that left the buffer
+			complete = b.loaded && (!b.truncated || (len(b.buffer) > 0 && lastEventId >= b.buffer[0].EventId))
+		}
+		for i, event := range b.buffer {
+			if (position >= 0 && i > position) || event.EventId > lastEventId {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := make(chan schema.ChangeEvent, 64)
+	b.subscribers[sub] = struct{}{}
+	unsubscribe = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub)
+		}
+	}
+	return replay, complete, sub, unsubscribe
+}
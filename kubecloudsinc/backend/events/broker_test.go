@@ -0,0 +1,47 @@
+package events
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"testing"
+)
+
+func eventIds(events []schema.ChangeEvent) []int64 {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.EventId
+	}
+	return ids
+}
+
+func TestLateEventIsSentAndReplayedAfterHigherIds(t *testing.T) {
+	b := NewBroker(nil)
+	b.loaded = true
+	for _, id := range []int64{1, 2, 4, 5} {
+		b.broadcast(schema.ChangeEvent{EventId: id})
+	}
+	if _, ok := b.gaps[3]; !ok {
+		t.Fatal("the skipped ID 3 is not tracked")
+	}
+
+	// Event 3 commits after 4 and 5 were sent
+	_, _, live, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+	b.broadcast(schema.ChangeEvent{EventId: 3})
+	if event := <-live; event.EventId != 3 {
+		t.Fatalf("live subscriber got event %d, want 3", event.EventId)
+	}
+	if _, ok := b.gaps[3]; ok {
+		t.Fatal("the filled gap is still tracked")
+	}
+	b.broadcast(schema.ChangeEvent{EventId: 3})
+	if len(b.buffer) != 5 {
+		t.Fatalf("buffer has %d events after a duplicate, want 5", len(b.buffer))
+	}
+
+	// A client that saw 5 resumes with the late event
+	replay, complete, _, unsubscribeReplay := b.Subscribe(5)
+	defer unsubscribeReplay()
+	if got := eventIds(replay); !complete || len(got) != 1 || got[0] != 3 {
+		t.Fatalf("replay after 5 is %v (complete %v), want [3]", got, complete)
+	}
+}
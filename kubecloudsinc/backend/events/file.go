@@ -0,0 +1,48 @@
+package events
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FilePublisher writes each event as a JSON line to a file or to stdout.
+type FilePublisher struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer
+}
+
+// NewFilePublisher appends events to path. An empty path or "-" writes to stdout.
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	if path == "" || path == "-" {
+		return &FilePublisher{out: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event file %s: %v", path, err)
+	}
+	return &FilePublisher{out: f, closer: f}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, event schema.ChangeEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.out.Write(append(line, '\n'))
+	return err
+}
+
+func (p *FilePublisher) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
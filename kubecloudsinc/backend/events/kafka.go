@@ -0,0 +1,47 @@
+package events
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes events to a Kafka-compatible broker (Kafka, Redpanda, ...).
+// Messages are keyed by employee ID so changes to one employee stay ordered.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event schema.ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.Itoa(event.EmployeeId)),
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "event-type", Value: []byte(event.Type)},
+			{Key: "event-id", Value: []byte(strconv.FormatInt(event.EventId, 10))},
+		},
+	})
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes events on "<prefix>.<event type>", e.g. "hr.employee.updated".
+type NATSPublisher struct {
+	conn          *nats.Conn
+	subjectPrefix string
+}
+
+func NewNATSPublisher(url, subjectPrefix string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("kubecloudsinc-outbox-relay"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %v", url, err)
+	}
+	return &NATSPublisher{conn: conn, subjectPrefix: subjectPrefix}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, event schema.ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+	msg := nats.NewMsg(p.subjectPrefix + "." + event.Type)
+	msg.Data = payload
+	msg.Header.Set(nats.MsgIdHdr, fmt.Sprintf("%d", event.EventId))
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	// Flush so a successful return means the server has the message.
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
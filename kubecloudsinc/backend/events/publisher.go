@@ -0,0 +1,38 @@
+package events
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+)
+
+// Publisher delivers change events to a downstream transport. Implementations must be
+// safe for use by a single relay goroutine; Publish should return only once the
+// transport has accepted the event so the outbox row can be marked as published.
+type Publisher interface {
+	Publish(ctx context.Context, event schema.ChangeEvent) error
+	Close() error
+}
+
+// MultiPublisher publishes every event to each of its publishers in order and stops
+// at the first error. Downstream publishers therefore have to tolerate seeing the
+// same event again when a later one fails and the relay retries.
+type MultiPublisher []Publisher
+
+func (m MultiPublisher) Publish(ctx context.Context, event schema.ChangeEvent) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MultiPublisher) Close() error {
+	var firstErr error
+	for _, p := range m {
+		if err := p.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// Relay moves committed change events from the outbox table to a Publisher.
+// Delivery is at-least-once: consumers should de-duplicate on eventId.
+type Relay struct {
+	DB        *sql.DB
+	Publisher Publisher
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Run polls the outbox until ctx is cancelled. A full batch is followed immediately
+// by another poll so a backlog drains without waiting for the next tick.
+func (r *Relay) Run(ctx context.Context) {
+	log.Printf("Outbox relay started, polling every %s", r.Interval)
+	for {
+		published, err := dbs.RelayOutbox(r.DB, r.BatchSize, func(event schema.ChangeEvent) error {
+			pubCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			return r.Publisher.Publish(pubCtx, event)
+		})
+		if err != nil {
+			log.Printf("Outbox relay error: %v", err)
+		}
+		if published > 0 {
+			log.Printf("Outbox relay published %d event(s)", published)
+		}
+		if err == nil && published == r.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped")
+			return
+		case <-time.After(r.Interval):
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// listFields are the fields that return lists, with the number of items assumed when
+// the query does not pass an explicit limit. Fields with a limit argument assume the
+// page size their resolver uses without one.
+var listFields = map[string]int{
+	"employees":   defaultPageSize,
+	"managers":    20,
+	"reports":     10,
+	"departments": 30,
+	"jobs":        20,
+	"locations":   25,
+	"jobHistory":  5,
+}
+
+// Complexity estimates the cost of an operation before it runs. Every field costs
+// one point, and the cost of everything selected below a list field is multiplied by
+// the expected number of items, so deeply nested lists grow quickly.
+func Complexity(query, operationName string, variables map[string]interface{}) (int, error) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return 0, err
+	}
+
+	var op *ast.OperationDefinition
+	for _, candidate := range doc.Operations {
+		if operationName == "" || candidate.Name == operationName {
+			op = candidate
+			break
+		}
+	}
+	if op == nil {
+		return 0, fmt.Errorf("operation %q not found", operationName)
+	}
+
+	c := &complexityCounter{fragments: doc.Fragments, variables: variables}
+	return c.selectionSet(op.SelectionSet, 0), nil
+}
+
+type complexityCounter struct {
+	fragments ast.FragmentDefinitionList
+	variables map[string]interface{}
+}
+
+// maxFragmentNesting stops runaway recursion through fragment spreads; cyclic
+// fragments are rejected by schema validation anyway.
+const maxFragmentNesting = 32
+
+func (c *complexityCounter) selectionSet(set ast.SelectionSet, nesting int) int {
+	if nesting > maxFragmentNesting {
+		return 0
+	}
+	total := 0
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			cost := 1 + c.selectionSet(s.SelectionSet, nesting+1)
+			if size, ok := listFields[s.Name]; ok {
+				cost = 1 + c.listSize(s, size)*(cost-1)
+			}
+			total += cost
+		case *ast.InlineFragment:
+			total += c.selectionSet(s.SelectionSet, nesting+1)
+		case *ast.FragmentSpread:
+			if def := c.fragments.ForName(s.Name); def != nil {
+				total += c.selectionSet(def.SelectionSet, nesting+1)
+			}
+		}
+	}
+	return total
+}
+
+// listSize reads the limit argument, from a literal or a variable, when present.
+func (c *complexityCounter) listSize(field *ast.Field, defaultSize int) int {
+	arg := field.Arguments.ForName("limit")
+	if arg == nil || arg.Value == nil {
+		return defaultSize
+	}
+	switch arg.Value.Kind {
+	case ast.IntValue:
+		if n, err := strconv.Atoi(arg.Value.Raw); err == nil && n > 0 {
+			return min(n, maxPageSize)
+		}
+	case ast.Variable:
+		switch v := c.variables[arg.Value.Raw].(type) {
+		case float64:
+			if v > 0 {
+				return min(int(v), maxPageSize)
+			}
+		case int:
+			if v > 0 {
+				return min(v, maxPageSize)
+			}
+		}
+	}
+	return defaultSize
+}
@@ -0,0 +1,119 @@
+package gql
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+type loadersKey struct{}
+
+// Loaders batch the relation lookups of a single GraphQL request. Resolvers for list
+// items run concurrently, so every Load issued within the batch window is combined
+// into one IN query instead of one query per parent row. Employees outside Scope, the
+// caller's, resolve as if they did not exist.
+type Loaders struct {
+	Scope            dbs.Scope
+	Employee         *dataloader.Loader[int, *schema.Employee]
+	ReportsByManager *dataloader.Loader[int, []schema.Employee]
+	EmployeesByDept  *dataloader.Loader[int, []schema.Employee]
+	JobHistoryByEmp  *dataloader.Loader[int, []schema.JobHistoryRecord]
+	Job              *dataloader.Loader[string, *schema.JobRecord]
+	Department       *dataloader.Loader[int, *schema.DepartmentRecord]
+	Location         *dataloader.Loader[int, *schema.LocationRecord]
+	Country          *dataloader.Loader[string, *schema.CountryRecord]
+	Region           *dataloader.Loader[int, *schema.Region]
+}
+
+func NewLoaders(db *sql.DB, scope dbs.Scope) *Loaders {
+	return &Loaders{
+		Scope: scope,
+		Employee: newLoader(func(ctx context.Context, ids []int) ([]schema.Employee, error) {
+			return dbs.QueryEmployeesByIds(ctx, db, ids, scope)
+		}, func(e schema.Employee) int { return *e.EmployeeId }),
+		ReportsByManager: newGroupLoader(func(ctx context.Context, ids []int) ([]schema.Employee, error) {
+			return dbs.QueryEmployeesByManagerIds(ctx, db, ids, scope)
+		}, func(e schema.Employee) *int { return e.ManagerId }),
+		EmployeesByDept: newGroupLoader(func(ctx context.Context, ids []int) ([]schema.Employee, error) {
+			return dbs.QueryEmployeesByDepartmentIds(ctx, db, ids, scope)
+		}, func(e schema.Employee) *int { return e.DepartmentId }),
+		JobHistoryByEmp: newGroupLoader(func(ctx context.Context, ids []int) ([]schema.JobHistoryRecord, error) {
+			return dbs.QueryJobHistoryByEmployeeIds(ctx, db, ids)
+		}, func(h schema.JobHistoryRecord) *int { return h.EmployeeId }),
+		Job: newLoader(func(ctx context.Context, ids []string) ([]schema.JobRecord, error) {
+			return dbs.QueryJobs(ctx, db, ids)
+		}, func(j schema.JobRecord) string { return *j.JobId }),
+		Department: newLoader(func(ctx context.Context, ids []int) ([]schema.DepartmentRecord, error) {
+			return dbs.QueryDepartments(ctx, db, ids)
+		}, func(d schema.DepartmentRecord) int { return *d.DepartmentId }),
+		Location: newLoader(func(ctx context.Context, ids []int) ([]schema.LocationRecord, error) {
+			return dbs.QueryLocations(ctx, db, ids)
+		}, func(l schema.LocationRecord) int { return *l.LocationId }),
+		Country: newLoader(func(ctx context.Context, ids []string) ([]schema.CountryRecord, error) {
+			return dbs.QueryCountriesByIds(ctx, db, ids)
+		}, func(c schema.CountryRecord) string { return *c.CountryId }),
+		Region: newLoader(func(ctx context.Context, ids []int) ([]schema.Region, error) {
+			return dbs.QueryRegionsByIds(ctx, db, ids)
+		}, func(r schema.Region) int { return *r.RegionId }),
+	}
+}
+
+func WithLoaders(ctx context.Context, loaders *Loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, loaders)
+}
+
+func loadersFrom(ctx context.Context) *Loaders {
+	return ctx.Value(loadersKey{}).(*Loaders)
+}
+
+const batchWait = 2 * time.Millisecond
+
+// newLoader builds a loader for one-to-one relations. Keys without a row resolve to nil.
+func newLoader[K comparable, V any](fetch func(context.Context, []K) ([]V, error), key func(V) K) *dataloader.Loader[K, *V] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, keys []K) []*dataloader.Result[*V] {
+		results := make([]*dataloader.Result[*V], len(keys))
+		rows, err := fetch(ctx, keys)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*V]{Error: err}
+			}
+			return results
+		}
+		byKey := make(map[K]*V, len(rows))
+		for i := range rows {
+			byKey[key(rows[i])] = &rows[i]
+		}
+		for i, k := range keys {
+			results[i] = &dataloader.Result[*V]{Data: byKey[k]}
+		}
+		return results
+	}, dataloader.WithWait[K, *V](batchWait))
+}
+
+// newGroupLoader builds a loader for one-to-many relations keyed by a foreign key.
+func newGroupLoader[V any](fetch func(context.Context, []int) ([]V, error), key func(V) *int) *dataloader.Loader[int, []V] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, keys []int) []*dataloader.Result[[]V] {
+		results := make([]*dataloader.Result[[]V], len(keys))
+		rows, err := fetch(ctx, keys)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[[]V]{Error: err}
+			}
+			return results
+		}
+		byKey := make(map[int][]V)
+		for _, row := range rows {
+			if k := key(row); k != nil {
+				byKey[*k] = append(byKey[*k], row)
+			}
+		}
+		for i, k := range keys {
+			results[i] = &dataloader.Result[[]V]{Data: byKey[k]}
+		}
+		return results
+	}, dataloader.WithWait[int, []V](batchWait))
+}
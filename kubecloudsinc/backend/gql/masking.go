@@ -0,0 +1,22 @@
+package gql
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"context"
+)
+
+// readable returns value unless the read policy hides field from the caller's role.
+func readable[T any](ctx context.Context, field string, value *T) *T {
+	if visible, _ := policy.CurrentRead.Mask(middleware.ActorFromContext(ctx).Role, field, nil); !visible {
+		return nil
+	}
+	return value
+}
+
+// band returns the range value falls in when the read policy bands field for the
+// caller's role.
+func band(ctx context.Context, field string, value *float64) *string {
+	_, b := policy.CurrentRead.Mask(middleware.ActorFromContext(ctx).Role, field, value)
+	return b
+}
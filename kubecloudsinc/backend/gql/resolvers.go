@@ -0,0 +1,341 @@
+package gql
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+)
+
+// Page sizes of list fields with a limit argument. defaultPageSize is also the default
+// of limit in schema.graphql and what the complexity estimate assumes without a limit.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Resolver is the root Query resolver.
+type Resolver struct {
+	DB *sql.DB
+}
+
+func (r *Resolver) Employee(ctx context.Context, args struct{ ID int32 }) (*employeeResolver, error) {
+	emp, err := loadersFrom(ctx).Employee.Load(ctx, int(args.ID))()
+	if err != nil || emp == nil {
+		return nil, err
+	}
+	return &employeeResolver{*emp}, nil
+}
+
+func (r *Resolver) Employees(ctx context.Context, args struct {
+	Limit        int32
+	Offset       int32
+	DepartmentId *int32
+	ManagerId    *int32
+	JobId        *string
+}) ([]*employeeResolver, error) {
+	limit := int(args.Limit)
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	offset := int(args.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+
+	filter := dbs.EmployeeFilter{Scope: loadersFrom(ctx).Scope}
+	if args.DepartmentId != nil {
+		filter.DepartmentId = int(*args.DepartmentId)
+	}
+	if args.ManagerId != nil {
+		filter.ManagerId = int(*args.ManagerId)
+	}
+	if args.JobId != nil {
+		filter.JobId = *args.JobId
+	}
+
+	employees, err := dbs.QueryEmployeePage(ctx, r.DB, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return employeeResolvers(ctx, employees), nil
+}
+
+func (r *Resolver) Managers(ctx context.Context) ([]*employeeResolver, error) {
+	employees, err := dbs.QueryManagers(ctx, r.DB, loadersFrom(ctx).Scope)
+	if err != nil {
+		return nil, err
+	}
+	return employeeResolvers(ctx, employees), nil
+}
+
+func (r *Resolver) Department(ctx context.Context, args struct{ ID int32 }) (*departmentResolver, error) {
+	return loadDepartment(ctx, intPtr(int(args.ID)))
+}
+
+func (r *Resolver) Departments(ctx context.Context) ([]*departmentResolver, error) {
+	departments, err := dbs.QueryDepartments(ctx, r.DB, nil)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*departmentResolver, len(departments))
+	for i, d := range departments {
+		resolvers[i] = &departmentResolver{d}
+	}
+	return resolvers, nil
+}
+
+func (r *Resolver) Job(ctx context.Context, args struct{ ID string }) (*jobResolver, error) {
+	return loadJob(ctx, &args.ID)
+}
+
+func (r *Resolver) Jobs(ctx context.Context) ([]*jobResolver, error) {
+	jobs, err := dbs.QueryJobs(ctx, r.DB, nil)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*jobResolver, len(jobs))
+	for i, j := range jobs {
+		resolvers[i] = &jobResolver{j}
+	}
+	return resolvers, nil
+}
+
+func (r *Resolver) Location(ctx context.Context, args struct{ ID int32 }) (*locationResolver, error) {
+	return loadLocation(ctx, intPtr(int(args.ID)))
+}
+
+func (r *Resolver) Locations(ctx context.Context) ([]*locationResolver, error) {
+	locations, err := dbs.QueryLocations(ctx, r.DB, nil)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*locationResolver, len(locations))
+	for i, l := range locations {
+		resolvers[i] = &locationResolver{l}
+	}
+	return resolvers, nil
+}
+
+type employeeResolver struct {
+	e schema.Employee
+}
+
+// employeeResolvers wraps employees and primes the employee loader so that later
+// manager lookups for the same IDs are served without another query.
+func employeeResolvers(ctx context.Context, employees []schema.Employee) []*employeeResolver {
+	loader := loadersFrom(ctx).Employee
+	resolvers := make([]*employeeResolver, len(employees))
+	for i := range employees {
+		loader.Prime(ctx, *employees[i].EmployeeId, &employees[i])
+		resolvers[i] = &employeeResolver{employees[i]}
+	}
+	return resolvers
+}
+
+func (r *employeeResolver) EmployeeId() int32 { return int32(*r.e.EmployeeId) }
+
+// Fields and relations go through the read policy; the ID is never masked.
+func (r *employeeResolver) FirstName(ctx context.Context) *string {
+	return readable(ctx, "firstName", r.e.FirstName)
+}
+func (r *employeeResolver) LastName(ctx context.Context) *string {
+	return readable(ctx, "lastName", r.e.LastName)
+}
+func (r *employeeResolver) Email(ctx context.Context) *string {
+	return readable(ctx, "email", r.e.Email)
+}
+func (r *employeeResolver) Phone(ctx context.Context) *string {
+	return readable(ctx, "phone", r.e.Phone)
+}
+func (r *employeeResolver) HireDate(ctx context.Context) *string {
+	return readable(ctx, "hireDate", r.e.HireDate)
+}
+func (r *employeeResolver) Salary(ctx context.Context) *float64 {
+	return readable(ctx, "salary", r.e.Salary)
+}
+func (r *employeeResolver) SalaryBand(ctx context.Context) *string {
+	return band(ctx, "salary", r.e.Salary)
+}
+func (r *employeeResolver) CommissionPct(ctx context.Context) *float64 {
+	return readable(ctx, "commissionPct", r.e.CommissionPct)
+}
+func (r *employeeResolver) CommissionPctBand(ctx context.Context) *string {
+	return band(ctx, "commissionPct", r.e.CommissionPct)
+}
+
+func (r *employeeResolver) Job(ctx context.Context) (*jobResolver, error) {
+	return loadJob(ctx, readable(ctx, "jobId", r.e.JobId))
+}
+
+func (r *employeeResolver) Manager(ctx context.Context) (*employeeResolver, error) {
+	return loadEmployee(ctx, readable(ctx, "managerId", r.e.ManagerId))
+}
+
+func (r *employeeResolver) Reports(ctx context.Context) ([]*employeeResolver, error) {
+	reports, err := loadersFrom(ctx).ReportsByManager.Load(ctx, *r.e.EmployeeId)()
+	if err != nil {
+		return nil, err
+	}
+	return employeeResolvers(ctx, reports), nil
+}
+
+func (r *employeeResolver) Department(ctx context.Context) (*departmentResolver, error) {
+	return loadDepartment(ctx, readable(ctx, "departmentId", r.e.DepartmentId))
+}
+
+func (r *employeeResolver) JobHistory(ctx context.Context) ([]*jobHistoryResolver, error) {
+	history, err := loadersFrom(ctx).JobHistoryByEmp.Load(ctx, *r.e.EmployeeId)()
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*jobHistoryResolver, len(history))
+	for i, h := range history {
+		resolvers[i] = &jobHistoryResolver{h}
+	}
+	return resolvers, nil
+}
+
+type jobResolver struct {
+	j schema.JobRecord
+}
+
+func (r *jobResolver) JobId() string       { return *r.j.JobId }
+func (r *jobResolver) JobTitle() *string   { return r.j.JobTitle }
+func (r *jobResolver) MinSalary() *float64 { return r.j.MinSalary }
+func (r *jobResolver) MaxSalary() *float64 { return r.j.MaxSalary }
+
+type jobHistoryResolver struct {
+	h schema.JobHistoryRecord
+}
+
+func (r *jobHistoryResolver) StartDate() *string { return r.h.StartDate }
+func (r *jobHistoryResolver) EndDate() *string   { return r.h.EndDate }
+
+func (r *jobHistoryResolver) Job(ctx context.Context) (*jobResolver, error) {
+	return loadJob(ctx, r.h.JobId)
+}
+
+func (r *jobHistoryResolver) Department(ctx context.Context) (*departmentResolver, error) {
+	return loadDepartment(ctx, r.h.DepartmentId)
+}
+
+type departmentResolver struct {
+	d schema.DepartmentRecord
+}
+
+func (r *departmentResolver) DepartmentId() int32     { return int32(*r.d.DepartmentId) }
+func (r *departmentResolver) DepartmentName() *string { return r.d.DepartmentName }
+
+func (r *departmentResolver) Manager(ctx context.Context) (*employeeResolver, error) {
+	return loadEmployee(ctx, r.d.ManagerId)
+}
+
+func (r *departmentResolver) Location(ctx context.Context) (*locationResolver, error) {
+	return loadLocation(ctx, r.d.LocationId)
+}
+
+func (r *departmentResolver) Employees(ctx context.Context) ([]*employeeResolver, error) {
+	employees, err := loadersFrom(ctx).EmployeesByDept.Load(ctx, *r.d.DepartmentId)()
+	if err != nil {
+		return nil, err
+	}
+	return employeeResolvers(ctx, employees), nil
+}
+
+type locationResolver struct {
+	l schema.LocationRecord
+}
+
+func (r *locationResolver) LocationId() int32      { return int32(*r.l.LocationId) }
+func (r *locationResolver) StreetAddress() *string { return r.l.StreetAddress }
+func (r *locationResolver) PostalCode() *string    { return r.l.PostalCode }
+func (r *locationResolver) City() *string          { return r.l.City }
+func (r *locationResolver) StateProvince() *string { return r.l.StateProvince }
+
+func (r *locationResolver) Country(ctx context.Context) (*countryResolver, error) {
+	if r.l.CountryId == nil {
+		return nil, nil
+	}
+	country, err := loadersFrom(ctx).Country.Load(ctx, *r.l.CountryId)()
+	if err != nil || country == nil {
+		return nil, err
+	}
+	return &countryResolver{*country}, nil
+}
+
+type countryResolver struct {
+	c schema.CountryRecord
+}
+
+func (r *countryResolver) CountryId() string    { return *r.c.CountryId }
+func (r *countryResolver) CountryName() *string { return r.c.CountryName }
+
+func (r *countryResolver) Region(ctx context.Context) (*regionResolver, error) {
+	if r.c.RegionId == nil {
+		return nil, nil
+	}
+	region, err := loadersFrom(ctx).Region.Load(ctx, *r.c.RegionId)()
+	if err != nil || region == nil {
+		return nil, err
+	}
+	return &regionResolver{*region}, nil
+}
+
+type regionResolver struct {
+	r schema.Region
+}
+
+func (r *regionResolver) RegionId() int32     { return int32(*r.r.RegionId) }
+func (r *regionResolver) RegionName() *string { return r.r.RegionName }
+
+func loadEmployee(ctx context.Context, employeeId *int) (*employeeResolver, error) {
+	if employeeId == nil {
+		return nil, nil
+	}
+	emp, err := loadersFrom(ctx).Employee.Load(ctx, *employeeId)()
+	if err != nil || emp == nil {
+		return nil, err
+	}
+	return &employeeResolver{*emp}, nil
+}
+
+func loadJob(ctx context.Context, jobId *string) (*jobResolver, error) {
+	if jobId == nil {
+		return nil, nil
+	}
+	job, err := loadersFrom(ctx).Job.Load(ctx, *jobId)()
+	if err != nil || job == nil {
+		return nil, err
+	}
+	return &jobResolver{*job}, nil
+}
+
+func loadDepartment(ctx context.Context, departmentId *int) (*departmentResolver, error) {
+	if departmentId == nil {
+		return nil, nil
+	}
+	department, err := loadersFrom(ctx).Department.Load(ctx, *departmentId)()
+	if err != nil || department == nil {
+		return nil, err
+	}
+	return &departmentResolver{*department}, nil
+}
+
+func loadLocation(ctx context.Context, locationId *int) (*locationResolver, error) {
+	if locationId == nil {
+		return nil, nil
+	}
+	location, err := loadersFrom(ctx).Location.Load(ctx, *locationId)()
+	if err != nil || location == nil {
+		return nil, err
+	}
+	return &locationResolver{*location}, nil
+}
+
+func intPtr(i int) *int {
+	return &i
+}
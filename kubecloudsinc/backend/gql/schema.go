@@ -0,0 +1,36 @@
+package gql
+
+import (
+	"database/sql"
+	_ "embed"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaString string
+
+// Query limits enforced on /v2/graphql.
+const (
+	MaxDepth      = 8
+	MaxComplexity = 2000
+)
+
+// NewSchema parses the HR schema and binds it to the resolvers.
+func NewSchema(db *sql.DB) (*graphql.Schema, error) {
+	return graphql.ParseSchema(schemaString, &Resolver{DB: db},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(MaxDepth),
+		graphql.MaxQueryLength(10000),
+		graphql.MaxParallelism(20),
+	)
+}
+
+// MustNewSchema is like NewSchema but panics if the schema and resolvers disagree.
+func MustNewSchema(db *sql.DB) *graphql.Schema {
+	s, err := NewSchema(db)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
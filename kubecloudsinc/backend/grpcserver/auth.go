@@ -0,0 +1,72 @@
+package grpcserver
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	pb "autotools-golang-api/kubecloudsinc/backend/proto/employeepb"
+	"context"
+	"log"
+	"strings"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methodPermissions lists the permission each method requires, matching the REST
+// routes. Methods missing from the map are rejected.
+var methodPermissions = map[string]string{
+	pb.EmployeeService_ListEmployees_FullMethodName:      policy.EmployeeRead,
+	pb.EmployeeService_GetEmployee_FullMethodName:        policy.EmployeeRead,
+	pb.EmployeeService_GetEmployeeProfile_FullMethodName: policy.EmployeeRead,
+	pb.EmployeeService_CreateEmployee_FullMethodName:     policy.EmployeeWrite,
+	pb.EmployeeService_UpdateEmployee_FullMethodName:     policy.EmployeeWrite,
+	pb.EmployeeService_DeleteEmployee_FullMethodName:     policy.EmployeeDelete,
+}
+
+// AuthInterceptor validates the bearer token in the "authorization" metadata, checks
+// that the caller's role holds the method's permission and stores the user in the
+// context the same way middleware.RequirePermission does for HTTP requests.
+func AuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	authHeader := md.Get("authorization")
+	if len(authHeader) == 0 || authHeader[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+	}
+
+	bearerToken := strings.Split(authHeader[0], "Bearer ")
+	if len(bearerToken) != 2 {
+		return nil, status.Error(codes.Unauthenticated, "Invalid Authorization token format")
+	}
+
+	claims, err := middleware.ParseToken(ctx, bearerToken[1])
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	permission, ok := methodPermissions[info.FullMethod]
+	if !ok || !policy.CurrentGrants.Has(claims.Role, permission) {
+		log.Printf("Insufficient permissions: user role %s is not allowed to call %s", claims.Role, info.FullMethod)
+		return nil, status.Errorf(codes.PermissionDenied, "Insufficient permissions: user role %s does not have %s", claims.Role, permission)
+	}
+
+	return handler(middleware.WithEndpoint(middleware.WithClaims(ctx, claims), info.FullMethod), req)
+}
+
+// NewRelicInterceptor starts a New Relic transaction per call so the dbs segments
+// and custom events are recorded as they are for HTTP requests.
+func NewRelicInterceptor(app *newrelic.Application) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		txn := app.StartTransaction(info.FullMethod)
+		defer txn.End()
+		txn.AddAttribute("grpcMethod", info.FullMethod)
+
+		resp, err := handler(newrelic.NewContext(ctx, txn), req)
+		if err != nil {
+			txn.AddAttribute("grpcStatusCode", status.Code(err).String())
+		}
+		return resp, err
+	}
+}
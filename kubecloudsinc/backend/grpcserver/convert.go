@@ -0,0 +1,143 @@
+package grpcserver
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	pb "autotools-golang-api/kubecloudsinc/backend/proto/employeepb"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+)
+
+// Conversions between the schema structs and their protobuf mirrors. Nil pointers
+// map to unset optional fields and back.
+
+func toProtoEmployee(e dbs.Employees) *pb.Employee {
+	return &pb.Employee{
+		EmployeeId:    toInt32(e.EmployeeId),
+		FirstName:     e.FirstName,
+		LastName:      e.LastName,
+		Email:         e.Email,
+		Phone:         e.Phone,
+		HireDate:      e.HireDate,
+		JobId:         e.JobId,
+		Salary:        e.Salary,
+		CommissionPct: e.CommissionPct,
+		ManagerId:     toInt32(e.ManagerId),
+		DepartmentId:  toInt32(e.DepartmentId),
+	}
+}
+
+func fromProtoEmployee(e *pb.Employee) schema.Employee {
+	return schema.Employee{
+		EmployeeId:    fromInt32(e.EmployeeId),
+		FirstName:     e.FirstName,
+		LastName:      e.LastName,
+		Email:         e.Email,
+		Phone:         e.Phone,
+		HireDate:      e.HireDate,
+		JobId:         e.JobId,
+		Salary:        e.Salary,
+		CommissionPct: e.CommissionPct,
+		ManagerId:     fromInt32(e.ManagerId),
+		DepartmentId:  fromInt32(e.DepartmentId),
+	}
+}
+
+func toProtoProfile(p *dbs.EmployeeProfile) *pb.EmployeeProfile {
+	profile := &pb.EmployeeProfile{
+		EmployeeId:    toInt32(p.EmployeeId),
+		FirstName:     p.FirstName,
+		LastName:      p.LastName,
+		Email:         p.Email,
+		Phone:         p.Phone,
+		Salary:        p.Salary,
+		CommissionPct: p.CommissionPct,
+		ManagerId:     toInt32(p.ManagerId),
+	}
+	if p.JobDetails == nil {
+		return profile
+	}
+
+	details := &pb.JobDetails{}
+	for _, j := range p.JobDetails.Jobs {
+		if j == nil {
+			continue
+		}
+		job := &pb.Job{
+			JobId:          j.JobId,
+			JobTitle:       j.JobTitle,
+			HireDate:       j.HireDate,
+			Salary:         j.Salary,
+			DepartmentId:   toInt32(j.DepartmentId),
+			DepartmentName: j.DepartmentName,
+		}
+		for _, h := range j.JobHistory {
+			if h == nil {
+				continue
+			}
+			job.JobHistory = append(job.JobHistory, &pb.JobHistory{
+				JobId:     h.JobId,
+				JobTitle:  h.JobTitle,
+				StartDate: h.StartDate,
+				EndDate:   h.EndDate,
+			})
+		}
+		details.Jobs = append(details.Jobs, job)
+	}
+	if m := p.JobDetails.Manager; m != nil {
+		details.Manager = &pb.Manager{
+			ManagerId:    toInt32(m.ManagerId),
+			ManagerFirst: m.ManagerFirst,
+			ManagerLast:  m.ManagerLast,
+		}
+	}
+	if d := p.JobDetails.Department; d != nil {
+		details.Department = &pb.Department{
+			DepartmentId:   toInt32(d.DepartmentId),
+			DepartmentName: d.DepartmentName,
+			Location:       toProtoLocation(d.Location),
+		}
+	}
+	profile.JobDetails = details
+	return profile
+}
+
+func toProtoLocation(l *schema.Location) *pb.Location {
+	if l == nil {
+		return nil
+	}
+	location := &pb.Location{
+		LocationId:    toInt32(l.LocationId),
+		StreetAddress: l.StreetAddress,
+		PostalCode:    l.PostalCode,
+		City:          l.City,
+		StateProvince: l.StateProvince,
+	}
+	if c := l.Country; c != nil {
+		location.Country = &pb.Country{
+			CountryId:   c.CountryId,
+			CountryName: c.CountryName,
+		}
+		if r := c.Region; r != nil {
+			location.Country.Region = &pb.Region{
+				RegionId:   toInt32(r.RegionId),
+				RegionName: r.RegionName,
+			}
+		}
+	}
+	return location
+}
+
+func toInt32(i *int) *int32 {
+	if i == nil {
+		return nil
+	}
+	v := int32(*i)
+	return &v
+}
+
+func fromInt32(i *int32) *int {
+	if i == nil {
+		return nil
+	}
+	v := int(*i)
+	return &v
+}
@@ -0,0 +1,60 @@
+package grpcserver
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MaskInterceptor applies the read policy for the caller's role to responses, the
+// way middleware.MaskReads does for REST. It must run after AuthInterceptor.
+func MaskInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	role, _ := ctx.Value(middleware.RoleContextKey).(string)
+	if err != nil || !policy.CurrentRead.Masks(role) {
+		return resp, err
+	}
+	if m, ok := resp.(proto.Message); ok {
+		maskMessage(role, m.ProtoReflect())
+	}
+	return resp, nil
+}
+
+// maskMessage masks the fields of m, and of the messages within it, whose JSON names
+// are masked for role. A banded field is cleared and its range set in <field>Band.
+func maskMessage(role string, m protoreflect.Message) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.IsMap():
+		case fd.Message() != nil && fd.IsList():
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				maskMessage(role, list.Get(j).Message())
+			}
+		case fd.Message() != nil:
+			if m.Has(fd) {
+				maskMessage(role, m.Get(fd).Message())
+			}
+		default:
+			var value *float64
+			if fd.Kind() == protoreflect.DoubleKind && m.Has(fd) {
+				v := m.Get(fd).Float()
+				value = &v
+			}
+			visible, band := policy.CurrentRead.Mask(role, fd.JSONName(), value)
+			if visible {
+				continue
+			}
+			m.Clear(fd)
+			if bandField := fields.ByJSONName(fd.JSONName() + "Band"); band != nil && bandField != nil {
+				m.Set(bandField, protoreflect.ValueOfString(*band))
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package grpcserver
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	pb "autotools-golang-api/kubecloudsinc/backend/proto/employeepb"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements pb.EmployeeServiceServer on top of the service package, so gRPC
+// and REST callers share the same validation, permission checks and change events.
+type Server struct {
+	pb.UnimplementedEmployeeServiceServer
+}
+
+// StartServer serves the gRPC API on addr until it fails.
+func StartServer(addr string, app *newrelic.Application) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(NewRelicInterceptor(app), AuthInterceptor, MaskInterceptor))
+	pb.RegisterEmployeeServiceServer(s, &Server{})
+
+	log.Printf("gRPC server starting on %s\n", addr)
+	return s.Serve(lis)
+}
+
+func (s *Server) ListEmployees(ctx context.Context, req *pb.ListEmployeesRequest) (*pb.ListEmployeesResponse, error) {
+	employees, err := service.ListEmployees(newrelic.FromContext(ctx), middleware.ActorFromContext(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return employeeList(employees), nil
+}
+
+func (s *Server) GetEmployee(ctx context.Context, req *pb.GetEmployeeRequest) (*pb.ListEmployeesResponse, error) {
+	if req.EmployeeId <= 0 && req.LastName == "" {
+		return nil, status.Error(codes.InvalidArgument, "employee_id or last_name is required")
+	}
+	employees, err := service.FindEmployees(newrelic.FromContext(ctx), int(req.EmployeeId), req.LastName, middleware.ActorFromContext(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return employeeList(employees), nil
+}
+
+func (s *Server) GetEmployeeProfile(ctx context.Context, req *pb.GetEmployeeProfileRequest) (*pb.EmployeeProfile, error) {
+	profile, err := service.GetEmployeeProfile(newrelic.FromContext(ctx), int(req.EmployeeId), middleware.ActorFromContext(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoProfile(profile), nil
+}
+
+func (s *Server) CreateEmployee(ctx context.Context, req *pb.CreateEmployeeRequest) (*pb.CreateEmployeeResponse, error) {
+	if req.Employee == nil {
+		return nil, status.Error(codes.InvalidArgument, "employee is required")
+	}
+	emp := fromProtoEmployee(req.Employee)
+	employeeId, err := service.CreateEmployee(newrelic.FromContext(ctx), &emp, middleware.ActorFromContext(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CreateEmployeeResponse{EmployeeId: int32(employeeId)}, nil
+}
+
+func (s *Server) UpdateEmployee(ctx context.Context, req *pb.UpdateEmployeeRequest) (*pb.Employee, error) {
+	if req.Employee == nil {
+		return nil, status.Error(codes.InvalidArgument, "employee is required")
+	}
+	emp := fromProtoEmployee(req.Employee)
+	accepted, err := service.UpdateEmployee(newrelic.FromContext(ctx), int(req.EmployeeId), &emp, middleware.ActorFromContext(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if accepted != nil {
+		grpc.SetHeader(ctx, metadata.Pairs("change-request-id", strconv.Itoa(accepted.ChangeRequestId)))
+	}
+	return toProtoEmployee(dbs.Employees(emp)), nil
+}
+
+func (s *Server) DeleteEmployee(ctx context.Context, req *pb.DeleteEmployeeRequest) (*pb.DeleteEmployeeResponse, error) {
+	if err := service.DeleteEmployee(newrelic.FromContext(ctx), int(req.EmployeeId), middleware.ActorFromContext(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteEmployeeResponse{}, nil
+}
+
+// toStatus maps service and dbs errors to the gRPC codes matching the REST statuses.
+func toStatus(err error) error {
+	var validationErr *service.ValidationError
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &validationErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &permissionErr):
+		return permissionStatus(permissionErr)
+	case errors.Is(err, dbs.ErrEmployeeNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// permissionStatus reports each write policy violation as an ErrorInfo detail.
+func permissionStatus(err *service.PermissionError) error {
+	st := status.New(codes.PermissionDenied, err.Error())
+	for _, v := range err.Violations {
+		withDetail, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   v.Reason,
+			Domain:   "write-policy",
+			Metadata: map[string]string{"field": v.Field, "operation": v.Operation, "role": v.Role},
+		})
+		if detailErr != nil {
+			log.Printf("Failed to attach write policy violation: %v", detailErr)
+			break
+		}
+		st = withDetail
+	}
+	return st.Err()
+}
+
+func employeeList(employees []dbs.Employees) *pb.ListEmployeesResponse {
+	resp := &pb.ListEmployeesResponse{Employees: make([]*pb.Employee, len(employees))}
+	for i, e := range employees {
+		resp.Employees[i] = toProtoEmployee(e)
+	}
+	return resp
+}
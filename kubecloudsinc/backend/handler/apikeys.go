@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/apikeys"
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func GetAPIKeys(store apikeys.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		keys, err := service.ListAPIKeys(r.Context(), store)
+		if err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetAPIKeys")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(keys); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetAPIKeys")
+		}
+	}
+}
+
+func GetAPIKey(store apikeys.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		keyId, ok := keyIdFromPath(w, r, "GetAPIKey")
+		if !ok {
+			return
+		}
+		key, err := service.GetAPIKey(r.Context(), store, keyId)
+		if err != nil {
+			sendAPIKeyError(w, r, err, "GetAPIKey")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(key); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetAPIKey")
+		}
+	}
+}
+
+// AddAPIKey issues a key. The response is the only time the key is shown.
+func AddAPIKey(store apikeys.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var input schema.NewAPIKey
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			log.Printf("Failed to decode API key: %v", err)
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddAPIKey")
+			return
+		}
+
+		created, err := service.CreateAPIKey(r.Context(), store, input, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendAPIKeyError(w, r, err, "AddAPIKey")
+			return
+		}
+
+		if txn != nil {
+			txn.Application().RecordCustomEvent("AddAPIKeyCompleted", map[string]interface{}{
+				"keyId": created.KeyId,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(created); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
+
+func RevokeAPIKey(store apikeys.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		keyId, ok := keyIdFromPath(w, r, "RevokeAPIKey")
+		if !ok {
+			return
+		}
+		if err := service.RevokeAPIKey(r.Context(), store, keyId, middleware.ActorFromContext(r.Context())); err != nil {
+			sendAPIKeyError(w, r, err, "RevokeAPIKey")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"message": "API key successfully revoked"}); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "RevokeAPIKey")
+		}
+	}
+}
+
+func keyIdFromPath(w http.ResponseWriter, r *http.Request, location string) (int, bool) {
+	keyId, err := strconv.Atoi(mux.Vars(r)["keyId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidKeyIDFormat", location)
+		return 0, false
+	}
+	return keyId, true
+}
+
+// sendAPIKeyError maps the errors of the API key service to responses.
+func sendAPIKeyError(w http.ResponseWriter, r *http.Request, err error, location string) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", location)
+	case errors.Is(err, dbs.ErrAPIKeyNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", location)
+	case errors.Is(err, dbs.ErrAPIKeyExists):
+		utils.SendErrorResponse(w, r, http.StatusConflict, err, "unique_error_id", "APIKeyExists", location)
+	default:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "APIKeyStoreError", location)
+	}
+}
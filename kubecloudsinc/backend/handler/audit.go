@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// GetAuditLog lists audit entries filtered by employeeId, actor, field and a from/to
+// time range, newest first.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	query := r.URL.Query()
+	filter := dbs.AuditFilter{
+		Actor: query.Get("actor"),
+		Field: query.Get("field"),
+	}
+	var err error
+	if value := query.Get("employeeId"); value != "" {
+		if filter.EmployeeId, err = strconv.Atoi(value); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidEmployeeIDFormat", "GetAuditLog")
+			return
+		}
+	}
+	if value := query.Get("from"); value != "" {
+		if filter.From, err = service.ParseAuditTime(value, false); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetAuditLog")
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if filter.To, err = service.ParseAuditTime(value, true); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetAuditLog")
+			return
+		}
+	}
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetAuditLog")
+			return
+		}
+	}
+
+	entries, err := service.QueryAuditLog(txn, filter, limit)
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetAuditLog")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetAuditLog")
+		return
+	}
+
+	if txn != nil {
+		txn.Application().RecordCustomEvent("GetAuditLogCompleted", map[string]interface{}{
+			"entries": len(entries),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetAuditLog")
+	}
+}
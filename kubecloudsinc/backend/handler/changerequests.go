@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func GetChangeRequests(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	// Only requests waiting for review are listed unless another status is asked for
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = schema.ChangeRequestPending
+	} else if status == "all" {
+		status = ""
+	}
+
+	employeeId := 0
+	if employeeIdStr := r.URL.Query().Get("employeeId"); employeeIdStr != "" {
+		var err error
+		employeeId, err = strconv.Atoi(employeeIdStr)
+		if err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidEmployeeIDFormat", "GetChangeRequests")
+			return
+		}
+	}
+
+	requests, err := service.ListChangeRequests(txn, status, employeeId)
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetChangeRequests")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetChangeRequests")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(requests); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetChangeRequests")
+	}
+}
+
+func GetChangeRequest(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	changeRequestId, err := strconv.Atoi(mux.Vars(r)["changeRequestId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidChangeRequestIDFormat", "GetChangeRequest")
+		return
+	}
+
+	request, err := service.GetChangeRequest(txn, changeRequestId, middleware.ActorFromContext(r.Context()))
+	switch {
+	case errors.Is(err, dbs.ErrChangeRequestNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "GetChangeRequest")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetChangeRequest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(request); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetChangeRequest")
+	}
+}
+
+func ApproveChangeRequest(w http.ResponseWriter, r *http.Request) {
+	reviewChangeRequest(w, r, true, "ApproveChangeRequest")
+}
+
+func RejectChangeRequest(w http.ResponseWriter, r *http.Request) {
+	reviewChangeRequest(w, r, false, "RejectChangeRequest")
+}
+
+// reviewChangeRequest handles both review decisions; they differ only in whether the
+// update is applied.
+func reviewChangeRequest(w http.ResponseWriter, r *http.Request, approve bool, location string) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	changeRequestId, err := strconv.Atoi(mux.Vars(r)["changeRequestId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidChangeRequestIDFormat", location)
+		return
+	}
+
+	// The body is optional when approving
+	var review schema.ChangeRequestReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil && err != io.EOF {
+		log.Printf("Error decoding change request review: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "RequestBodyDecodeError", location)
+		return
+	}
+
+	request, err := service.ReviewChangeRequest(txn, changeRequestId, approve, &review, middleware.ActorFromContext(r.Context()))
+	var validationErr *service.ValidationError
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", location)
+		return
+	case errors.As(err, &permissionErr):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", location)
+		return
+	case errors.Is(err, dbs.ErrChangeRequestNotFound), errors.Is(err, dbs.ErrEmployeeNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", location)
+		return
+	case errors.Is(err, dbs.ErrChangeRequestClosed):
+		utils.SendErrorResponse(w, r, http.StatusConflict, err, "unique_error_id", "ChangeRequestClosed", location)
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "ChangeRequestReviewError", location)
+		return
+	}
+
+	if txn != nil {
+		txn.Application().RecordCustomEvent(location+"Completed", map[string]interface{}{
+			"changeRequestId": changeRequestId,
+			"employeeId":      request.EmployeeId,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(request); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", location)
+	}
+}
@@ -4,11 +4,10 @@ import (
 	"autotools-golang-api/kubecloudsinc/backend/dbs"
 	"autotools-golang-api/kubecloudsinc/backend/middleware"
 	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
 	"autotools-golang-api/kubecloudsinc/backend/utils"
 	"errors"
-	"regexp"
 	"strings"
-	"time"
 
 	"encoding/json"
 	"fmt"
@@ -32,7 +31,30 @@ func GetEmployees(w http.ResponseWriter, r *http.Request) {
 		txn.AddAttribute("httpMethod", r.Method)
 	}
 
-	employees, err := dbs.QueryEmployees(txn, dbs.DB)
+	// ?fields= and ?expand= switch to the projected query
+	fields, expand := splitList(r.URL.Query().Get("fields")), splitList(r.URL.Query().Get("expand"))
+	var employees interface{}
+	var count int
+	var err error
+	if len(fields) > 0 || len(expand) > 0 {
+		var records []dbs.Record
+		records, err = service.ListEmployeesProjected(txn, 0, "", fields, expand, middleware.ActorFromContext(r.Context()))
+		employees, count = records, len(records)
+	} else {
+		var all []dbs.Employees
+		all, err = service.ListEmployees(txn, middleware.ActorFromContext(r.Context()))
+		employees, count = all, len(all)
+	}
+	var validationErr *service.ValidationError
+	var permissionErr *service.PermissionError
+	if errors.As(err, &validationErr) {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "hagsv123", "InvalidQueryParameter", "Employee Retrieval")
+		return
+	}
+	if errors.As(err, &permissionErr) {
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "hagsv123", "InsufficientPermissions", "Employee Retrieval")
+		return
+	}
 	if err != nil {
 		log.Printf("Error querying all employees: %v", err)
 		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "hagsv123", "NoMatchingRecordFound", "Employee Retrieval")
@@ -42,7 +64,7 @@ func GetEmployees(w http.ResponseWriter, r *http.Request) {
 	// Record a custom event after successfully querying all employees
 	if txn != nil {
 		txn.Application().RecordCustomEvent("GetEmployeesCompleted", map[string]interface{}{
-			"count": len(employees),
+			"count": count,
 		})
 	}
 
@@ -83,10 +105,26 @@ func GetEmployee(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	log.Printf("Fetching employee with ID: %d and lastName: %s", employeeId, lastName)
-	employees, err := dbs.QueryEmployee(txn, dbs.DB, employeeId, lastName)
+	var employees interface{}
+	var count int
+	fields, expand := splitList(queryValues.Get("fields")), splitList(queryValues.Get("expand"))
+	if len(fields) > 0 || len(expand) > 0 {
+		var records []dbs.Record
+		records, err = service.ListEmployeesProjected(txn, employeeId, lastName, fields, expand, middleware.ActorFromContext(r.Context()))
+		employees, count = records, len(records)
+	} else {
+		var found []dbs.Employees
+		found, err = service.FindEmployees(txn, employeeId, lastName, middleware.ActorFromContext(r.Context()))
+		employees, count = found, len(found)
+	}
 	if err != nil {
-		if err.Error() == "no record was found with provided identifiers" {
+		var validationErr *service.ValidationError
+		var permissionErr *service.PermissionError
+		if errors.As(err, &validationErr) {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "QueryEmployee")
+		} else if errors.As(err, &permissionErr) {
+			utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "QueryEmployee")
+		} else if errors.Is(err, dbs.ErrEmployeeNotFound) {
 			utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "QueryEmployee")
 		} else {
 			// Handle other errors
@@ -98,7 +136,7 @@ func GetEmployee(w http.ResponseWriter, r *http.Request) {
 	// Record a custom event after successfully querying the employee
 	if txn != nil {
 		txn.Application().RecordCustomEvent("GetEmployeeCompleted", map[string]interface{}{
-			"count": len(employees),
+			"count": count,
 		})
 	}
 
@@ -128,13 +166,7 @@ func AddEmployee(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := validateAddEmployeeInput(&emp); err != nil {
-		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddEmployee")
-		return
-	}
-
-	// Assuming `db` is your database connection available globally or passed in some way
-	employeeId, err := dbs.InsertEmployee(txn, dbs.DB, dbs.Employees(emp))
+	employeeId, err := service.CreateEmployee(txn, &emp, middleware.ActorFromContext(r.Context()))
 
 	// Record a custom event after successfully querying all employees
 	if txn != nil {
@@ -143,6 +175,16 @@ func AddEmployee(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddEmployee")
+		return
+	}
+	var permissionErr *service.PermissionError
+	if errors.As(err, &permissionErr) {
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "AddEmployee")
+		return
+	}
 	if err != nil {
 		errorMessage := fmt.Sprintf("Failed to add employee: %v", err)
 		log.Println(errorMessage)
@@ -150,7 +192,6 @@ func AddEmployee(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Employee added with ID: %d", employeeId) // Log success
 	successMessage := fmt.Sprintf("Employee with EmployeeId: %d successfully Added", employeeId)
 
 	response := map[string]string{"message": successMessage}
@@ -165,8 +206,7 @@ func AddEmployee(w http.ResponseWriter, r *http.Request) {
 
 func UpdateEmployee(w http.ResponseWriter, r *http.Request) {
 	txn := newrelic.FromContext(r.Context())
-	userRole, ok := r.Context().Value(middleware.RoleContextKey).(string)
-	if !ok {
+	if _, ok := r.Context().Value(middleware.RoleContextKey).(string); !ok {
 		utils.SendErrorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("user role not found"), "unique_error_id", "UserRoleNotFound", "UpdateEmployee")
 		return
 	}
@@ -198,31 +238,38 @@ func UpdateEmployee(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := validateUpdateEmployeeInput(&emp); err != nil {
-		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddEmployee")
+	accepted, err := service.UpdateEmployee(txn, employeeId, &emp, middleware.ActorFromContext(r.Context()))
+	var validationErr *service.ValidationError
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "UpdateEmployee")
+		return
+	case errors.As(err, &permissionErr):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "UpdateEmployee")
+		return
+	case errors.Is(err, dbs.ErrEmployeeNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "UpdateEmployee")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "EmployeeUpdateError", "UpdateEmployee")
 		return
 	}
 
-	proceedWithUpdate := true
-	var restrictedFields = make([]string, 0)
-
-	if userRole == "editor" {
-		restrictedFields = checkRestrictedFields(emp)
-		if len(restrictedFields) > 0 {
-			errMsg := fmt.Sprintf("You don't have enough permissions to update these fields: %s", strings.Join(restrictedFields, ", "))
-			log.Println(errMsg)
-			utils.SendErrorResponse(w, r, http.StatusForbidden, fmt.Errorf(errMsg), "unique_error_id", "InsufficientPermissions", "UpdateEmployee")
-			proceedWithUpdate = false
+	// Restricted changes by editors wait for an admin to approve them
+	if accepted != nil {
+		if txn != nil {
+			txn.Application().RecordCustomEvent("UpdateEmployeeHeldForApproval", map[string]interface{}{
+				"employeeId":      employeeId,
+				"changeRequestId": accepted.ChangeRequestId,
+			})
 		}
-	} // No else if needed here, admin has full access and others are already blocked by middleware
-
-	if proceedWithUpdate {
-		if err := dbs.UpdateEmployeeDB(txn, dbs.DB, employeeId, dbs.Employees(emp)); err != nil {
-			log.Printf("Error updating employee with ID %d: %v", employeeId, err)
-			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "EmployeeUpdateError", "UpdateEmployee")
-			return
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(accepted); err != nil {
+			log.Printf("Error encoding response: %v", err)
 		}
-		log.Printf("Employee with ID %d successfully updated", employeeId)
+		return
 	}
 
 	// Record a custom event after successfully updating the employee
@@ -233,12 +280,10 @@ func UpdateEmployee(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	if proceedWithUpdate {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(emp); err != nil {
-			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "UpdateEmployee")
-		}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(emp); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "UpdateEmployee")
 	}
 }
 
@@ -264,12 +309,17 @@ func DeleteEmployee(w http.ResponseWriter, r *http.Request) {
 		txn.AddAttribute("httpMethod", r.Method)
 	}
 
-	log.Printf("Attempting to delete employee with ID: %d", employeeId)
-
-	// Call a function to delete the employee by ID. This function needs to be implemented.
-	err = dbs.DeleteEmployeeByID(txn, dbs.DB, employeeId)
+	err = service.DeleteEmployee(txn, employeeId, middleware.ActorFromContext(r.Context()))
+	if errors.Is(err, dbs.ErrEmployeeNotFound) {
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "DeleteEmployee")
+		return
+	}
+	var permissionErr *service.PermissionError
+	if errors.As(err, &permissionErr) {
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "DeleteEmployee")
+		return
+	}
 	if err != nil {
-		log.Printf("Error deleting employee with ID %d: %v", employeeId, err)
 		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "EmployeeDeletionError", "DeleteEmployee")
 		return
 	}
@@ -282,7 +332,6 @@ func DeleteEmployee(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	log.Printf("Employee with ID %d successfully deleted", employeeId)
 	w.Header().Set("Content-Type", "application/json")
 
 	w.WriteHeader(http.StatusOK)
@@ -314,12 +363,32 @@ func GetEmployeeProfile(w http.ResponseWriter, r *http.Request) {
 		txn.AddAttribute("httpMethod", r.Method)
 	}
 
-	log.Printf("Attempting to get employee profile with ID: %d", employeeId)
-
-	// Query database to get employee profile
-	employeeProfile, err := dbs.GetEmployeeProfile(txn, dbs.DB, employeeId)
+	// Without ?fields= or ?expand= the full profile is returned; ?asOf= returns the
+	// employee record as it was at that time instead
+	var employeeProfile interface{}
+	fields, expand := splitList(r.URL.Query().Get("fields")), splitList(r.URL.Query().Get("expand"))
+	asOf := r.URL.Query().Get("asOf")
+	if asOf != "" && (len(fields) > 0 || len(expand) > 0) {
+		err = errors.New("asOf cannot be combined with fields or expand")
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetEmployeeProfile")
+		return
+	}
+	actor := middleware.ActorFromContext(r.Context())
+	if asOf != "" {
+		employeeProfile, err = service.GetEmployeeAsOf(txn, employeeId, asOf, actor)
+	} else if len(fields) > 0 || len(expand) > 0 {
+		employeeProfile, err = service.GetEmployeeProfileProjected(txn, employeeId, fields, expand, actor)
+	} else {
+		employeeProfile, err = service.GetEmployeeProfile(txn, employeeId, actor)
+	}
 	if err != nil {
-		if err.Error() == "no record was found with provided identifiers" {
+		var validationErr *service.ValidationError
+		var permissionErr *service.PermissionError
+		if errors.As(err, &validationErr) {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetEmployeeProfile")
+		} else if errors.As(err, &permissionErr) {
+			utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "GetEmployeeProfile")
+		} else if errors.Is(err, dbs.ErrEmployeeNotFound) {
 			utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "GetEmployeeProfile")
 		} else {
 			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetEmployeeProfile")
@@ -343,152 +412,65 @@ func GetEmployeeProfile(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// validateEmployeeInput validates the fields of Employee
-func validateAddEmployeeInput(emp *schema.Employee) error {
-	validJobIDs := validJobIDs()
-	if emp.FirstName == nil || *emp.FirstName == "" {
-		return errors.New("first name is required")
-	}
-	if emp.LastName == nil || *emp.LastName == "" {
-		return errors.New("last name is required")
-	}
-	if emp.Email == nil || *emp.Email == "" {
-		return errors.New("email is required")
-	} else if err := validateEmail(*emp.Email); err != nil {
-		return err
-	}
-	if emp.Phone == nil || *emp.Phone == "" {
-		return errors.New("phone number is required")
-	} else {
-		normalizedPhone, err := normalizePhoneNumber(*emp.Phone)
-		if err != nil {
-			return err
-		}
-		*emp.Phone = normalizedPhone // Update the employee's phone number to the normalized format
-	}
-	if emp.HireDate == nil || *emp.HireDate == "" {
-		return errors.New("hire date is required")
-	} else if _, err := time.Parse("2006-01-02 15:04:05", *emp.HireDate); err != nil {
-		_, err := time.Parse("2006-01-02", *emp.HireDate)
-		if err != nil {
-			return fmt.Errorf("invalid date format for hire date: %v", err)
-		}
-	}
-	if emp.JobId == nil || *emp.JobId == "" {
-		return errors.New("job ID is required")
-	} else if _, exists := validJobIDs[*emp.JobId]; !exists {
-		return fmt.Errorf("invalid job ID: %s", *emp.JobId)
-	}
-	if emp.Salary == nil {
-		return errors.New("salary is required")
-	}
-	return nil
-}
-
-func validateUpdateEmployeeInput(emp *schema.Employee) error {
-	validJobIDs := validJobIDs()
-	if emp.Email != nil && *emp.Email != "" {
-		if err := validateEmail(*emp.Email); err != nil {
-			return err
+// splitList parses a comma-separated query parameter, ignoring blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-
-	if emp.Phone != nil && *emp.Phone != "" {
-		normalizedPhone, err := normalizePhoneNumber(*emp.Phone)
-		if err != nil {
-			return err
-		}
-		*emp.Phone = normalizedPhone
-	}
-
-	if emp.HireDate != nil && *emp.HireDate != "" {
-		_, err := time.Parse("2006-01-02 15:04:05", *emp.HireDate)
-		if err != nil {
-			_, err := time.Parse("2006-01-02", *emp.HireDate)
-			if err != nil {
-				return fmt.Errorf("invalid date format for hire date: %v", err)
-			}
-		}
-	}
-
-	if emp.JobId == nil || *emp.JobId == "" {
-		return errors.New("job ID is required")
-	} else if _, exists := validJobIDs[*emp.JobId]; !exists {
-		return fmt.Errorf("invalid job ID: %s", *emp.JobId)
-	}
-
-	return nil
+	return items
 }
 
-// validateEmail checks if the email address is valid
-func validateEmail(email string) error {
-	// Regular expression to validate email according to the specified rules
-	regexPattern := `^(?:[a-zA-Z0-9]+[_\.-]?)*[a-zA-Z0-9]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z]{2,})+$`
-	match, _ := regexp.MatchString(regexPattern, email)
-	if !match {
-		return errors.New("invalid email format")
+// BulkUpdateEmployees applies changes to many employees in one transaction. It answers
+// 200 when the changes were applied or a dry run succeeded, and 422 with the
+// per-employee results when any of them failed and nothing was written.
+func BulkUpdateEmployees(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.Application().RecordCustomEvent("BulkUpdateEmployeesAttempt", map[string]interface{}{})
+		txn.AddAttribute("httpMethod", r.Method)
 	}
-	return nil
-}
 
-// validatePhone checks if the phone number is valid
-func normalizePhoneNumber(phone string) (string, error) {
-	reg, err := regexp.Compile("[^0-9]+")
-	if err != nil {
-		return "", err
+	var req schema.BulkUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding bulk update request: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "RequestBodyDecodeError", "BulkUpdateEmployees")
+		return
 	}
-	normalizedPhone := reg.ReplaceAllString(phone, "")
 
-	if len(normalizedPhone) != 10 {
-		return "", fmt.Errorf("phone number does not have 10 digits: %s", normalizedPhone)
+	resp, err := service.BulkUpdateEmployees(txn, &req, middleware.ActorFromContext(r.Context()))
+	var validationErr *service.ValidationError
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "BulkUpdateEmployees")
+		return
+	case errors.As(err, &permissionErr):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "BulkUpdateEmployees")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "EmployeeUpdateError", "BulkUpdateEmployees")
+		return
 	}
 
-	reformattedPhone := fmt.Sprintf("%s.%s.%s", normalizedPhone[0:3], normalizedPhone[3:6], normalizedPhone[6:])
-	return reformattedPhone, nil
-}
-
-func validJobIDs() map[string]bool {
-	return map[string]bool{
-		"AC_MGR":     true,
-		"AC_ACCOUNT": true,
-		"AD_ASST":    true,
-		"AD_PRES":    true,
-		"AD_VP":      true,
-		"FI_ACCOUNT": true,
-		"FI_MGR":     true,
-		"HR_REP":     true,
-		"IT_PROG":    true,
-		"MK_MAN":     true,
-		"MK_REP":     true,
-		"PR_REP":     true,
-		"PU_CLERK":   true,
-		"PU_MAN":     true,
-		"SA_MAN":     true,
-		"SA_REP":     true,
-		"SH_CLERK":   true,
-		"ST_CLERK":   true,
-		"ST_MAN":     true,
+	if txn != nil {
+		txn.Application().RecordCustomEvent("BulkUpdateEmployeesCompleted", map[string]interface{}{
+			"matched": resp.Matched,
+			"failed":  resp.Failed,
+			"dryRun":  resp.DryRun,
+			"applied": resp.Applied,
+		})
 	}
-}
-
-func checkRestrictedFields(emp schema.Employee) []string {
-	var restrictedFields []string
 
-	if emp.Salary != nil {
-		restrictedFields = append(restrictedFields, "salary")
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusUnprocessableEntity
 	}
-	if emp.HireDate != nil {
-		restrictedFields = append(restrictedFields, "hireDate")
-	}
-	if emp.CommissionPct != nil {
-		restrictedFields = append(restrictedFields, "commissionPct")
-	}
-	if emp.ManagerId != nil {
-		restrictedFields = append(restrictedFields, "managerId")
-	}
-	if emp.DepartmentId != nil {
-		restrictedFields = append(restrictedFields, "departmentId")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "BulkUpdateEmployees")
 	}
-
-	return restrictedFields
 }
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/events"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// scopeRefresh is how long a scoped stream trusts its copy of the reporting tree.
+const scopeRefresh = 30 * time.Second
+
+// StreamEvents serves the change feed as text/event-stream. Clients resume after a
+// disconnect with the Last-Event-ID header (or ?lastEventId= for EventSource
+// polyfills) and may narrow the feed with ?types=employee.updated,...; an "reset"
+// event tells the client that events were missed and it should reload its data.
+// Callers scoped to their reports only receive events about their reporting tree.
+func StreamEvents(broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"), "unique_error_id", "StreamingUnsupported", "StreamEvents")
+			return
+		}
+
+		lastEventIdStr := r.Header.Get("Last-Event-ID")
+		if lastEventIdStr == "" {
+			lastEventIdStr = r.URL.Query().Get("lastEventId")
+		}
+		var lastEventId int64
+		if lastEventIdStr != "" {
+			var err error
+			lastEventId, err = strconv.ParseInt(lastEventIdStr, 10, 64)
+			if err != nil {
+				utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidLastEventID", "StreamEvents")
+				return
+			}
+		}
+
+		var types map[string]bool
+		if typesStr := r.URL.Query().Get("types"); typesStr != "" {
+			types = make(map[string]bool)
+			for _, t := range strings.Split(typesStr, ",") {
+				types[strings.TrimSpace(t)] = true
+			}
+		}
+
+		actor := middleware.ActorFromContext(r.Context())
+		scope, err := service.ReadScope(actor)
+		if err != nil {
+			utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "StreamEvents")
+			return
+		}
+		visible := &eventScope{txn: newrelic.FromContext(r.Context()), scope: scope}
+		if err := visible.reload(); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "StreamEvents")
+			return
+		}
+
+		replay, complete, live, unsubscribe := broker.Subscribe(lastEventId)
+		defer unsubscribe()
+
+		log.Printf("Event stream opened for %s (lastEventId=%d, replay=%d)", actor.Username, lastEventId, len(replay))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if !complete {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, event := range replay {
+			if err := writeEvent(w, event, actor.Role, types, visible); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				log.Printf("Event stream closed for %s", actor.Username)
+				return
+			case event, ok := <-live:
+				if !ok {
+					// Dropped by the broker for falling behind; the client reconnects
+					// with Last-Event-ID and replays from the buffer.
+					return
+				}
+				if err := writeEvent(w, event, actor.Role, types, visible); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event schema.ChangeEvent, role string, types map[string]bool, visible *eventScope) error {
+	if (types != nil && !types[event.Type]) || !visible.allows(event) {
+		return nil
+	}
+	data, err := json.Marshal(event)
+	if err == nil {
+		// Mask what the caller's role may not read in the before/after images
+		data, err = policy.CurrentRead.MaskJSON(role, data)
+	}
+	if err != nil {
+		log.Printf("Error encoding event %d: %v", event.EventId, err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.EventId, event.Type, data)
+	return err
+}
+
+// eventScope decides which events a stream limited to a reporting tree receives: those
+// about an employee in the tree, or about one reporting into it before or after the
+// change, so moves into and out of the tree are both seen. The tree is reloaded every
+// scopeRefresh so reorganizations reach streams that stay open.
+type eventScope struct {
+	txn      *newrelic.Transaction
+	scope    dbs.Scope
+	members  map[int]bool
+	loadedAt time.Time
+}
+
+func (s *eventScope) reload() error {
+	members, err := dbs.QueryScopeMembers(s.txn, dbs.DB, s.scope)
+	if err != nil {
+		return err
+	}
+	s.members, s.loadedAt = members, time.Now()
+	return nil
+}
+
+func (s *eventScope) allows(event schema.ChangeEvent) bool {
+	if s.scope.IsZero() {
+		return true
+	}
+	if time.Since(s.loadedAt) > scopeRefresh {
+		// Keep the previous tree until the database answers again
+		if err := s.reload(); err != nil {
+			log.Printf("Failed to reload the event stream scope: %v", err)
+		}
+	}
+	if s.members[event.EmployeeId] {
+		return true
+	}
+	for _, emp := range []*schema.Employee{event.Before, event.After} {
+		if emp != nil && emp.ManagerId != nil && s.members[*emp.ManagerId] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/gql"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GraphQL executes queries against the HR schema. Depth is limited by the schema and
+// the estimated complexity is checked here before any resolver runs.
+func GraphQL(gqlSchema *graphql.Schema) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var req graphqlRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "GraphQL")
+			return
+		}
+		if req.Query == "" {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, fmt.Errorf("query is required"), "unique_error_id", "InvalidRequestBody", "GraphQL")
+			return
+		}
+
+		// Scoped roles only see their reporting tree, in every part of the graph
+		scope, err := service.ReadScope(middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "GraphQL")
+			return
+		}
+
+		var response *graphql.Response
+		complexity, err := gql.Complexity(req.Query, req.OperationName, req.Variables)
+		switch {
+		case err != nil:
+			response = &graphql.Response{Errors: []*gqlerrors.QueryError{gqlerrors.Errorf("%s", err)}}
+		case complexity > gql.MaxComplexity:
+			log.Printf("Rejected GraphQL query with complexity %d", complexity)
+			response = &graphql.Response{Errors: []*gqlerrors.QueryError{gqlerrors.Errorf("query complexity %d exceeds the limit of %d", complexity, gql.MaxComplexity)}}
+		default:
+			if txn != nil {
+				txn.AddAttribute("graphqlComplexity", complexity)
+			}
+			ctx := gql.WithLoaders(r.Context(), gql.NewLoaders(dbs.DB, scope))
+			response = gqlSchema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Error encoding GraphQL response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// GetEmployeeTimeline lists the hire, job changes and audited mutations of an
+// employee in chronological order.
+func GetEmployeeTimeline(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	employeeId, err := strconv.Atoi(mux.Vars(r)["employeeId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidEmployeeIDFormat", "GetEmployeeTimeline")
+		return
+	}
+
+	timeline, err := service.GetEmployeeTimeline(txn, employeeId, middleware.ActorFromContext(r.Context()))
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &permissionErr):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "GetEmployeeTimeline")
+		return
+	case errors.Is(err, dbs.ErrEmployeeNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "GetEmployeeTimeline")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetEmployeeTimeline")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(timeline); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetEmployeeTimeline")
+	}
+}
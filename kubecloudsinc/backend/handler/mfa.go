@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/mfa"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// GetOwnMFA says whether the caller has MFA and whether their role requires it.
+func GetOwnMFA(store users.Store, mfaStore mfa.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		user, err := service.OwnUser(r.Context(), store, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendMFAError(w, r, err, "GetOwnMFA")
+			return
+		}
+		status, err := service.GetMFAStatus(r.Context(), mfaStore, user)
+		if err != nil {
+			sendMFAError(w, r, err, "GetOwnMFA")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetOwnMFA")
+		}
+	}
+}
+
+// EnrollOwnMFA starts the caller's enrollment with a new TOTP secret.
+func EnrollOwnMFA(store users.Store, mfaStore mfa.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		user, err := service.OwnUser(r.Context(), store, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendMFAError(w, r, err, "EnrollOwnMFA")
+			return
+		}
+		enrollment, err := service.BeginMFAEnrollment(r.Context(), mfaStore, user)
+		if err != nil {
+			sendMFAError(w, r, err, "EnrollOwnMFA")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(enrollment); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
+
+// ConfirmOwnMFA enables the caller's MFA with a first code and returns the recovery
+// codes, which are not shown again.
+func ConfirmOwnMFA(store users.Store, mfaStore mfa.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var input schema.MFACode
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "ConfirmOwnMFA")
+			return
+		}
+		user, err := service.OwnUser(r.Context(), store, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendMFAError(w, r, err, "ConfirmOwnMFA")
+			return
+		}
+		codes, err := service.ConfirmMFAEnrollment(r.Context(), mfaStore, user, input.Code)
+		if err != nil {
+			sendMFAError(w, r, err, "ConfirmOwnMFA")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(codes); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "ConfirmOwnMFA")
+		}
+	}
+}
+
+// DisableOwnMFA turns off the caller's MFA with a code or a recovery code.
+func DisableOwnMFA(store users.Store, mfaStore mfa.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var input schema.MFACode
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "DisableOwnMFA")
+			return
+		}
+		user, err := service.OwnUser(r.Context(), store, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendMFAError(w, r, err, "DisableOwnMFA")
+			return
+		}
+		if err := service.DisableMFA(r.Context(), mfaStore, user, input); err != nil {
+			sendMFAError(w, r, err, "DisableOwnMFA")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"message": "MFA successfully turned off"}); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "DisableOwnMFA")
+		}
+	}
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes.
+func RegenerateRecoveryCodes(store users.Store, mfaStore mfa.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var input schema.MFACode
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "RegenerateRecoveryCodes")
+			return
+		}
+		user, err := service.OwnUser(r.Context(), store, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendMFAError(w, r, err, "RegenerateRecoveryCodes")
+			return
+		}
+		codes, err := service.RegenerateRecoveryCodes(r.Context(), mfaStore, user, input)
+		if err != nil {
+			sendMFAError(w, r, err, "RegenerateRecoveryCodes")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(codes); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "RegenerateRecoveryCodes")
+		}
+	}
+}
+
+// GetUserMFA says whether a user has MFA, for admins.
+func GetUserMFA(store users.Store, mfaStore mfa.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		userId, ok := userIdFromPath(w, r, "GetUserMFA")
+		if !ok {
+			return
+		}
+		user, err := store.Get(r.Context(), userId)
+		if err != nil {
+			sendMFAError(w, r, err, "GetUserMFA")
+			return
+		}
+		status, err := service.GetMFAStatus(r.Context(), mfaStore, user)
+		if err != nil {
+			sendMFAError(w, r, err, "GetUserMFA")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetUserMFA")
+		}
+	}
+}
+
+// ResetUserMFA removes the MFA of a user who lost their device.
+func ResetUserMFA(store users.Store, mfaStore mfa.Store, sessionStore sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		userId, ok := userIdFromPath(w, r, "ResetUserMFA")
+		if !ok {
+			return
+		}
+		if err := service.ResetMFA(r.Context(), store, mfaStore, sessionStore, userId, middleware.ActorFromContext(r.Context())); err != nil {
+			sendMFAError(w, r, err, "ResetUserMFA")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"message": "MFA successfully reset"}); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "ResetUserMFA")
+		}
+	}
+}
+
+// sendMFAError maps the errors of the MFA service to responses. A wrong code is a 403
+// rather than a 401, since the caller's token is fine.
+func sendMFAError(w http.ResponseWriter, r *http.Request, err error, location string) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", location)
+	case errors.Is(err, service.ErrInvalidMFACode):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InvalidMFACode", location)
+	case errors.Is(err, service.ErrMFARequired), errors.Is(err, users.ErrUserDisabled):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "MFARequired", location)
+	case errors.Is(err, dbs.ErrUserNotFound), errors.Is(err, dbs.ErrMFANotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", location)
+	case errors.Is(err, dbs.ErrMFAEnabled):
+		utils.SendErrorResponse(w, r, http.StatusConflict, err, "unique_error_id", "MFAEnabled", location)
+	default:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "MFAStoreError", location)
+	}
+}
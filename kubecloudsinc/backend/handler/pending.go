@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func AddPendingChange(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	employeeId, err := strconv.Atoi(mux.Vars(r)["employeeId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidEmployeeIDFormat", "AddPendingChange")
+		return
+	}
+
+	var req schema.PendingChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding pending change: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "RequestBodyDecodeError", "AddPendingChange")
+		return
+	}
+
+	actor := middleware.ActorFromContext(r.Context())
+	changeId, err := service.SchedulePendingChange(txn, employeeId, &req, actor)
+	var validationErr *service.ValidationError
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddPendingChange")
+		return
+	case errors.As(err, &permissionErr):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "AddPendingChange")
+		return
+	case errors.Is(err, dbs.ErrEmployeeNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "AddPendingChange")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "PendingChangeInsertionError", "AddPendingChange")
+		return
+	}
+
+	if txn != nil {
+		txn.Application().RecordCustomEvent("AddPendingChangeCompleted", map[string]interface{}{
+			"employeeId":    employeeId,
+			"changeId":      changeId,
+			"effectiveDate": *req.EffectiveDate,
+		})
+	}
+
+	change := schema.PendingChange{
+		ChangeId:      changeId,
+		EmployeeId:    employeeId,
+		EffectiveDate: *req.EffectiveDate,
+		Changes:       *req.Changes,
+		Status:        schema.PendingChangePending,
+		CreatedBy:     actor,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(change); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func GetPendingChanges(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	employeeId, err := strconv.Atoi(mux.Vars(r)["employeeId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidEmployeeIDFormat", "GetPendingChanges")
+		return
+	}
+
+	// Only changes that are still waiting are listed unless another status is asked for
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = schema.PendingChangePending
+	} else if status == "all" {
+		status = ""
+	}
+
+	changes, err := service.ListPendingChanges(txn, employeeId, status, middleware.ActorFromContext(r.Context()))
+	var validationErr *service.ValidationError
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetPendingChanges")
+		return
+	case errors.As(err, &permissionErr):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "GetPendingChanges")
+		return
+	case errors.Is(err, dbs.ErrEmployeeNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "GetPendingChanges")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetPendingChanges")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(changes); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetPendingChanges")
+	}
+}
+
+func CancelPendingChange(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	vars := mux.Vars(r)
+	employeeId, err := strconv.Atoi(vars["employeeId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidEmployeeIDFormat", "CancelPendingChange")
+		return
+	}
+	changeId, err := strconv.Atoi(vars["changeId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidChangeIDFormat", "CancelPendingChange")
+		return
+	}
+
+	err = service.CancelPendingChange(txn, employeeId, changeId, middleware.ActorFromContext(r.Context()))
+	var permissionErr *service.PermissionError
+	switch {
+	case errors.As(err, &permissionErr):
+		utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "InsufficientPermissions", "CancelPendingChange")
+		return
+	case errors.Is(err, dbs.ErrPendingChangeNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "CancelPendingChange")
+		return
+	case errors.Is(err, dbs.ErrPendingChangeClosed):
+		utils.SendErrorResponse(w, r, http.StatusConflict, err, "unique_error_id", "PendingChangeClosed", "CancelPendingChange")
+		return
+	case err != nil:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "PendingChangeCancellationError", "CancelPendingChange")
+		return
+	}
+
+	if txn != nil {
+		txn.Application().RecordCustomEvent("CancelPendingChangeCompleted", map[string]interface{}{
+			"employeeId": employeeId,
+			"changeId":   changeId,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Pending change cancelled successfully"}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// GetSecurityEvents lists security events filtered by type, username, ipAddress and a
+// from/to time range, newest first.
+func GetSecurityEvents(store loginguard.EventStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		query := r.URL.Query()
+		filter := dbs.SecurityEventFilter{
+			Type:      query.Get("type"),
+			Username:  query.Get("username"),
+			IpAddress: query.Get("ipAddress"),
+		}
+		var err error
+		if value := query.Get("from"); value != "" {
+			if filter.From, err = service.ParseAuditTime(value, false); err != nil {
+				utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetSecurityEvents")
+				return
+			}
+		}
+		if value := query.Get("to"); value != "" {
+			if filter.To, err = service.ParseAuditTime(value, true); err != nil {
+				utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetSecurityEvents")
+				return
+			}
+		}
+		limit := 0
+		if value := query.Get("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil {
+				utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetSecurityEvents")
+				return
+			}
+		}
+
+		events, err := service.QuerySecurityEvents(r.Context(), store, filter, limit)
+		var validationErr *service.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidQueryParameter", "GetSecurityEvents")
+			return
+		case err != nil:
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetSecurityEvents")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(events); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetSecurityEvents")
+		}
+	}
+}
+
+// UnlockLogin lifts the login lockout of a username or an IP address.
+func UnlockLogin(guard *loginguard.Guard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var input schema.LoginUnlock
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			log.Printf("Failed to decode login unlock: %v", err)
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "UnlockLogin")
+			return
+		}
+
+		err := service.UnlockLogin(r.Context(), guard, input, middleware.ActorFromContext(r.Context()))
+		var validationErr *service.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "UnlockLogin")
+			return
+		case err != nil:
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "LockoutStoreError", "UnlockLogin")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"message": "Login lockout successfully lifted"}); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "UnlockLogin")
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func GetUsers(store users.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		list, err := service.ListUsers(r.Context(), store)
+		if err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetUsers")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetUsers")
+		}
+	}
+}
+
+func GetUser(store users.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		userId, ok := userIdFromPath(w, r, "GetUser")
+		if !ok {
+			return
+		}
+		user, err := service.GetUser(r.Context(), store, userId)
+		if err != nil {
+			sendUserError(w, r, err, "GetUser")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetUser")
+		}
+	}
+}
+
+func AddUser(store users.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var input schema.NewUser
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			log.Printf("Failed to decode user: %v", err)
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddUser")
+			return
+		}
+
+		user, err := service.CreateUser(r.Context(), store, input, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendUserError(w, r, err, "AddUser")
+			return
+		}
+
+		if txn != nil {
+			txn.Application().RecordCustomEvent("AddUserCompleted", map[string]interface{}{
+				"userId": user.UserId,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
+
+// UpdateUser replaces the role, employee link and disabled flag of a user.
+func UpdateUser(store users.Store, sessionStore sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		userId, ok := userIdFromPath(w, r, "UpdateUser")
+		if !ok {
+			return
+		}
+		var update schema.UserUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			log.Printf("Failed to decode user update: %v", err)
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "UpdateUser")
+			return
+		}
+
+		user, err := service.UpdateUser(r.Context(), store, sessionStore, userId, update, middleware.ActorFromContext(r.Context()))
+		if err != nil {
+			sendUserError(w, r, err, "UpdateUser")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "UpdateUser")
+		}
+	}
+}
+
+func DeleteUser(store users.Store, sessionStore sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		userId, ok := userIdFromPath(w, r, "DeleteUser")
+		if !ok {
+			return
+		}
+		if err := service.DeleteUser(r.Context(), store, sessionStore, userId, middleware.ActorFromContext(r.Context())); err != nil {
+			sendUserError(w, r, err, "DeleteUser")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"message": "User successfully deleted"}); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "DeleteUser")
+		}
+	}
+}
+
+// ResetUserPassword sets a new password for another user.
+func ResetUserPassword(store users.Store, sessionStore sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		userId, ok := userIdFromPath(w, r, "ResetUserPassword")
+		if !ok {
+			return
+		}
+		var reset schema.PasswordReset
+		if err := json.NewDecoder(r.Body).Decode(&reset); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "ResetUserPassword")
+			return
+		}
+
+		if err := service.ResetPassword(r.Context(), store, sessionStore, userId, reset, middleware.ActorFromContext(r.Context())); err != nil {
+			sendUserError(w, r, err, "ResetUserPassword")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"message": "Password successfully reset"}); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "ResetUserPassword")
+		}
+	}
+}
+
+// ChangeOwnPassword lets the caller replace their password by giving the current one.
+// Every session of the caller ends, so they log in again with the new password. Wrong
+// current passwords count against the login lockout, which answers 429 while it holds.
+func ChangeOwnPassword(store users.Store, sessionStore sessions.Store, guard *loginguard.Guard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txn := newrelic.FromContext(r.Context())
+		if txn != nil {
+			txn.AddAttribute("httpMethod", r.Method)
+		}
+
+		var change schema.PasswordChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "ChangeOwnPassword")
+			return
+		}
+
+		err := service.ChangeOwnPassword(r.Context(), store, sessionStore, guard, middleware.ClientIP(r), change, middleware.ActorFromContext(r.Context()))
+		var lockedErr *service.LockedOutError
+		switch {
+		case errors.As(err, &lockedErr):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			utils.SendErrorResponse(w, r, http.StatusTooManyRequests, err, "unique_error_id", "TooManyFailedLogins", "ChangeOwnPassword")
+			return
+		case errors.Is(err, users.ErrInvalidCredentials):
+			// The token is valid, so this is a 403 rather than a 401 that would send
+			// the client back to the login
+			utils.SendErrorResponse(w, r, http.StatusForbidden, errors.New("the current password is wrong"), "unique_error_id", "InvalidCurrentPassword", "ChangeOwnPassword")
+			return
+		case errors.Is(err, users.ErrUserDisabled):
+			utils.SendErrorResponse(w, r, http.StatusForbidden, err, "unique_error_id", "UserDisabled", "ChangeOwnPassword")
+			return
+		case err != nil:
+			sendUserError(w, r, err, "ChangeOwnPassword")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]string{"message": "Password successfully changed"}); err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "ChangeOwnPassword")
+		}
+	}
+}
+
+func userIdFromPath(w http.ResponseWriter, r *http.Request, location string) (int, bool) {
+	userId, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidUserIDFormat", location)
+		return 0, false
+	}
+	return userId, true
+}
+
+// sendUserError maps the errors of the user service to responses.
+func sendUserError(w http.ResponseWriter, r *http.Request, err error, location string) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", location)
+	case errors.Is(err, dbs.ErrUserNotFound):
+		utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", location)
+	case errors.Is(err, dbs.ErrUserExists):
+		utils.SendErrorResponse(w, r, http.StatusConflict, err, "unique_error_id", "UserExists", location)
+	default:
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "UserStoreError", location)
+	}
+}
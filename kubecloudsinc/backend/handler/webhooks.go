@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"autotools-golang-api/kubecloudsinc/backend/webhooks"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func AddWebhook(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	var sub schema.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		log.Printf("Failed to decode webhook subscription: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddWebhook")
+		return
+	}
+
+	if err := validateWebhookInput(r.Context(), &sub); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "AddWebhook")
+		return
+	}
+
+	// Generate a signing secret when the caller did not supply one
+	if sub.Secret == nil || *sub.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "SecretGenerationError", "AddWebhook")
+			return
+		}
+		sub.Secret = &secret
+	}
+	createdBy := middleware.ActorFromContext(r.Context()).Username
+	sub.CreatedBy = &createdBy
+
+	subscriptionId, err := dbs.InsertWebhookSubscription(txn, dbs.DB, sub)
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "WebhookInsertionError", "AddWebhook")
+		return
+	}
+
+	if txn != nil {
+		txn.Application().RecordCustomEvent("AddWebhookCompleted", map[string]interface{}{
+			"subscriptionId": subscriptionId,
+		})
+	}
+
+	// The secret is only ever returned in this response
+	active := true
+	sub.SubscriptionId = &subscriptionId
+	sub.Active = &active
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(sub); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	subscriptions, err := dbs.QueryWebhookSubscriptions(txn, dbs.DB)
+	if err != nil {
+		log.Printf("Error querying webhook subscriptions: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetWebhooks")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(subscriptions); err != nil {
+		log.Printf("Error encoding webhook subscriptions to JSON: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetWebhooks")
+	}
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	subscriptionIdStr := mux.Vars(r)["subscriptionId"]
+	subscriptionId, err := strconv.Atoi(subscriptionIdStr)
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidSubscriptionIDFormat", "DeleteWebhook")
+		return
+	}
+
+	if err := dbs.DeleteWebhookSubscription(txn, dbs.DB, subscriptionId); err != nil {
+		if errors.Is(err, dbs.ErrWebhookSubscriptionNotFound) {
+			utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "DeleteWebhook")
+		} else {
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "WebhookDeletionError", "DeleteWebhook")
+		}
+		return
+	}
+
+	log.Printf("Webhook subscription %d deleted", subscriptionId)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Webhook subscription successfully deleted"}); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "DeleteWebhook")
+	}
+}
+
+// GetWebhookDeliveries lists recent deliveries; ?status=dead returns the dead-letter list.
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != schema.DeliveryPending && status != schema.DeliveryInFlight && status != schema.DeliveryDelivered && status != schema.DeliveryDead {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid status: %s", status), "unique_error_id", "InvalidQueryParameter", "GetWebhookDeliveries")
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > 1000 {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and 1000"), "unique_error_id", "InvalidQueryParameter", "GetWebhookDeliveries")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := dbs.QueryWebhookDeliveries(txn, dbs.DB, status, limit)
+	if err != nil {
+		log.Printf("Error querying webhook deliveries: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "QueryError", "GetWebhookDeliveries")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+		log.Printf("Error encoding webhook deliveries to JSON: %v", err)
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "GetWebhookDeliveries")
+	}
+}
+
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	txn := newrelic.FromContext(r.Context())
+	if txn != nil {
+		txn.AddAttribute("httpMethod", r.Method)
+	}
+
+	deliveryIdStr := mux.Vars(r)["deliveryId"]
+	deliveryId, err := strconv.Atoi(deliveryIdStr)
+	if err != nil {
+		utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidDeliveryIDFormat", "RedeliverWebhook")
+		return
+	}
+
+	if err := dbs.RequeueWebhookDelivery(txn, dbs.DB, deliveryId); err != nil {
+		switch {
+		case errors.Is(err, dbs.ErrWebhookDeliveryNotFound):
+			utils.SendErrorResponse(w, r, http.StatusNotFound, err, "unique_error_id", "NoMatchingRecordFound", "RedeliverWebhook")
+		case errors.Is(err, dbs.ErrWebhookDeliveryNotRequeueable):
+			utils.SendErrorResponse(w, r, http.StatusConflict, err, "unique_error_id", "WebhookDeliveryNotRequeueable", "RedeliverWebhook")
+		default:
+			utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "WebhookRedeliveryError", "RedeliverWebhook")
+		}
+		return
+	}
+
+	if txn != nil {
+		txn.Application().RecordCustomEvent("RedeliverWebhookCompleted", map[string]interface{}{
+			"deliveryId": deliveryId,
+		})
+	}
+
+	log.Printf("Webhook delivery %d queued for redelivery", deliveryId)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{"message": "Webhook delivery queued for redelivery"}); err != nil {
+		utils.SendErrorResponse(w, r, http.StatusInternalServerError, err, "unique_error_id", "JSONEncodingError", "RedeliverWebhook")
+	}
+}
+
+func validateWebhookInput(ctx context.Context, sub *schema.WebhookSubscription) error {
+	if sub.URL == nil || *sub.URL == "" {
+		return errors.New("url is required")
+	}
+	u, err := url.Parse(*sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url: %s", *sub.URL)
+	}
+	if err := webhooks.CheckHost(ctx, u.Hostname()); err != nil {
+		return err
+	}
+	if len(sub.EventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	validEventTypes := validEventTypes()
+	for _, eventType := range sub.EventTypes {
+		if !validEventTypes[eventType] {
+			return fmt.Errorf("invalid event type: %s", eventType)
+		}
+	}
+	if sub.Secret != nil && *sub.Secret != "" && len(*sub.Secret) < 16 {
+		return errors.New("secret must be at least 16 characters")
+	}
+	return nil
+}
+
+func validEventTypes() map[string]bool {
+	return map[string]bool{
+		"*":                         true,
+		schema.EventEmployeeCreated: true,
+		schema.EventEmployeeUpdated: true,
+		schema.EventEmployeeDeleted: true,
+
+		schema.EventDepartmentMembersChanged: true,
+	}
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
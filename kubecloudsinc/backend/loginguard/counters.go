@@ -0,0 +1,103 @@
+// Package loginguard protects /v2/login against password guessing. It counts login
+// attempts per username and per client address, slows down the answers to failures,
+// locks a username or address out for a while once it failed too often, and records
+// these as security events.
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Counters count login attempts and hold lockouts by key, such as "user:mazda" or
+// "ip:10.0.0.7". An attempt is counted before the password or code is checked, so
+// parallel guesses cannot all slip in before the first of them fails. Every instance
+// of the service must share the counters for the limits to hold across instances.
+type Counters interface {
+	// Attempt counts an attempt of key and returns the attempts counted, unless key is
+	// locked: then it returns how long the lockout remains and counts nothing. When
+	// max attempts are already counted, it locks key for lockout, forgets its attempts
+	// and returns max+1 with the lockout. Counting and comparing are one operation.
+	// The count is forgotten window after its first attempt.
+	Attempt(ctx context.Context, key string, max int, window, lockout time.Duration) (int, time.Duration, error)
+	// Release uncounts an attempt of key that turned out not to be a guess.
+	Release(ctx context.Context, key string) error
+	// Reset forgets the attempts and lockout of key.
+	Reset(ctx context.Context, key string) error
+}
+
+// sweepInterval is the least time between two removals of expired counters.
+const sweepInterval = time.Minute
+
+type counter struct {
+	attempts    int
+	resetAt     time.Time
+	lockedUntil time.Time
+}
+
+// MemoryCounters keeps counters in memory. Each instance of the service counts on its
+// own, so it suits a single instance or local development.
+type MemoryCounters struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	swept    time.Time
+}
+
+func NewMemoryCounters() *MemoryCounters {
+	return &MemoryCounters{counters: make(map[string]*counter)}
+}
+
+func (c *MemoryCounters) Attempt(ctx context.Context, key string, max int, window, lockout time.Duration) (int, time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.sweep(now)
+	entry, ok := c.counters[key]
+	if !ok {
+		entry = &counter{}
+		c.counters[key] = entry
+	}
+	if remaining := entry.lockedUntil.Sub(now); remaining > 0 {
+		return 0, remaining, nil
+	}
+	if !now.Before(entry.resetAt) {
+		entry.attempts, entry.resetAt = 0, now.Add(window)
+	}
+	if entry.attempts >= max {
+		entry.attempts, entry.lockedUntil = 0, now.Add(lockout)
+		return max + 1, lockout, nil
+	}
+	entry.attempts++
+	return entry.attempts, 0, nil
+}
+
+func (c *MemoryCounters) Release(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.counters[key]; ok && entry.attempts > 0 {
+		entry.attempts--
+	}
+	return nil
+}
+
+func (c *MemoryCounters) Reset(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counters, key)
+	return nil
+}
+
+// sweep removes the counters whose attempts and lockout have both expired, so
+// guessed usernames do not pile up.
+func (c *MemoryCounters) sweep(now time.Time) {
+	if now.Sub(c.swept) < sweepInterval {
+		return
+	}
+	c.swept = now
+	for key, entry := range c.counters {
+		if !now.Before(entry.resetAt) && !now.Before(entry.lockedUntil) {
+			delete(c.counters, key)
+		}
+	}
+}
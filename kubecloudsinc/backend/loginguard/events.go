@@ -0,0 +1,88 @@
+package loginguard
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// EventStore keeps security events.
+type EventStore interface {
+	// Record appends event.
+	Record(ctx context.Context, event schema.SecurityEvent) error
+	// Query returns up to limit events matching filter, newest first.
+	Query(ctx context.Context, filter dbs.SecurityEventFilter, limit int) ([]schema.SecurityEvent, error)
+}
+
+// IsEventType reports whether eventType is a security event type.
+func IsEventType(eventType string) bool {
+	switch eventType {
+	case schema.SecurityEventLoginFailed, schema.SecurityEventLoginLockout, schema.SecurityEventLoginUnlocked:
+		return true
+	}
+	return false
+}
+
+// SQLEventStore keeps security events in the security_events table created by
+// migrations/011_security_events.sql.
+type SQLEventStore struct {
+	DB *sql.DB
+}
+
+func (s *SQLEventStore) Record(ctx context.Context, event schema.SecurityEvent) error {
+	return dbs.InsertSecurityEvent(newrelic.FromContext(ctx), s.DB, event)
+}
+
+func (s *SQLEventStore) Query(ctx context.Context, filter dbs.SecurityEventFilter, limit int) ([]schema.SecurityEvent, error) {
+	return dbs.QuerySecurityEvents(newrelic.FromContext(ctx), s.DB, filter, limit)
+}
+
+// maxMemoryEvents is how many events a MemoryEventStore keeps; older ones are dropped.
+const maxMemoryEvents = 10000
+
+// MemoryEventStore keeps the latest security events in memory, next to a
+// users.MemoryStore for local development. Its contents are lost on restart.
+type MemoryEventStore struct {
+	mu     sync.Mutex
+	events []schema.SecurityEvent
+	nextId int64
+}
+
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{nextId: 1}
+}
+
+func (s *MemoryEventStore) Record(ctx context.Context, event schema.SecurityEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event.EventId = s.nextId
+	s.nextId++
+	s.events = append(s.events, event)
+	if len(s.events) > maxMemoryEvents {
+		s.events = append([]schema.SecurityEvent(nil), s.events[len(s.events)-maxMemoryEvents:]...)
+	}
+	return nil
+}
+
+func (s *MemoryEventStore) Query(ctx context.Context, filter dbs.SecurityEventFilter, limit int) ([]schema.SecurityEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := []schema.SecurityEvent{}
+	for i := len(s.events) - 1; i >= 0 && len(events) < limit; i-- {
+		event := s.events[i]
+		switch {
+		case filter.Type != "" && event.Type != filter.Type,
+			filter.Username != "" && (event.Username == nil || *event.Username != filter.Username),
+			filter.IpAddress != "" && (event.IpAddress == nil || *event.IpAddress != filter.IpAddress),
+			filter.From != nil && event.OccurredAt.Before(*filter.From),
+			filter.To != nil && !event.OccurredAt.Before(*filter.To):
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,168 @@
+package loginguard
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Guard applies policy.CurrentLockout to logins. Attempts are counted per username,
+// whatever its case, and per client address. When Counters fail, logins are let
+// through and the error is logged: an unreachable Redis must not lock everyone out.
+type Guard struct {
+	Counters Counters
+	Events   EventStore
+}
+
+func NewGuard(counters Counters, events EventStore) *Guard {
+	return &Guard{Counters: counters, Events: events}
+}
+
+// limit is the failure limit of one counter of a login.
+type limit struct {
+	key         string
+	maxFailures int
+	what        string
+}
+
+func usernameKey(username string) string { return "user:" + strings.ToLower(username) }
+func ipKey(ip string) string             { return "ip:" + ip }
+
+// limits returns the counters a login of username from ip is held to. An empty
+// username is only counted by its address.
+func limits(p *policy.LockoutPolicy, username, ip string) []limit {
+	var list []limit
+	if username != "" {
+		list = append(list, limit{usernameKey(username), p.UsernameMaxFailures, "username"})
+	}
+	return append(list, limit{ipKey(ip), p.IPMaxFailures, "address"})
+}
+
+// Attempt reserves a login attempt of username from ip before its password or code is
+// checked. It returns how long the login is refused, 0 when it may go ahead, and the
+// most attempts counted for it, which Failed turns into a delay. A username or address
+// that already used up its attempts is locked out and refused.
+func (g *Guard) Attempt(ctx context.Context, username, ip string) (time.Duration, int) {
+	p := policy.CurrentLockout
+	var reserved []string
+	most := 0
+	for _, l := range limits(p, username, ip) {
+		attempts, retryAfter, err := g.Counters.Attempt(ctx, l.key, l.maxFailures, p.Window, p.LockoutDuration)
+		if err != nil {
+			log.Printf("Error counting a login attempt: %v", err)
+			continue
+		}
+		if retryAfter > 0 {
+			if attempts > 0 {
+				detail := fmt.Sprintf("%s locked for %s after %d failed logins", l.what, p.LockoutDuration, attempts-1)
+				log.Printf("Login lockout: %s from %s", detail, ip)
+				g.record(ctx, schema.SecurityEvent{Type: schema.SecurityEventLoginLockout, Username: optional(username), IpAddress: optional(ip), Detail: detail})
+			}
+			// The login is not made, so neither are the attempts reserved for it
+			for _, key := range reserved {
+				g.release(ctx, key)
+			}
+			return retryAfter, 0
+		}
+		reserved = append(reserved, l.key)
+		if attempts > most {
+			most = attempts
+		}
+	}
+	return 0, most
+}
+
+// Failed records a failed login of username from ip, whose attempt stays counted, and
+// returns how long to hold back the answer after attempts.
+func (g *Guard) Failed(ctx context.Context, username, ip, reason string, attempts int) time.Duration {
+	g.record(ctx, schema.SecurityEvent{Type: schema.SecurityEventLoginFailed, Username: optional(username), IpAddress: optional(ip), Detail: reason})
+	return policy.CurrentLockout.Delay(attempts)
+}
+
+// Refused records a login of username from ip refused for reason although its password
+// was right, such as that of a disabled user. Its attempt stays counted.
+func (g *Guard) Refused(ctx context.Context, username, ip, reason string) {
+	g.record(ctx, schema.SecurityEvent{Type: schema.SecurityEventLoginFailed, Username: optional(username), IpAddress: optional(ip), Detail: reason})
+}
+
+// Challenged releases the attempt of the address once the password is right and the
+// second factor is asked for. The attempt of the username stays counted until the login
+// succeeds, so a known password cannot be used to clear the guesses of codes.
+func (g *Guard) Challenged(ctx context.Context, ip string) {
+	g.release(ctx, ipKey(ip))
+}
+
+// Succeeded forgets the attempts of username and releases the attempt of the address.
+// The other attempts of the address are kept, so a guesser cannot clear them by logging
+// into an account of their own.
+func (g *Guard) Succeeded(ctx context.Context, username, ip string) {
+	if err := g.Counters.Reset(ctx, usernameKey(username)); err != nil {
+		log.Printf("Error resetting failed logins: %v", err)
+	}
+	g.release(ctx, ipKey(ip))
+}
+
+// Cancel releases the attempts of a login that was no guess, because it was malformed
+// or failed on an error of the service.
+func (g *Guard) Cancel(ctx context.Context, username, ip string) {
+	for _, l := range limits(policy.CurrentLockout, username, ip) {
+		g.release(ctx, l.key)
+	}
+}
+
+func (g *Guard) release(ctx context.Context, key string) {
+	if err := g.Counters.Release(ctx, key); err != nil {
+		log.Printf("Error releasing a login attempt: %v", err)
+	}
+}
+
+// Unlock lifts the lockout and forgets the attempts of username, of ip, or of both,
+// and records that actor did so.
+func (g *Guard) Unlock(ctx context.Context, username, ip, actor string) error {
+	var keys, what []string
+	if username != "" {
+		keys, what = append(keys, usernameKey(username)), append(what, "username")
+	}
+	if ip != "" {
+		keys, what = append(keys, ipKey(ip)), append(what, "address")
+	}
+	for _, key := range keys {
+		if err := g.Counters.Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	g.record(ctx, schema.SecurityEvent{Type: schema.SecurityEventLoginUnlocked, Username: optional(username), IpAddress: optional(ip),
+		Actor: optional(actor), Detail: strings.Join(what, " and ") + " unlocked"})
+	return nil
+}
+
+// record stores a security event. A failure is only logged; it must not change the
+// outcome of the login.
+func (g *Guard) record(ctx context.Context, event schema.SecurityEvent) {
+	event.OccurredAt = time.Now().UTC()
+	if err := g.Events.Record(ctx, event); err != nil {
+		log.Printf("Error recording a %s security event: %v", event.Type, err)
+	}
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Wait holds back the answer to a failed attempt for d, the delay Failed returned, or
+// until the client gives up.
+func Wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,87 @@
+package loginguard
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestAttemptHoldsTheLimitUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	events := NewMemoryEventStore()
+	guard := NewGuard(NewMemoryCounters(), events)
+	limit := policy.CurrentLockout.UsernameMaxFailures
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed, refused := 0, 0
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retryAfter, attempts := guard.Attempt(ctx, "Mazda", "203.0.113.7")
+			if retryAfter == 0 {
+				// Every attempt that got through fails, like a wrong password
+				guard.Failed(ctx, "mazda", "203.0.113.7", "invalid credentials", attempts)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if retryAfter > 0 {
+				refused++
+			} else {
+				allowed++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != limit || refused != 40-limit {
+		t.Fatalf("allowed %d and refused %d attempts, want %d and %d", allowed, refused, limit, 40-limit)
+	}
+	lockouts, err := events.Query(ctx, dbs.SecurityEventFilter{Type: schema.SecurityEventLoginLockout}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 1 {
+		t.Fatalf("got %d lockout events, want 1", len(lockouts))
+	}
+}
+
+func TestSucceededForgetsTheUsernameAttempts(t *testing.T) {
+	ctx := context.Background()
+	guard := NewGuard(NewMemoryCounters(), NewMemoryEventStore())
+	limit := policy.CurrentLockout.UsernameMaxFailures
+
+	for i := 0; i < limit-1; i++ {
+		if retryAfter, _ := guard.Attempt(ctx, "mazda", "203.0.113.7"); retryAfter > 0 {
+			t.Fatalf("attempt %d was refused", i+1)
+		}
+	}
+	guard.Succeeded(ctx, "mazda", "203.0.113.7")
+	for i := 0; i < limit; i++ {
+		if retryAfter, _ := guard.Attempt(ctx, "mazda", "203.0.113.7"); retryAfter > 0 {
+			t.Fatalf("attempt %d after the success was refused", i+1)
+		}
+	}
+	if retryAfter, _ := guard.Attempt(ctx, "mazda", "203.0.113.7"); retryAfter == 0 {
+		t.Fatal("attempt past the limit was let through")
+	}
+}
+
+func TestRefusedAttemptReleasesTheOtherCounters(t *testing.T) {
+	ctx := context.Background()
+	counters := NewMemoryCounters()
+	guard := NewGuard(counters, NewMemoryEventStore())
+	limit := policy.CurrentLockout.UsernameMaxFailures
+
+	for i := 0; i <= limit; i++ {
+		guard.Attempt(ctx, "mazda", "203.0.113.7")
+	}
+	// The address took limit attempts; the refused one must not count against it
+	if got := counters.counters[ipKey("203.0.113.7")].attempts; got != limit {
+		t.Fatalf("address has %d attempts, want %d", got, limit)
+	}
+}
@@ -0,0 +1,89 @@
+package loginguard
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// attemptScript counts an attempt unless the key is locked, and locks it instead once
+// the limit is reached, in one step so concurrent attempts cannot pass the limit
+// together. KEYS are the attempts and the lock; ARGV the limit, the window and the
+// lockout in milliseconds.
+var attemptScript = redis.NewScript(`
+local locked = redis.call('PTTL', KEYS[2])
+if locked > 0 then
+	return {0, locked}
+end
+local attempts = tonumber(redis.call('GET', KEYS[1]) or '0')
+if attempts >= tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[2], 1, 'PX', ARGV[3])
+	redis.call('DEL', KEYS[1])
+	return {attempts + 1, tonumber(ARGV[3])}
+end
+attempts = redis.call('INCR', KEYS[1])
+if attempts == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return {attempts, 0}
+`)
+
+// releaseScript uncounts an attempt without going below zero or touching the expiry.
+var releaseScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[1]) or '0') > 0 then
+	redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+// RedisCounters keeps counters in Redis, shared by every instance of the service.
+// Attempts and lockouts are keys that expire on their own.
+type RedisCounters struct {
+	Client *redis.Client
+	// Prefix starts every key, so several services can share a Redis database.
+	Prefix string
+}
+
+// NewRedisCounters connects to the Redis server of url, such as
+// redis://:password@redis:6379/0, and checks that it answers.
+func NewRedisCounters(ctx context.Context, url string) (*RedisCounters, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %v", err)
+	}
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to reach Redis: %v", err)
+	}
+	return &RedisCounters{Client: client, Prefix: "loginguard:"}, nil
+}
+
+// The keys of one counter share a hash tag, so the scripts also run on Redis Cluster.
+func (c *RedisCounters) attemptsKey(key string) string { return c.Prefix + "{" + key + "}:attempts" }
+func (c *RedisCounters) lockKey(key string) string     { return c.Prefix + "{" + key + "}:lock" }
+
+func (c *RedisCounters) Attempt(ctx context.Context, key string, max int, window, lockout time.Duration) (int, time.Duration, error) {
+	result, err := attemptScript.Run(ctx, c.Client, []string{c.attemptsKey(key), c.lockKey(key)},
+		max, window.Milliseconds(), lockout.Milliseconds()).Int64Slice()
+	if err != nil || len(result) != 2 {
+		return 0, 0, fmt.Errorf("failed to count an attempt of %s: %v", key, err)
+	}
+	return int(result[0]), time.Duration(result[1]) * time.Millisecond, nil
+}
+
+func (c *RedisCounters) Release(ctx context.Context, key string) error {
+	if err := releaseScript.Run(ctx, c.Client, []string{c.attemptsKey(key)}).Err(); err != nil {
+		return fmt.Errorf("failed to release an attempt of %s: %v", key, err)
+	}
+	return nil
+}
+
+func (c *RedisCounters) Reset(ctx context.Context, key string) error {
+	if err := c.Client.Del(ctx, c.attemptsKey(key), c.lockKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to reset %s: %v", key, err)
+	}
+	return nil
+}
@@ -3,9 +3,27 @@
 package main
 
 import (
+	"autotools-golang-api/kubecloudsinc/backend/apikeys"
 	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/events"
+	"autotools-golang-api/kubecloudsinc/backend/grpcserver"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/mfa"
 	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/oidc"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
 	"autotools-golang-api/kubecloudsinc/backend/server"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/signing"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"autotools-golang-api/kubecloudsinc/backend/webhooks"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
 
 	"log"
 	"os"
@@ -15,8 +33,21 @@ import (
 
 var dsn string
 var appName, appKey string
+var eventPublisher string
+var grpcPort string
+var writePolicyFile, readPolicyFile, rolesFile, passwordPolicyFile string
+var usersFile string
+var signingKeyFile, jwtIssuer, jwtAudience string
+var verificationKeyFiles []string
+var oidcConfigFile string
+var lockoutPolicyFile, loginCounters string
+var trustedProxies []*net.IPNet
+var mfaPolicyFile, mfaKeyFile string
+var sessionCookies *middleware.CookieConfig
 
-func init() {
+// loadConfig reads the service configuration from the environment and .env. The
+// subcommands read only what they need.
+func loadConfig() {
 	_ = godotenv.Load()
 	dsn = os.Getenv("DATABASE_DSN")
 	if dsn == "" {
@@ -30,9 +61,263 @@ func init() {
 	if appKey == "" {
 		log.Fatal("NewRelic_Key is not set")
 	}
+	eventPublisher = os.Getenv("EVENT_PUBLISHER")
+	if eventPublisher == "" {
+		eventPublisher = "stdout"
+	}
+	grpcPort = os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = ":9090"
+	}
+	// Empty means the built-in write policy
+	writePolicyFile = os.Getenv("WRITE_POLICY_FILE")
+	readPolicyFile = os.Getenv("READ_POLICY_FILE")
+	rolesFile = os.Getenv("ROLES_FILE")
+	passwordPolicyFile = os.Getenv("PASSWORD_POLICY_FILE")
+	// Empty means the app_users table
+	usersFile = os.Getenv("USERS_FILE")
+	// Empty means a key generated at startup, for local development only
+	signingKeyFile = os.Getenv("JWT_SIGNING_KEY_FILE")
+	if files := os.Getenv("JWT_VERIFICATION_KEY_FILES"); files != "" {
+		verificationKeyFiles = strings.Split(files, ",")
+	}
+	jwtIssuer = os.Getenv("JWT_ISSUER")
+	if jwtIssuer == "" {
+		jwtIssuer = "kubecloudsinc-employee-api"
+	}
+	jwtAudience = os.Getenv("JWT_AUDIENCE")
+	if jwtAudience == "" {
+		jwtAudience = "kubecloudsinc-employee-api"
+	}
+	// Empty means no OpenID Connect sign-in
+	oidcConfigFile = os.Getenv("OIDC_CONFIG_FILE")
+	lockoutPolicyFile = os.Getenv("LOCKOUT_POLICY_FILE")
+	loginCounters = os.Getenv("LOGIN_COUNTERS")
+	if loginCounters == "" {
+		loginCounters = "memory"
+	}
+	mfaPolicyFile = os.Getenv("MFA_POLICY_FILE")
+	// Empty means a key generated at startup, for local development only
+	mfaKeyFile = os.Getenv("MFA_ENCRYPTION_KEY_FILE")
+	// Cookie sessions for the browser app, next to bearer tokens
+	if os.Getenv("SESSION_COOKIES") == "true" {
+		config, err := newCookieConfig()
+		if err != nil {
+			log.Fatalf("Invalid session cookie settings: %v", err)
+		}
+		sessionCookies = config
+	}
+	// Load balancers whose X-Forwarded-For is believed
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		networks, err := apikeys.NormalizeIPs(strings.Split(proxies, ","))
+		if err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+		for _, cidr := range networks {
+			_, network, _ := net.ParseCIDR(cidr)
+			trustedProxies = append(trustedProxies, network)
+		}
+	}
+}
+
+// newUserStore returns the users of USERS_FILE, kept in memory, or the app_users table.
+func newUserStore(path string) (users.Store, error) {
+	if path != "" {
+		return users.LoadMemoryStore(path)
+	}
+	return &users.SQLStore{DB: dbs.DB}, nil
+}
+
+// newSessionStore keeps sessions next to the users: in memory with USERS_FILE, whose
+// user IDs the database does not know, and in the session tables otherwise.
+func newSessionStore(usersPath string) sessions.Store {
+	if usersPath != "" {
+		return sessions.NewMemoryStore()
+	}
+	return &sessions.SQLStore{DB: dbs.DB}
+}
+
+// newAPIKeyStore keeps API keys in memory with USERS_FILE, like the sessions, and in
+// the api_keys table otherwise.
+func newAPIKeyStore(usersPath string) apikeys.Store {
+	if usersPath != "" {
+		return apikeys.NewMemoryStore()
+	}
+	return &apikeys.SQLStore{DB: dbs.DB}
+}
+
+// newSecurityEventStore keeps security events in memory with USERS_FILE, like the
+// sessions, and in the security_events table otherwise.
+func newSecurityEventStore(usersPath string) loginguard.EventStore {
+	if usersPath != "" {
+		return loginguard.NewMemoryEventStore()
+	}
+	return &loginguard.SQLEventStore{DB: dbs.DB}
+}
+
+// newCookieConfig reads the session cookie settings. Cookies are Secure and SameSite=Strict
+// unless SESSION_COOKIE_SECURE or SESSION_COOKIE_SAMESITE say otherwise.
+func newCookieConfig() (*middleware.CookieConfig, error) {
+	config := &middleware.CookieConfig{
+		Domain:   os.Getenv("SESSION_COOKIE_DOMAIN"),
+		Secure:   os.Getenv("SESSION_COOKIE_SECURE") != "false",
+		SameSite: http.SameSiteStrictMode,
+	}
+	switch sameSite := os.Getenv("SESSION_COOKIE_SAMESITE"); sameSite {
+	case "", "strict":
+	case "lax":
+		config.SameSite = http.SameSiteLaxMode
+	default:
+		return nil, fmt.Errorf("unknown SESSION_COOKIE_SAMESITE %q", sameSite)
+	}
+	return config, nil
+}
+
+// newMFAStore keeps MFA secrets in memory with USERS_FILE, like the sessions, and in
+// the user_mfa table otherwise, encrypted with cipher.
+func newMFAStore(usersPath string, cipher *mfa.Cipher) mfa.Store {
+	if usersPath != "" {
+		return mfa.NewMemoryStore()
+	}
+	return &mfa.SQLStore{DB: dbs.DB, Cipher: cipher}
+}
+
+// newMFACipher loads the key that encrypts the stored TOTP secrets, or generates one
+// that only this process knows when no key file is configured.
+func newMFACipher(path string) (*mfa.Cipher, error) {
+	if path == "" {
+		log.Println("MFA_ENCRYPTION_KEY_FILE is not set; encrypting MFA secrets with a generated key that is lost on restart")
+		return mfa.GenerateCipher()
+	}
+	return mfa.LoadCipher(path)
+}
+
+// newLoginCounters builds the failed login counters selected by LOGIN_COUNTERS.
+func newLoginCounters(kind string) (loginguard.Counters, error) {
+	switch kind {
+	case "memory":
+		return loginguard.NewMemoryCounters(), nil
+	case "redis":
+		url := os.Getenv("REDIS_URL")
+		if url == "" {
+			return nil, fmt.Errorf("REDIS_URL is not set")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return loginguard.NewRedisCounters(ctx, url)
+	default:
+		return nil, fmt.Errorf("unknown LOGIN_COUNTERS %q", kind)
+	}
+}
+
+// newSigningKeys loads the token signing and verification keys, or generates a key
+// that only this process knows when no signing key file is configured.
+func newSigningKeys(signingPath string, verificationPaths []string) (*signing.KeySet, error) {
+	if signingPath == "" {
+		log.Println("JWT_SIGNING_KEY_FILE is not set; signing tokens with a generated key that is lost on restart")
+		return signing.Generate()
+	}
+	return signing.Load(signingPath, verificationPaths)
+}
+
+// newOIDCProvider returns the OpenID Connect provider of the config file at path, or
+// nil when path is empty.
+func newOIDCProvider(path string) (*oidc.Provider, error) {
+	if path == "" {
+		return nil, nil
+	}
+	config, err := oidc.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	return oidc.NewProvider(config), nil
+}
+
+// newPublisher builds the outbox publisher selected by EVENT_PUBLISHER.
+func newPublisher(kind string) (events.Publisher, error) {
+	switch kind {
+	case "stdout":
+		return events.NewFilePublisher("-")
+	case "file":
+		return events.NewFilePublisher(os.Getenv("EVENT_FILE"))
+	case "nats":
+		url := os.Getenv("NATS_URL")
+		if url == "" {
+			return nil, fmt.Errorf("NATS_URL is not set")
+		}
+		prefix := os.Getenv("NATS_SUBJECT_PREFIX")
+		if prefix == "" {
+			prefix = "kubecloudsinc"
+		}
+		return events.NewNATSPublisher(url, prefix)
+	case "kafka":
+		brokers := os.Getenv("KAFKA_BROKERS")
+		if brokers == "" {
+			return nil, fmt.Errorf("KAFKA_BROKERS is not set")
+		}
+		topic := os.Getenv("KAFKA_TOPIC")
+		if topic == "" {
+			topic = "employee-changes"
+		}
+		return events.NewKafkaPublisher(strings.Split(brokers, ","), topic), nil
+	default:
+		return nil, fmt.Errorf("unknown EVENT_PUBLISHER %q", kind)
+	}
 }
 func main() {
-	err := dbs.InitDB(dsn)
+	if len(os.Args) > 1 {
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+		if err := command(os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+	loadConfig()
+
+	// Permissions granted to each role
+	if err := policy.LoadGrants(rolesFile); err != nil {
+		log.Fatal("Failed to load role grants:", err)
+	}
+	// Field-level write permissions per role
+	if err := policy.Load(writePolicyFile); err != nil {
+		log.Fatal("Failed to load write policy:", err)
+	}
+	log.Printf("Write policy loaded for roles: %s", strings.Join(policy.Current.RoleNames(), ", "))
+	// Fields masked from each role in responses
+	if err := policy.LoadRead(readPolicyFile); err != nil {
+		log.Fatal("Failed to load read policy:", err)
+	}
+	// Rules for new passwords
+	if err := policy.LoadPassword(passwordPolicyFile); err != nil {
+		log.Fatal("Failed to load password policy:", err)
+	}
+	// Limits on failed logins
+	if err := policy.LoadLockout(lockoutPolicyFile); err != nil {
+		log.Fatal("Failed to load lockout policy:", err)
+	}
+	// Roles that must use a second factor
+	if err := policy.LoadMFA(mfaPolicyFile); err != nil {
+		log.Fatal("Failed to load MFA policy:", err)
+	}
+	// Sign-in through an external identity provider, with its groups mapped to roles
+	oidcProvider, err := newOIDCProvider(oidcConfigFile)
+	if err != nil {
+		log.Fatal("Failed to load the OIDC config:", err)
+	}
+	if oidcProvider != nil {
+		// The callback is a browser navigation, where tokens in the body would be shown
+		// to the user and kept in the history instead of reaching the app
+		if sessionCookies == nil {
+			log.Fatal("OIDC sign-in needs cookie sessions: set SESSION_COOKIES=true")
+		}
+		log.Printf("OIDC sign-in enabled with %s", oidcProvider.Config.Issuer)
+	}
+
+	err = dbs.InitDB(dsn)
 	if err != nil {
 		log.Fatal("Failed to connect to the database:", err)
 	}
@@ -43,8 +328,76 @@ func main() {
 	}
 	log.Println("Successfully Initialized New Relic", app)
 
+	userStore, err := newUserStore(usersFile)
+	if err != nil {
+		log.Fatal("Failed to initialize the user store:", err)
+	}
+	// Keys that sign and verify access tokens
+	signingKeys, err := newSigningKeys(signingKeyFile, verificationKeyFiles)
+	if err != nil {
+		log.Fatal("Failed to load the token signing keys:", err)
+	}
+	signingKeys.Issuer, signingKeys.Audience = jwtIssuer, jwtAudience
+	middleware.SigningKeys = signingKeys
+	log.Printf("Signing tokens with key %s", signingKeys.SigningKeyId())
+
+	// Sessions, refresh tokens and the access token denylist
+	sessionStore := newSessionStore(usersFile)
+	middleware.Denylist = sessionStore
+	pruner := &sessions.Pruner{Store: sessionStore, Interval: time.Hour}
+	go pruner.Run(context.Background())
+
+	// API keys of service clients
+	apiKeyStore := newAPIKeyStore(usersFile)
+	middleware.APIKeys = apikeys.NewAuthenticator(apiKeyStore)
+	middleware.TrustedProxies = trustedProxies
+	middleware.Cookies = sessionCookies
+
+	// Failed login counters, shared by every instance through Redis
+	counters, err := newLoginCounters(loginCounters)
+	if err != nil {
+		log.Fatal("Failed to initialize the login counters:", err)
+	}
+	loginGuard := loginguard.NewGuard(counters, newSecurityEventStore(usersFile))
+
+	// Second factors, with the TOTP secrets encrypted at rest
+	mfaCipher, err := newMFACipher(mfaKeyFile)
+	if err != nil {
+		log.Fatal("Failed to load the MFA encryption key:", err)
+	}
+	mfaStore := newMFAStore(usersFile, mfaCipher)
+
+	// Relay committed change events from the outbox to downstream consumers
+	publisher, err := newPublisher(eventPublisher)
+	if err != nil {
+		log.Fatal("Failed to initialize event publisher:", err)
+	}
+	defer publisher.Close()
+
+	// Webhook subscribers receive the same events through the dispatcher
+	dispatcher := webhooks.NewDispatcher(dbs.DB)
+	go dispatcher.Run(context.Background())
+
+	relay := &events.Relay{DB: dbs.DB, Publisher: events.MultiPublisher{dispatcher, publisher}, Interval: 2 * time.Second, BatchSize: 100}
+	go relay.Run(context.Background())
+
+	// Apply scheduled employee changes once their effective date is reached
+	scheduler := &service.PendingChangeScheduler{DB: dbs.DB, Interval: time.Minute, BatchSize: 50}
+	go scheduler.Run(context.Background())
+
+	// Tail the outbox for the server-sent change feed
+	broker := events.NewBroker(dbs.DB)
+	go broker.Run(context.Background())
+
+	// Serve the gRPC employee API on its own port
+	go func() {
+		if err := grpcserver.StartServer(grpcPort, app); err != nil {
+			log.Fatal("Failed to start gRPC server:", err)
+		}
+	}()
+
 	// Start the server on port 8080
-	err = server.StartServer(":8080", app)
+	err = server.StartServer(":8080", app, broker, userStore, sessionStore, oidcProvider, apiKeyStore, loginGuard, mfaStore)
 	if err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
@@ -0,0 +1,83 @@
+package mfa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Cipher encrypts TOTP secrets at rest with AES-256-GCM. Unlike passwords they cannot
+// be hashed, since the codes are computed from them.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// LoadCipher reads a base64-encoded 32-byte key, as written by
+// "openssl rand -base64 32", from the file at path.
+func LoadCipher(path string) (*Cipher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MFA encryption key: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("MFA encryption key %s is not base64: %v", path, err)
+	}
+	return NewCipher(key)
+}
+
+// GenerateCipher returns a Cipher with a random key, which is lost on restart along
+// with every secret encrypted under it.
+func GenerateCipher() (*Cipher, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return NewCipher(key)
+}
+
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, errors.New("MFA encryption key must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts the secret of a user. The user ID is authenticated with it, so a
+// secret copied to another user's row does not decrypt.
+func (c *Cipher) Seal(userId int, secret string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), []byte(strconv.Itoa(userId)))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret sealed for the user.
+func (c *Cipher) Open(userId int, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", errors.New("MFA secret is corrupt")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, []byte(strconv.Itoa(userId)))
+	if err != nil {
+		return "", errors.New("MFA secret does not decrypt; was MFA_ENCRYPTION_KEY_FILE changed?")
+	}
+	return string(secret), nil
+}
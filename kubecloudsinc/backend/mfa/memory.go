@@ -0,0 +1,112 @@
+package mfa
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryFactor is a second factor with its unused recovery codes.
+type memoryFactor struct {
+	mfa   schema.UserMFA
+	codes map[string]bool
+}
+
+// MemoryStore keeps second factors in memory, next to a users.MemoryStore for local
+// development. Its contents are lost on restart, so users enroll again.
+type MemoryStore struct {
+	mu      sync.Mutex
+	factors map[int]*memoryFactor
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{factors: make(map[int]*memoryFactor)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, userId int) (*schema.UserMFA, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	factor, ok := s.factors[userId]
+	if !ok {
+		return nil, dbs.ErrMFANotFound
+	}
+	mfa := factor.mfa
+	mfa.RecoveryCodesLeft = len(factor.codes)
+	return &mfa, nil
+}
+
+func (s *MemoryStore) SetSecret(ctx context.Context, userId int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if factor, ok := s.factors[userId]; ok && factor.mfa.Enabled {
+		return dbs.ErrMFAEnabled
+	}
+	s.factors[userId] = &memoryFactor{mfa: schema.UserMFA{UserId: userId, Secret: secret}, codes: map[string]bool{}}
+	return nil
+}
+
+func (s *MemoryStore) Enable(ctx context.Context, userId int, step int64, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	factor, ok := s.factors[userId]
+	if !ok || factor.mfa.Enabled {
+		return dbs.ErrMFANotFound
+	}
+	now := time.Now().UTC()
+	factor.mfa.Enabled, factor.mfa.EnabledAt, factor.mfa.LastStep = true, &now, step
+	factor.codes = codeSet(codeHashes)
+	return nil
+}
+
+func (s *MemoryStore) UseStep(ctx context.Context, userId int, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	factor, ok := s.factors[userId]
+	if !ok || !factor.mfa.Enabled || factor.mfa.LastStep >= step {
+		return dbs.ErrMFACodeUsed
+	}
+	factor.mfa.LastStep = step
+	return nil
+}
+
+func (s *MemoryStore) UseRecoveryCode(ctx context.Context, userId int, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	factor, ok := s.factors[userId]
+	if !ok || !factor.codes[codeHash] {
+		return dbs.ErrRecoveryCodeInvalid
+	}
+	delete(factor.codes, codeHash)
+	return nil
+}
+
+func (s *MemoryStore) ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	factor, ok := s.factors[userId]
+	if !ok {
+		return dbs.ErrMFANotFound
+	}
+	factor.codes = codeSet(codeHashes)
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.factors[userId]; !ok {
+		return dbs.ErrMFANotFound
+	}
+	delete(s.factors, userId)
+	return nil
+}
+
+func codeSet(codeHashes []string) map[string]bool {
+	codes := make(map[string]bool, len(codeHashes))
+	for _, codeHash := range codeHashes {
+		codes[codeHash] = true
+	}
+	return codes
+}
@@ -0,0 +1,78 @@
+package mfa
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func enrolledStore(t *testing.T, step int64, codeHashes []string) *MemoryStore {
+	t.Helper()
+	s := NewMemoryStore()
+	if err := s.SetSecret(context.Background(), 1, rfcSecret); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Enable(context.Background(), 1, step, codeHashes); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCodeCannotBeReusedWithinItsStep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1234567890, 0)
+	s := enrolledStore(t, Step(now)-5, nil)
+
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, ok := Verify(rfcSecret, code, now, 1)
+	if !ok {
+		t.Fatal("the current code was refused")
+	}
+	if err := s.UseStep(ctx, 1, step); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := s.UseStep(ctx, 1, step); !errors.Is(err, dbs.ErrMFACodeUsed) {
+		t.Fatalf("second use in the same step returned %v, want ErrMFACodeUsed", err)
+	}
+	// A code of an earlier step that is still inside the window is spent too
+	if err := s.UseStep(ctx, 1, step-1); !errors.Is(err, dbs.ErrMFACodeUsed) {
+		t.Fatalf("use of the previous step returned %v, want ErrMFACodeUsed", err)
+	}
+	if err := s.UseStep(ctx, 1, step+1); err != nil {
+		t.Fatalf("use of the next step: %v", err)
+	}
+}
+
+func TestRecoveryCodeWorksOnce(t *testing.T) {
+	ctx := context.Background()
+	codes, hashes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := enrolledStore(t, 0, hashes)
+
+	// Codes are typed without regard to case or dashes
+	typed := strings.ToUpper(strings.ReplaceAll(codes[3], "-", ""))
+	if err := s.UseRecoveryCode(ctx, 1, HashRecoveryCode(typed)); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := s.UseRecoveryCode(ctx, 1, HashRecoveryCode(codes[3])); !errors.Is(err, dbs.ErrRecoveryCodeInvalid) {
+		t.Fatalf("second use returned %v, want ErrRecoveryCodeInvalid", err)
+	}
+	if err := s.UseRecoveryCode(ctx, 1, HashRecoveryCode("aaaaa-aaaaa")); !errors.Is(err, dbs.ErrRecoveryCodeInvalid) {
+		t.Fatalf("an unknown code returned %v, want ErrRecoveryCodeInvalid", err)
+	}
+	factor, err := s.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if factor.RecoveryCodesLeft != 9 {
+		t.Fatalf("%d recovery codes left, want 9", factor.RecoveryCodesLeft)
+	}
+}
@@ -0,0 +1,41 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+// recoveryAlphabet leaves out characters that are easily mistaken for others.
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// NewRecoveryCodes returns n recovery codes such as "k7pq2-xm9ad" and their hashes.
+// Each holds 10 random characters, about 49 bits.
+func NewRecoveryCodes(n int) (codes, hashes []string, err error) {
+	for i := 0; i < n; i++ {
+		code := make([]byte, 0, 11)
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				code = append(code, '-')
+			}
+			index, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryAlphabet))))
+			if err != nil {
+				return nil, nil, err
+			}
+			code = append(code, recoveryAlphabet[index.Int64()])
+		}
+		codes = append(codes, string(code))
+		hashes = append(hashes, HashRecoveryCode(string(code)))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the SHA-256 hash under which a recovery code is stored. Case,
+// dashes and spaces do not matter.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package mfa
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// Store keeps the second factors of users. Users without one fail with
+// dbs.ErrMFANotFound, reused codes with dbs.ErrMFACodeUsed and bad recovery codes with
+// dbs.ErrRecoveryCodeInvalid, whatever the implementation.
+type Store interface {
+	// Get returns the second factor of a user with its secret in the clear.
+	Get(ctx context.Context, userId int) (*schema.UserMFA, error)
+	// SetSecret starts an enrollment, replacing one that was not confirmed. It fails
+	// with dbs.ErrMFAEnabled when MFA is enabled.
+	SetSecret(ctx context.Context, userId int, secret string) error
+	// Enable confirms the enrollment with the code of step and stores the hashes of
+	// the recovery codes.
+	Enable(ctx context.Context, userId int, step int64, codeHashes []string) error
+	// UseStep spends the codes of step and of every earlier step.
+	UseStep(ctx context.Context, userId int, step int64) error
+	// UseRecoveryCode spends the recovery code with hash codeHash.
+	UseRecoveryCode(ctx context.Context, userId int, codeHash string) error
+	// ReplaceRecoveryCodes swaps all recovery codes for new ones.
+	ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error
+	// Delete removes the second factor and the recovery codes.
+	Delete(ctx context.Context, userId int) error
+}
+
+// SQLStore keeps second factors in the tables created by migrations/012_user_mfa.sql,
+// with the secrets encrypted by Cipher.
+type SQLStore struct {
+	DB     *sql.DB
+	Cipher *Cipher
+}
+
+func (s *SQLStore) Get(ctx context.Context, userId int) (*schema.UserMFA, error) {
+	mfa, err := dbs.GetUserMFA(newrelic.FromContext(ctx), s.DB, userId)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Secret, err = s.Cipher.Open(userId, mfa.Secret); err != nil {
+		return nil, err
+	}
+	return mfa, nil
+}
+
+func (s *SQLStore) SetSecret(ctx context.Context, userId int, secret string) error {
+	sealed, err := s.Cipher.Seal(userId, secret)
+	if err != nil {
+		return err
+	}
+	return dbs.SetMFASecret(newrelic.FromContext(ctx), s.DB, userId, sealed)
+}
+
+func (s *SQLStore) Enable(ctx context.Context, userId int, step int64, codeHashes []string) error {
+	return dbs.EnableMFA(newrelic.FromContext(ctx), s.DB, userId, step, codeHashes)
+}
+
+func (s *SQLStore) UseStep(ctx context.Context, userId int, step int64) error {
+	return dbs.UseMFAStep(newrelic.FromContext(ctx), s.DB, userId, step)
+}
+
+func (s *SQLStore) UseRecoveryCode(ctx context.Context, userId int, codeHash string) error {
+	return dbs.UseRecoveryCode(newrelic.FromContext(ctx), s.DB, userId, codeHash)
+}
+
+func (s *SQLStore) ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error {
+	return dbs.ReplaceRecoveryCodes(newrelic.FromContext(ctx), s.DB, userId, codeHashes)
+}
+
+func (s *SQLStore) Delete(ctx context.Context, userId int) error {
+	return dbs.DeleteUserMFA(newrelic.FromContext(ctx), s.DB, userId)
+}
@@ -0,0 +1,76 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890",
+// in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; these are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyWindow(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+	tests := []struct {
+		name   string
+		offset int64
+		want   bool
+	}{
+		{"current step", 0, true},
+		{"one step behind", -1, true},
+		{"one step ahead", 1, true},
+		{"two steps behind", -2, false},
+		{"two steps ahead", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Code(rfcSecret, current+tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			step, ok := Verify(rfcSecret, code, now, 1)
+			if ok != tt.want {
+				t.Fatalf("Verify accepted %v, want %v", ok, tt.want)
+			}
+			if ok && step != current+tt.offset {
+				t.Fatalf("Verify returned step %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	for _, code := range []string{"", "00592", "0059245", "abcdef"} {
+		if _, ok := Verify(rfcSecret, code, now, 1); ok {
+			t.Errorf("Verify accepted %q", code)
+		}
+	}
+	if _, ok := Verify(rfcSecret, "005 924", now, 0); !ok {
+		t.Error("Verify refused a code with a space")
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/apikeys"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIKeyHeader carries an API key. Authorization: ApiKey <key> works as well.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys checks the API keys of service clients. main sets it at startup; until then
+// API keys are refused.
+var APIKeys *apikeys.Authenticator
+
+// apiKeyFromRequest returns the API key of the request, if it carries one.
+func apiKeyFromRequest(r *http.Request) (string, bool) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key, true
+	}
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+		return key, true
+	}
+	return "", false
+}
+
+// authenticateAPIKey checks key and returns claims that act as the key's role, limited
+// to its scopes. The username is "apikey:" and the key's name, as the audit log shows
+// it. On failure it writes the response: 401 for a bad key, 403 for a refused
+// address and 429 with Retry-After beyond the rate limit.
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, key string) (*Claims, bool) {
+	if APIKeys == nil {
+		http.Error(w, "API keys are not configured", http.StatusUnauthorized)
+		return nil, false
+	}
+	record, err := APIKeys.Authenticate(r.Context(), key, ClientIP(r))
+	var rateErr *apikeys.RateLimitError
+	switch {
+	case errors.Is(err, apikeys.ErrInvalidKey):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	case errors.Is(err, apikeys.ErrAddressNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	case errors.As(err, &rateErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return nil, false
+	case err != nil:
+		log.Printf("Error checking an API key: %v", err)
+		http.Error(w, "API key could not be checked", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &Claims{Username: "apikey:" + record.Name, Role: record.Role, APIKeyId: record.KeyId, Scopes: record.Scopes}, true
+}
+
+// TrustedProxies are the load balancers and ingress controllers whose
+// X-Forwarded-For header ClientIP believes. main sets them from TRUSTED_PROXIES.
+var TrustedProxies []*net.IPNet
+
+// ClientIP returns the address the request came from. When it came through trusted
+// proxies, that is the last address in X-Forwarded-For that is not one of them;
+// addresses before it were written by the client and could be anything.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host) {
+		return host
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		addr := ip.String()
+		if !trustedProxy(addr) {
+			return addr
+		}
+		host = addr
+	}
+	return host
+}
+
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// Cookie sessions keep the tokens of the browser app where its scripts cannot read
+// them. The access token and the refresh token are HttpOnly cookies, and requests that
+// change something must repeat the readable CSRF cookie in the CSRF header (double
+// submit). The access token also carries a hash of that CSRF token, so a CSRF cookie
+// planted by another site does not pass.
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFCookie         = "csrf_token"
+	CSRFHeader         = "X-CSRF-Token"
+	// SessionModeHeader set to "cookie" asks a login for a cookie session instead of
+	// tokens in the body.
+	SessionModeHeader = "X-Session-Mode"
+)
+
+// refreshCookiePath limits the refresh token cookie to the endpoint that takes it.
+const refreshCookiePath = "/v2/token/refresh"
+
+// CookieConfig configures cookie sessions. Domain is empty for cookies of the API's
+// own host only.
+type CookieConfig struct {
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Cookies enables cookie sessions. main sets it when SESSION_COOKIES is true; while it
+// is nil, tokens are only sent in the body and cookies are ignored.
+var Cookies *CookieConfig
+
+// wantsCookies reports whether a login asked for a cookie session.
+func wantsCookies(r *http.Request) bool {
+	return Cookies != nil && r.Header.Get(SessionModeHeader) == "cookie"
+}
+
+// newCSRFToken returns a random CSRF token and the hash the access token carries.
+func newCSRFToken() (string, string, error) {
+	token, err := sessions.NewId()
+	if err != nil {
+		return "", "", err
+	}
+	return token, sessions.HashToken(token), nil
+}
+
+// setSessionCookies sends the tokens of a cookie session. The cookies last as long as
+// the tokens in them; the CSRF token lasts as long as the refresh token, which needs it.
+func setSessionCookies(w http.ResponseWriter, accessToken, refreshToken, csrfToken string) {
+	setCookie(w, AccessTokenCookie, accessToken, "/", int(sessions.AccessTokenLifetime.Seconds()), true)
+	setCookie(w, RefreshTokenCookie, refreshToken, refreshCookiePath, int(sessions.RefreshTokenLifetime.Seconds()), true)
+	setCookie(w, CSRFCookie, csrfToken, "/", int(sessions.RefreshTokenLifetime.Seconds()), false)
+}
+
+// clearSessionCookies deletes the cookies of a session that ended.
+func clearSessionCookies(w http.ResponseWriter) {
+	if Cookies == nil {
+		return
+	}
+	setCookie(w, AccessTokenCookie, "", "/", -1, true)
+	setCookie(w, RefreshTokenCookie, "", refreshCookiePath, -1, true)
+	setCookie(w, CSRFCookie, "", "/", -1, false)
+}
+
+func setCookie(w http.ResponseWriter, name, value, path string, maxAge int, httpOnly bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   Cookies.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: httpOnly,
+		Secure:   Cookies.Secure,
+		SameSite: Cookies.SameSite,
+	})
+}
+
+// checkCSRF lets safe methods through and requires the CSRF header of every other
+// request to match the CSRF cookie and, unless csrfHash is empty, the hash the access
+// token carries.
+func checkCSRF(r *http.Request, csrfHash string) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	header := r.Header.Get(CSRFHeader)
+	cookie, err := r.Cookie(CSRFCookie)
+	if header == "" || err != nil || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return errors.New("CSRF token is missing or does not match")
+	}
+	if csrfHash != "" && subtle.ConstantTimeCompare([]byte(sessions.HashToken(header)), []byte(csrfHash)) != 1 {
+		return errors.New("CSRF token is missing or does not match")
+	}
+	return nil
+}
+
+// claimsFromCookie authenticates the access token cookie of a request and checks its
+// CSRF token, and writes the response when either fails.
+func claimsFromCookie(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) (*Claims, bool) {
+	claims, err := ParseToken(r.Context(), cookie.Value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	// Only tokens issued into a cookie carry a CSRF hash
+	if claims.CSRFHash == "" {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+	if err := checkCSRF(r, claims.CSRFHash); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return claims, true
+}
@@ -1,12 +1,21 @@
 package middleware
 
-// User represents a user with a username, password, and role.
 import (
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/mfa"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/signing"
+	"autotools-golang-api/kubecloudsinc/backend/users"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,22 +27,24 @@ type contextKey string
 // RoleContextKey is the key for role values in the context
 const RoleContextKey contextKey = "userRole"
 
-type User struct {
-	Username string
-	Password string
-	Role     string
-}
+// UsernameContextKey is the key for the authenticated username in the context
+const UsernameContextKey contextKey = "username"
 
-// Users is a mock database of users.
-var users = []User{
-	{Username: "mazda", Password: "Test1ng!", Role: "admin"},
-	{Username: "honda", Password: "Test1ng!", Role: "editor"},
-	{Username: "kia", Password: "Test1ng!", Role: "editor"},
-	{Username: "benz", Password: "Test1ng!", Role: "viewer"},
-	{Username: "toyota", Password: "Test1ng!", Role: "viewer"},
-}
+// EndpointContextKey is the key for the called endpoint, recorded in the audit log
+const EndpointContextKey contextKey = "endpoint"
+
+// EmployeeIdContextKey is the key for the authenticated user's own employee ID
+const EmployeeIdContextKey contextKey = "employeeId"
+
+// SessionIdContextKey is the key for the session the access token was issued in
+const SessionIdContextKey contextKey = "sessionId"
+
+// SigningKeys signs the access tokens and verifies them. main sets it at startup.
+var SigningKeys *signing.KeySet
 
-var jwtKey = []byte("JAIJAFFA")
+// Denylist holds the access tokens revoked before their expiry. main sets it to the
+// session store; until both are set, ParseToken refuses every token.
+var Denylist sessions.Denylist
 
 // Credentials are used for parsing login requests.
 type Credentials struct {
@@ -41,120 +52,281 @@ type Credentials struct {
 	Password string `json:"password"`
 }
 
-// Claims are used for creating JWT tokens.
+// Claims are used for creating JWT tokens. The jti (StandardClaims.Id) identifies the
+// token on the denylist and SessionId the login it belongs to. Tokens sent as a cookie
+// carry the hash of their session's CSRF token. Requests with an API key get claims
+// without a token, with the key's ID and the permissions it is scoped to.
 type Claims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Username   string   `json:"username"`
+	Role       string   `json:"role"`
+	EmployeeId int      `json:"employeeId,omitempty"`
+	SessionId  string   `json:"sid"`
+	CSRFHash   string   `json:"csrf,omitempty"`
+	APIKeyId   int      `json:"-"`
+	Scopes     []string `json:"-"`
 	jwt.StandardClaims
 }
 
-func Login(w http.ResponseWriter, r *http.Request) {
-	var creds Credentials
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+// Login checks the credentials against store, starts a session and returns an access
+// token and a refresh token for the user, or sets them as cookies when the request asks
+// for a cookie session. Users with MFA, or whose role requires it,
+// get an MFA challenge instead, answered at LoginMFA. guard slows down failed logins
+// and refuses a locked username or address with a 429 and Retry-After.
+func Login(store users.Store, sessionStore sessions.Store, mfaStore mfa.Store, guard *loginguard.Guard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var creds Credentials
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 
-	// Authenticate the user
-	var userRole string
-	for _, user := range users {
-		if user.Username == creds.Username && user.Password == creds.Password {
-			userRole = user.Role
-			break
+		ip := ClientIP(r)
+		// The attempt is counted before the password is checked, so parallel guesses
+		// cannot get past the limit
+		retryAfter, attempts := guard.Attempt(r.Context(), creds.Username, ip)
+		if retryAfter > 0 {
+			tooManyLogins(w, retryAfter)
+			return
 		}
-	}
 
-	if userRole == "" {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
+		// Authenticate the user
+		user, err := users.Authenticate(r.Context(), store, creds.Username, creds.Password)
+		if errors.Is(err, users.ErrInvalidCredentials) {
+			// Failed attempts are recorded as security events rather than logged
+			loginguard.Wait(r.Context(), guard.Failed(r.Context(), creds.Username, ip, "invalid credentials", attempts))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, users.ErrUserDisabled) {
+			guard.Refused(r.Context(), creds.Username, ip, "user disabled")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("Error authenticating a login: %v", err)
+			guard.Cancel(r.Context(), creds.Username, ip)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 
-	// Log successful authentication
-	log.Printf("User authenticated: %s at %s", creds.Username, time.Now().Format(time.RFC3339))
+		// The attempts of the username are only forgiven once the second factor passed
+		if challengeMFA(w, r, user, mfaStore) {
+			guard.Challenged(r.Context(), ip)
+			return
+		}
+		guard.Succeeded(r.Context(), creds.Username, ip)
+
+		// Log successful authentication
+		log.Printf("User authenticated: %s at %s", user.Username, time.Now().Format(time.RFC3339))
 
-	expirationTime := time.Now().Add(15 * time.Minute)
-	claims := &Claims{
-		Username: creds.Username,
-		Role:     userRole,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+		startSession(w, r, user, sessionStore, nil, wantsCookies(r))
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+// tooManyLogins refuses a locked out login with a 429 and Retry-After.
+func tooManyLogins(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too many failed logins, try again later", http.StatusTooManyRequests)
+}
+
+// startSession starts a session for an authenticated user and sends its first access
+// token and refresh token, in the body or as cookies, with the recovery codes of an
+// enrollment it completes.
+func startSession(w http.ResponseWriter, r *http.Request, user *schema.User, sessionStore sessions.Store, recoveryCodes []string, cookie bool) {
+	sessionId, err := sessions.NewId()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	refreshToken, record, err := sessions.NewRefreshToken()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	session := schema.Session{SessionId: sessionId, UserId: user.UserId, CreatedAt: now, ExpiresAt: now.Add(sessions.SessionLifetime)}
+	if err := sessionStore.Create(r.Context(), session, record); err != nil {
+		log.Printf("Error starting a session for %s: %v", user.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-	log.Printf("Token generated for user: %s at %s", creds.Username, time.Now().Format(time.RFC3339))
-
-	// http.SetCookie(w, &http.Cookie{
-	// 	Name:    "token",
-	// 	Value:   tokenString,
-	// 	Expires: expirationTime,
-	// })
-	// Instead of setting the token as a cookie, return it in the response body
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+	writeTokens(w, user, sessionId, refreshToken, record, recoveryCodes, cookie)
 }
 
-func IsAuthorized(requiredRoles ...string) func(http.HandlerFunc) http.HandlerFunc {
+// RequirePermission authenticates the bearer token or API key and lets the request
+// through when its role holds any of the given permissions in policy.CurrentGrants.
+// An API key must also be scoped to that permission.
+func RequirePermission(anyOf ...string) func(http.HandlerFunc) http.HandlerFunc {
+	for _, permission := range anyOf {
+		if !policy.IsPermission(permission) {
+			panic(fmt.Sprintf("middleware: unknown permission %q", permission))
+		}
+	}
 	return func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-				return
+		return authenticate(func(w http.ResponseWriter, r *http.Request, claims *Claims) bool {
+			// Check if the user's role grants the permission the route needs
+			if !policy.CurrentGrants.Has(claims.Role, anyOf...) {
+				msg := fmt.Sprintf("Insufficient permissions: user role %s does not have %s", claims.Role, strings.Join(anyOf, " or "))
+				log.Print(msg)
+				http.Error(w, msg, http.StatusForbidden)
+				return false
+			}
+			if claims.APIKeyId != 0 && !scopedTo(claims.Scopes, anyOf) {
+				msg := fmt.Sprintf("Insufficient permissions: API key %s is not scoped to %s", claims.Username, strings.Join(anyOf, " or "))
+				log.Print(msg)
+				http.Error(w, msg, http.StatusForbidden)
+				return false
 			}
+			return true
+		}, next)
+	}
+}
 
-			bearerToken := strings.Split(authHeader, "Bearer ")
-			if len(bearerToken) != 2 {
-				http.Error(w, "Invalid Authorization token format", http.StatusUnauthorized)
-				return
+// scopedTo reports whether scopes holds any of the permissions.
+func scopedTo(scopes, anyOf []string) bool {
+	for _, scope := range scopes {
+		for _, permission := range anyOf {
+			if scope == permission {
+				return true
 			}
+		}
+	}
+	return false
+}
 
-			tokenString := bearerToken[1]
-			claims := &Claims{}
+// RequireLogin authenticates the bearer token and lets the request through for any
+// role listed in policy.CurrentGrants, for routes every user may call on themselves.
+// API keys are not users and are refused.
+func RequireLogin(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate(func(w http.ResponseWriter, r *http.Request, claims *Claims) bool {
+		if claims.APIKeyId != 0 {
+			http.Error(w, "API keys cannot call this endpoint", http.StatusForbidden)
+			return false
+		}
+		if !policy.CurrentGrants.HasRole(claims.Role) {
+			msg := fmt.Sprintf("Insufficient permissions: unknown user role %s", claims.Role)
+			log.Print(msg)
+			http.Error(w, msg, http.StatusForbidden)
+			return false
+		}
+		return true
+	}, next)
+}
 
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return jwtKey, nil
-			})
+// authenticate validates the bearer token, API key or access token cookie, asks allow whether its claims
+// may call the route, and passes the request on with the caller in the context. allow
+// writes the response when it refuses.
+func authenticate(allow func(w http.ResponseWriter, r *http.Request, claims *Claims) bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromRequest(w, r)
+		if !ok {
+			return
+		}
+		// A role that became scoped cannot be used by an API key, which has no employee
+		if claims.APIKeyId != 0 && policy.CurrentGrants.Scope(claims.Role) != "" {
+			http.Error(w, "Insufficient permissions: API keys cannot act with a scoped role", http.StatusForbidden)
+			return
+		}
+		if !allow(w, r, claims) {
+			return
+		}
 
-			if err != nil {
-				if err == jwt.ErrSignatureInvalid {
-					http.Error(w, "Invalid token signature", http.StatusUnauthorized)
-					return
-				}
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				return
-			}
+		// User is authorized; add the user's role and username to the context
+		ctx := WithEndpoint(WithClaims(r.Context(), claims), r.Method+" "+r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
 
-			if !token.Valid {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				return
-			}
+// claimsFromRequest authenticates the API key, the bearer token or, without either, the
+// access token cookie of a request, and writes the response when it fails.
+func claimsFromRequest(w http.ResponseWriter, r *http.Request) (*Claims, bool) {
+	if key, ok := apiKeyFromRequest(r); ok {
+		return authenticateAPIKey(w, r, key)
+	}
 
-			// Check if the user's role matches any of the required roles
-			roleIsAllowed := false
-			for _, requiredRole := range requiredRoles {
-				if claims.Role == requiredRole {
-					roleIsAllowed = true
-					break
-				}
-			}
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" && Cookies != nil {
+		if cookie, err := r.Cookie(AccessTokenCookie); err == nil {
+			return claimsFromCookie(w, r, cookie)
+		}
+	}
+	if authHeader == "" {
+		http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+		return nil, false
+	}
 
-			if !roleIsAllowed {
-				msg := fmt.Sprintf("Insufficient permissions: user role %s is not allowed", claims.Role)
-				log.Printf("Insufficient permissions: user role %s is not allowed", claims.Role)
-				http.Error(w, msg, http.StatusForbidden)
-				return
-			}
+	bearerToken := strings.Split(authHeader, "Bearer ")
+	if len(bearerToken) != 2 {
+		http.Error(w, "Invalid Authorization token format", http.StatusUnauthorized)
+		return nil, false
+	}
 
-			// User is authorized; add the user's role to the context
-			ctxWithRole := context.WithValue(r.Context(), RoleContextKey, claims.Role)
-			next.ServeHTTP(w, r.WithContext(ctxWithRole))
+	claims, err := ParseToken(r.Context(), bearerToken[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+// ParseToken validates a JWT issued by Login or RefreshToken: its signature with the
+// key named by kid and that key's algorithm, exp, nbf, iss and aud, and that it was
+// not revoked. It returns the claims and is shared by RequirePermission and the gRPC
+// auth interceptor.
+func ParseToken(ctx context.Context, tokenString string) (*Claims, error) {
+	if SigningKeys == nil || Denylist == nil {
+		return nil, errors.New("Token verification is not configured")
+	}
+	claims := &Claims{}
+	token, err := SigningKeys.Parse(tokenString, claims)
+	if err != nil {
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
+			return nil, errors.New("Invalid token signature")
 		}
+		return nil, errors.New("Invalid token")
+	}
+	// exp and nbf are checked by Parse when present; both must be
+	if claims.ExpiresAt == 0 || claims.NotBefore == 0 {
+		return nil, errors.New("Invalid token")
+	}
+	if !claims.VerifyIssuer(SigningKeys.Issuer, true) || !claims.VerifyAudience(SigningKeys.Audience, true) {
+		return nil, errors.New("Invalid token issuer or audience")
+	}
+	if !token.Valid || claims.Id == "" || claims.SessionId == "" {
+		return nil, errors.New("Invalid token")
 	}
+	revoked, err := Denylist.IsRevoked(ctx, claims.Id)
+	if err != nil {
+		log.Printf("Error checking token %s: %v", claims.Id, err)
+		return nil, errors.New("Token could not be checked")
+	}
+	if revoked {
+		return nil, errors.New("Token has been revoked")
+	}
+	return claims, nil
+}
+
+// WithClaims stores the authenticated user's role, username, employee ID and session
+// in the context.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	ctx = context.WithValue(ctx, RoleContextKey, claims.Role)
+	ctx = context.WithValue(ctx, EmployeeIdContextKey, claims.EmployeeId)
+	ctx = context.WithValue(ctx, SessionIdContextKey, claims.SessionId)
+	return context.WithValue(ctx, UsernameContextKey, claims.Username)
+}
+
+// WithEndpoint stores the endpoint the actor called, such as "PUT /v2/employee/101".
+func WithEndpoint(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, EndpointContextKey, endpoint)
+}
+
+// ActorFromContext returns the authenticated user stored in the context by RequirePermission
+// or RequireLogin.
+func ActorFromContext(ctx context.Context) schema.Actor {
+	username, _ := ctx.Value(UsernameContextKey).(string)
+	role, _ := ctx.Value(RoleContextKey).(string)
+	endpoint, _ := ctx.Value(EndpointContextKey).(string)
+	employeeId, _ := ctx.Value(EmployeeIdContextKey).(int)
+	return schema.Actor{Username: username, Role: role, Endpoint: endpoint, EmployeeId: employeeId}
 }
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// MaskReads applies the read policy for the caller's role to JSON responses. It must
+// run inside RequirePermission, which puts the role in the context. Responses to roles
+// without masks are passed through untouched.
+func MaskReads(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value(RoleContextKey).(string)
+		if !policy.CurrentRead.Masks(role) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buffered := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(buffered, r)
+
+		body := buffered.body.Bytes()
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			masked, err := policy.CurrentRead.MaskJSON(role, body)
+			if err != nil {
+				// Never fall back to the unmasked body
+				log.Printf("Error masking response for role %s: %v", role, err)
+				http.Error(w, "failed to prepare response", http.StatusInternalServerError)
+				return
+			}
+			body = masked
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(buffered.status)
+		w.Write(body)
+	}
+}
+
+// bufferedResponse holds a response until it has been masked.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) { b.status = status }
+
+func (b *bufferedResponse) Write(data []byte) (int, error) { return b.body.Write(data) }
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/mfa"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// mfaChallengeAudience keeps MFA challenge tokens from being mistaken for access
+// tokens, and the other way round.
+const mfaChallengeAudience = "mfa-challenge"
+
+// mfaChallengeClaims are the contents of an MFA challenge token: the user who gave
+// the right password, and whether they must enroll before answering.
+type mfaChallengeClaims struct {
+	UserId int  `json:"uid"`
+	Enroll bool `json:"enroll,omitempty"`
+	jwt.StandardClaims
+}
+
+// challengeMFA answers a correct password with an MFA challenge when the user has MFA
+// or their role requires it, and reports whether it wrote the response.
+func challengeMFA(w http.ResponseWriter, r *http.Request, user *schema.User, mfaStore mfa.Store) bool {
+	status, err := service.GetMFAStatus(r.Context(), mfaStore, user)
+	if err != nil {
+		log.Printf("Error reading the MFA of %s: %v", user.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	if !status.Enabled && !status.Required {
+		return false
+	}
+
+	now := time.Now()
+	lifetime := policy.CurrentMFA.ChallengeLifetime
+	claims := &mfaChallengeClaims{
+		UserId: user.UserId,
+		Enroll: !status.Enabled,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   user.Username,
+			Issuer:    SigningKeys.Issuer,
+			Audience:  mfaChallengeAudience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(lifetime).Unix(),
+		},
+	}
+	token, err := SigningKeys.Sign(claims)
+	if err != nil {
+		log.Printf("Error signing an MFA challenge for %s: %v", user.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+	log.Printf("Password of %s accepted, waiting for the second factor", user.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(schema.MFAChallenge{MfaToken: token, ExpiresIn: int(lifetime.Seconds()), EnrollmentRequired: claims.Enroll})
+	return true
+}
+
+// parseMFAChallenge verifies an MFA challenge token issued by challengeMFA.
+func parseMFAChallenge(tokenString string) (*mfaChallengeClaims, error) {
+	claims := &mfaChallengeClaims{}
+	if _, err := SigningKeys.Parse(tokenString, claims); err != nil || claims.ExpiresAt == 0 || claims.UserId == 0 ||
+		!claims.VerifyIssuer(SigningKeys.Issuer, true) || !claims.VerifyAudience(mfaChallengeAudience, true) {
+		return nil, errors.New("The MFA challenge is invalid or has expired; log in again")
+	}
+	return claims, nil
+}
+
+// LoginMFA is the second step of a login: it checks a code of the authenticator app or
+// a recovery code against the user of the MFA challenge and starts the session. A
+// user who had to enroll confirms the enrollment with the first code instead, and
+// gets the recovery codes with the tokens. Wrong codes count as failed logins.
+func LoginMFA(store users.Store, sessionStore sessions.Store, mfaStore mfa.Store, guard *loginguard.Guard) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body schema.MFALogin
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		challenge, err := parseMFAChallenge(body.MfaToken)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ip := ClientIP(r)
+		retryAfter, attempts := guard.Attempt(r.Context(), challenge.Subject, ip)
+		if retryAfter > 0 {
+			tooManyLogins(w, retryAfter)
+			return
+		}
+
+		user, ok := challengedUser(w, r, store, challenge)
+		if !ok {
+			guard.Cancel(r.Context(), challenge.Subject, ip)
+			return
+		}
+		status, err := service.GetMFAStatus(r.Context(), mfaStore, user)
+		if err != nil {
+			log.Printf("Error reading the MFA of %s: %v", user.Username, err)
+			guard.Cancel(r.Context(), user.Username, ip)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var recoveryCodes []string
+		if status.Enabled {
+			err = service.VerifyMFA(r.Context(), mfaStore, user, body.MFACode)
+		} else {
+			var codes *schema.RecoveryCodes
+			if codes, err = service.ConfirmMFAEnrollment(r.Context(), mfaStore, user, body.Code); err == nil {
+				recoveryCodes = codes.RecoveryCodes
+			}
+		}
+
+		var validationErr *service.ValidationError
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode):
+			loginguard.Wait(r.Context(), guard.Failed(r.Context(), user.Username, ip, "invalid MFA code", attempts))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case errors.Is(err, dbs.ErrMFANotFound):
+			guard.Cancel(r.Context(), user.Username, ip)
+			http.Error(w, "Start the enrollment at /v2/login/mfa/enroll first", http.StatusBadRequest)
+			return
+		case errors.As(err, &validationErr):
+			guard.Cancel(r.Context(), user.Username, ip)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("Error checking the second factor of %s: %v", user.Username, err)
+			guard.Cancel(r.Context(), user.Username, ip)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		guard.Succeeded(r.Context(), user.Username, ip)
+
+		log.Printf("User authenticated with MFA: %s at %s", user.Username, time.Now().Format(time.RFC3339))
+		startSession(w, r, user, sessionStore, recoveryCodes, wantsCookies(r))
+	}
+}
+
+// LoginMFAEnroll gives a user whose role requires MFA, and who has none yet, a TOTP
+// secret during the login, to confirm with a code at LoginMFA.
+func LoginMFAEnroll(store users.Store, mfaStore mfa.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			MfaToken string `json:"mfaToken"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		challenge, err := parseMFAChallenge(body.MfaToken)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !challenge.Enroll {
+			http.Error(w, "MFA is already enabled; send a code to /v2/login/mfa", http.StatusConflict)
+			return
+		}
+
+		user, ok := challengedUser(w, r, store, challenge)
+		if !ok {
+			return
+		}
+		enrollment, err := service.BeginMFAEnrollment(r.Context(), mfaStore, user)
+		if errors.Is(err, dbs.ErrMFAEnabled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Error starting the MFA enrollment of %s: %v", user.Username, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(enrollment)
+	}
+}
+
+// challengedUser reads the user of an MFA challenge again, refusing users that were
+// deleted or disabled since the password step.
+func challengedUser(w http.ResponseWriter, r *http.Request, store users.Store, challenge *mfaChallengeClaims) (*schema.User, bool) {
+	user, err := store.Get(r.Context(), challenge.UserId)
+	if errors.Is(err, dbs.ErrUserNotFound) || (err == nil && user.Username != challenge.Subject) {
+		http.Error(w, "The MFA challenge is invalid or has expired; log in again", http.StatusUnauthorized)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error reading user %d: %v", challenge.UserId, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if user.Disabled {
+		w.WriteHeader(http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/oidc"
+	"autotools-golang-api/kubecloudsinc/backend/service"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// oidcLoginCookie carries the state, nonce and code verifier of a sign-in from
+// OIDCLogin to OIDCCallback, signed with SigningKeys so any instance can check it.
+const oidcLoginCookie = "oidc_login"
+
+// oidcLoginAudience keeps the login state from being mistaken for an access token.
+const oidcLoginAudience = "oidc-login"
+
+// oidcLoginLifetime is how long the user has to sign in at the provider.
+const oidcLoginLifetime = 10 * time.Minute
+
+// oidcLoginClaims are the contents of oidcLoginCookie.
+type oidcLoginClaims struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.StandardClaims
+}
+
+// OIDCLogin starts a sign-in at the OpenID Connect provider: it remembers a new state,
+// nonce and PKCE code verifier in a short-lived cookie and redirects the browser to
+// the provider's authorization endpoint.
+func OIDCLogin(provider *oidc.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil || Cookies == nil {
+			http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+			return
+		}
+
+		var claims oidcLoginClaims
+		for _, secret := range []*string{&claims.State, &claims.Nonce, &claims.Verifier} {
+			var err error
+			if *secret, err = oidc.NewSecret(); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		authURL, err := provider.AuthCodeURL(r.Context(), claims.State, claims.Nonce, claims.Verifier)
+		if err != nil {
+			log.Printf("Error starting an OIDC sign-in: %v", err)
+			http.Error(w, "The identity provider is unavailable", http.StatusBadGateway)
+			return
+		}
+
+		now := time.Now()
+		claims.StandardClaims = jwt.StandardClaims{
+			Issuer:    SigningKeys.Issuer,
+			Audience:  oidcLoginAudience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(oidcLoginLifetime).Unix(),
+		}
+		state, err := SigningKeys.Sign(&claims)
+		if err != nil {
+			log.Printf("Error signing the OIDC login state: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		setOIDCLoginCookie(w, provider, state, int(oidcLoginLifetime.Seconds()))
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// OIDCCallback finishes a sign-in started by OIDCLogin. It checks the state against
+// the cookie, exchanges the code with the code verifier, verifies the ID token and
+// its nonce, and starts a session for the user like Login, with the role their groups
+// map to. The callback is a browser navigation, so the session is always a cookie
+// session; main refuses to enable OIDC without cookie sessions.
+func OIDCCallback(provider *oidc.Provider, store users.Store, sessionStore sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil || Cookies == nil {
+			http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+			return
+		}
+
+		// The login state works once, whatever the outcome
+		cookie, err := r.Cookie(oidcLoginCookie)
+		setOIDCLoginCookie(w, provider, "", -1)
+		if err != nil {
+			http.Error(w, "The sign-in has expired or was started in another browser", http.StatusBadRequest)
+			return
+		}
+		var login oidcLoginClaims
+		if _, err := SigningKeys.Parse(cookie.Value, &login); err != nil || login.ExpiresAt == 0 || !login.VerifyAudience(oidcLoginAudience, true) {
+			http.Error(w, "The sign-in has expired or was started in another browser", http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+			http.Error(w, "The sign-in state does not match", http.StatusBadRequest)
+			return
+		}
+		if providerErr := query.Get("error"); providerErr != "" {
+			log.Printf("OIDC sign-in refused by the provider: %s %s", providerErr, query.Get("error_description"))
+			http.Error(w, "The identity provider refused the sign-in: "+providerErr, http.StatusUnauthorized)
+			return
+		}
+		code := query.Get("code")
+		if code == "" {
+			http.Error(w, "code is required", http.StatusBadRequest)
+			return
+		}
+
+		identity, err := provider.Exchange(r.Context(), code, login.Verifier, login.Nonce)
+		if errors.Is(err, oidc.ErrTokenRejected) {
+			log.Printf("OIDC sign-in failed: %v", err)
+			http.Error(w, "The sign-in could not be verified", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Error finishing an OIDC sign-in: %v", err)
+			http.Error(w, "The identity provider is unavailable", http.StatusBadGateway)
+			return
+		}
+
+		user, err := service.SignInWithOIDC(r.Context(), store, sessionStore, provider.Config, identity)
+		var validationErr *service.ValidationError
+		switch {
+		case errors.Is(err, service.ErrNoMappedRole), errors.Is(err, users.ErrUserDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, dbs.ErrUserExists):
+			log.Printf("OIDC sign-in of subject %s refused: username %s belongs to a local user", identity.Subject, identity.Username)
+			http.Error(w, "A local user already has this username", http.StatusConflict)
+			return
+		case errors.As(err, &validationErr):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			log.Printf("Error signing in OIDC subject %s: %v", identity.Subject, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("User authenticated by OIDC: %s at %s", user.Username, time.Now().Format(time.RFC3339))
+		startSession(w, r, user, sessionStore, nil, true)
+	}
+}
+
+// setOIDCLoginCookie stores or, with maxAge -1, deletes the login state. It is only
+// sent back to the callback, and is Secure when the callback is served over https.
+// SameSite=Lax lets it accompany the provider's redirect.
+func setOIDCLoginCookie(w http.ResponseWriter, provider *oidc.Provider, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    value,
+		Path:     "/v2/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(provider.Config.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
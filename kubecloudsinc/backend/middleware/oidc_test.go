@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/oidc"
+	"autotools-golang-api/kubecloudsinc/backend/oidc/mockidp"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/signing"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// oidcTest signs users in against a mock provider.
+type oidcTest struct {
+	t        *testing.T
+	provider *oidc.Provider
+	callback http.HandlerFunc
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+	keys, err := signing.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Issuer, keys.Audience = "employee-api-test", "employee-api-test"
+	SigningKeys = keys
+	Cookies = &CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode}
+	t.Cleanup(func() { Cookies = nil })
+
+	var idp *mockidp.Server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { idp.ServeHTTP(w, r) }))
+	t.Cleanup(server.Close)
+	idpUsers, err := mockidp.LoadUsers("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idp, err = mockidp.New(server.URL, "employee-api", "", idpUsers); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := oidc.ParseConfig([]byte("issuer: " + server.URL + "\nclientId: employee-api\nredirectUrl: https://api.example/v2/auth/oidc/callback\ngroupRoles:\n  - group: everyone\n    role: viewer\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStore := sessions.NewMemoryStore()
+	Denylist = sessionStore
+	provider := oidc.NewProvider(config)
+	return &oidcTest{t: t, provider: provider, callback: OIDCCallback(provider, users.NewMemoryStore(), sessionStore)}
+}
+
+// authorize starts a sign-in as linus and returns the login state cookie and the
+// query the provider redirects back with. tamper may change the authorization request
+// before it reaches the provider.
+func (o *oidcTest) authorize(tamper func(url.Values)) (*http.Cookie, url.Values) {
+	o.t.Helper()
+	rec := httptest.NewRecorder()
+	OIDCLogin(o.provider)(rec, httptest.NewRequest("GET", "/v2/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		o.t.Fatalf("login returned %d, want 302: %s", rec.Code, rec.Body.String())
+	}
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcLoginCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		o.t.Fatal("login set no state cookie")
+	}
+
+	authURL, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	query := authURL.Query()
+	query.Set("login_hint", "linus")
+	if tamper != nil {
+		tamper(query)
+	}
+	authURL.RawQuery = query.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL.String())
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	resp.Body.Close()
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		o.t.Fatalf("the provider answered %d, want a redirect", resp.StatusCode)
+	}
+	return cookie, back.Query()
+}
+
+// finish calls the callback with the login state cookie and query.
+func (o *oidcTest) finish(cookie *http.Cookie, query url.Values) *httptest.ResponseRecorder {
+	o.t.Helper()
+	req := httptest.NewRequest("GET", "/v2/auth/oidc/callback?"+query.Encode(), nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	o.callback(rec, req)
+	return rec
+}
+
+func TestOIDCCallbackStartsACookieSession(t *testing.T) {
+	o := newOIDCTest(t)
+	rec := o.finish(o.authorize(nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback returned %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"token", "refreshToken"} {
+		if _, ok := body[key]; ok {
+			t.Errorf("the response body holds %s", key)
+		}
+	}
+	if body["csrfToken"] == nil {
+		t.Error("the response body holds no CSRF token")
+	}
+	var sessionCookies int
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcLoginCookie || c.Value == "" {
+			continue
+		}
+		sessionCookies++
+		if c.Name != CSRFCookie && strings.Contains(rec.Body.String(), c.Value) {
+			t.Errorf("the response body holds the value of cookie %s", c.Name)
+		}
+	}
+	if sessionCookies == 0 {
+		t.Fatal("the callback set no session cookies")
+	}
+}
+
+func TestOIDCCallbackRejectsAnotherState(t *testing.T) {
+	o := newOIDCTest(t)
+	cookie, query := o.authorize(nil)
+	query.Set("state", "state-of-another-sign-in")
+	if rec := o.finish(cookie, query); rec.Code != http.StatusBadRequest {
+		t.Fatalf("callback returned %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOIDCCallbackRejectsAnotherNonce(t *testing.T) {
+	o := newOIDCTest(t)
+	// An ID token minted for another sign-in carries that sign-in's nonce
+	cookie, query := o.authorize(func(request url.Values) { request.Set("nonce", "nonce-of-another-sign-in") })
+	rec := o.finish(cookie, query)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("callback returned %d, want 401: %s", rec.Code, rec.Body.String())
+	}
+	if len(rec.Result().Cookies()) != 1 {
+		t.Fatal("a rejected callback set session cookies")
+	}
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// tokenResponse is the body of a login or refresh. A cookie session gets the CSRF token
+// instead of the tokens, which are in HttpOnly cookies.
+type tokenResponse struct {
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	ExpiresIn    int    `json:"expiresIn"`
+	CSRFToken    string `json:"csrfToken,omitempty"`
+	// RecoveryCodes are only sent by the login that completes an MFA enrollment
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh
+// token. The claims are read from the user again, so role changes apply at the next
+// refresh. A refresh token works once: presenting it again revokes the session. Without
+// a refresh token in the body, or without a body, the refresh token cookie of a cookie
+// session is used, with its CSRF token, and the new tokens are set as cookies again.
+func RefreshToken(store users.Store, sessionStore sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// A cookie session may send no body at all
+		var body schema.TokenRefresh
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "refreshToken is required", http.StatusBadRequest)
+			return
+		}
+		cookie := false
+		if body.RefreshToken == "" && Cookies != nil {
+			if c, err := r.Cookie(RefreshTokenCookie); err == nil {
+				if err := checkCSRF(r, ""); err != nil {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+				body.RefreshToken, cookie = c.Value, true
+			}
+		}
+		if body.RefreshToken == "" {
+			http.Error(w, "refreshToken is required", http.StatusBadRequest)
+			return
+		}
+
+		refreshToken, record, err := sessions.NewRefreshToken()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		session, err := sessionStore.Rotate(r.Context(), sessions.HashToken(body.RefreshToken), record)
+		if errors.Is(err, dbs.ErrRefreshTokenInvalid) || errors.Is(err, dbs.ErrRefreshTokenReused) {
+			if cookie {
+				clearSessionCookies(w)
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Error refreshing a token: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		user, err := store.Get(r.Context(), session.UserId)
+		if err == nil && user.Disabled {
+			err = users.ErrUserDisabled
+		}
+		if errors.Is(err, dbs.ErrUserNotFound) || errors.Is(err, users.ErrUserDisabled) {
+			if err := sessionStore.Revoke(r.Context(), session.SessionId, schema.SessionRevokedUserChanged); err != nil {
+				log.Printf("Error revoking session %s: %v", session.SessionId, err)
+			}
+			if cookie {
+				clearSessionCookies(w)
+			}
+			http.Error(w, dbs.ErrRefreshTokenInvalid.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading user %d: %v", session.UserId, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writeTokens(w, user, session.SessionId, refreshToken, record, nil, cookie)
+	}
+}
+
+// Logout revokes the caller's session: its refresh tokens stop working and its access
+// tokens, including the one of this request, are refused from now on. The cookies of a
+// cookie session are deleted.
+func Logout(sessionStore sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor := ActorFromContext(r.Context())
+		sessionId := SessionIdFromContext(r.Context())
+		if err := sessionStore.Revoke(r.Context(), sessionId, schema.SessionRevokedLogout); err != nil {
+			log.Printf("Error revoking session %s: %v", sessionId, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Printf("User %s logged out of session %s", actor.Username, sessionId)
+		clearSessionCookies(w)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Successfully logged out"})
+	}
+}
+
+// SessionIdFromContext returns the session of the access token stored in the context.
+func SessionIdFromContext(ctx context.Context) string {
+	sessionId, _ := ctx.Value(SessionIdContextKey).(string)
+	return sessionId
+}
+
+// writeTokens signs the access token described by record for user with SigningKeys and
+// sends it with the refresh token and any new recovery codes. With cookie, the tokens
+// are set as cookies with a new CSRF token, whose hash the access token carries.
+func writeTokens(w http.ResponseWriter, user *schema.User, sessionId, refreshToken string, record schema.RefreshToken, recoveryCodes []string, cookie bool) {
+	claims := &Claims{
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionId: sessionId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        record.AccessJti,
+			Issuer:    SigningKeys.Issuer,
+			Audience:  SigningKeys.Audience,
+			IssuedAt:  record.IssuedAt.Unix(),
+			NotBefore: record.IssuedAt.Unix(),
+			ExpiresAt: record.AccessExpiresAt.Unix(),
+		},
+	}
+	if user.EmployeeId != nil {
+		claims.EmployeeId = *user.EmployeeId
+	}
+	var csrfToken string
+	if cookie {
+		var err error
+		if csrfToken, claims.CSRFHash, err = newCSRFToken(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tokenString, err := SigningKeys.Sign(claims)
+	if err != nil {
+		log.Printf("Error signing a token for %s: %v", user.Username, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Token generated for user: %s in session %s", user.Username, sessionId)
+
+	response := tokenResponse{
+		ExpiresIn:     int(sessions.AccessTokenLifetime.Seconds()),
+		RecoveryCodes: recoveryCodes,
+	}
+	if cookie {
+		// Scripts of the page only ever see the CSRF token
+		setSessionCookies(w, tokenString, refreshToken, csrfToken)
+		response.CSRFToken = csrfToken
+	} else {
+		response.Token, response.RefreshToken = tokenString, refreshToken
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ServeJWKS publishes the public keys that verify access tokens, so other services can
+// check them without a shared secret. Verifiers should pick the key by the kid header
+// and refetch the set when they meet an unknown kid.
+func ServeJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(SigningKeys.JWKS())
+}
@@ -0,0 +1,16 @@
+-- Transactional outbox for employee change events.
+-- Rows are written in the same transaction as the employee mutation and
+-- drained by the relay started in main.go.
+CREATE TABLE employee_outbox (
+    event_id     NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_type   VARCHAR2(64)   NOT NULL,
+    employee_id  NUMBER(6)      NOT NULL,
+    actor        VARCHAR2(100),
+    payload      CLOB           NOT NULL CHECK (payload IS JSON),
+    created_at   TIMESTAMP      DEFAULT SYSTIMESTAMP NOT NULL,
+    published_at TIMESTAMP,
+    attempts     NUMBER         DEFAULT 0 NOT NULL,
+    last_error   VARCHAR2(4000)
+);
+
+CREATE INDEX employee_outbox_pending_ix ON employee_outbox (published_at, event_id);
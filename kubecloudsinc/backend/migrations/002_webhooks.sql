@@ -0,0 +1,28 @@
+-- Outbound webhook subscriptions and their delivery log.
+CREATE TABLE webhook_subscriptions (
+    subscription_id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    url             VARCHAR2(2000) NOT NULL,
+    event_types     VARCHAR2(1000) NOT NULL,
+    secret          VARCHAR2(200)  NOT NULL,
+    active          NUMBER(1)      DEFAULT 1 NOT NULL,
+    created_by      VARCHAR2(100),
+    created_at      TIMESTAMP      DEFAULT SYSTIMESTAMP NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    delivery_id      NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscription_id  NUMBER         NOT NULL REFERENCES webhook_subscriptions (subscription_id) ON DELETE CASCADE,
+    event_id         NUMBER         NOT NULL,
+    event_type       VARCHAR2(64)   NOT NULL,
+    payload          CLOB           NOT NULL,
+    status           VARCHAR2(16)   DEFAULT 'pending' NOT NULL,
+    attempts         NUMBER         DEFAULT 0 NOT NULL,
+    last_status_code NUMBER(3),
+    last_error       VARCHAR2(4000),
+    next_attempt_at  TIMESTAMP,
+    created_at       TIMESTAMP      DEFAULT SYSTIMESTAMP NOT NULL,
+    delivered_at     TIMESTAMP,
+    CONSTRAINT webhook_deliveries_uk UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_ix ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,19 @@
+-- Employee changes scheduled for a future effective date.
+-- Pending rows are applied by the scheduler started in main.go once
+-- effective_date is reached; applied and cancelled rows are kept as history.
+CREATE TABLE employee_pending_changes (
+    change_id      NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    employee_id    NUMBER(6)      NOT NULL REFERENCES employees (employee_id) ON DELETE CASCADE,
+    changes        CLOB           NOT NULL CHECK (changes IS JSON),
+    effective_date DATE           NOT NULL,
+    status         VARCHAR2(16)   DEFAULT 'pending' NOT NULL,
+    created_by     VARCHAR2(100),
+    created_role   VARCHAR2(32),
+    created_at     TIMESTAMP      DEFAULT SYSTIMESTAMP NOT NULL,
+    cancelled_by   VARCHAR2(100),
+    applied_at     TIMESTAMP,
+    last_error     VARCHAR2(4000)
+);
+
+CREATE INDEX employee_pending_changes_due_ix ON employee_pending_changes (status, effective_date);
+CREATE INDEX employee_pending_changes_emp_ix ON employee_pending_changes (employee_id, effective_date);
@@ -0,0 +1,19 @@
+-- Editor updates touching restricted fields wait here for an admin's review.
+-- changes holds the values of the changed_fields only; they are merged into the
+-- employee as it is when the request is approved.
+CREATE TABLE employee_change_requests (
+    change_request_id NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    employee_id       NUMBER(6)      NOT NULL REFERENCES employees (employee_id) ON DELETE CASCADE,
+    changes           CLOB           NOT NULL CHECK (changes IS JSON),
+    changed_fields    VARCHAR2(200)  NOT NULL,
+    restricted_fields VARCHAR2(200)  NOT NULL,
+    status            VARCHAR2(16)   DEFAULT 'pending' NOT NULL,
+    requested_by      VARCHAR2(100),
+    requested_role    VARCHAR2(32),
+    requested_at      TIMESTAMP      DEFAULT SYSTIMESTAMP NOT NULL,
+    reviewed_by       VARCHAR2(100),
+    reviewed_at       TIMESTAMP,
+    review_comment    VARCHAR2(2000)
+);
+
+CREATE INDEX employee_change_requests_st_ix ON employee_change_requests (status, requested_at);
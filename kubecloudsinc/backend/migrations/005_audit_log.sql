@@ -0,0 +1,39 @@
+-- Append-only audit log of employee mutations. Entries are written in the same
+-- transaction as the mutation; audit_log_changes holds one row per changed field
+-- with the old and new value as JSON. Both tables reject updates and deletes.
+CREATE TABLE audit_log (
+    audit_id       NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    occurred_at    TIMESTAMP      DEFAULT SYSTIMESTAMP NOT NULL,
+    actor_username VARCHAR2(100),
+    actor_role     VARCHAR2(32),
+    endpoint       VARCHAR2(200),
+    action         VARCHAR2(64)   NOT NULL,
+    employee_id    NUMBER(6)      NOT NULL
+);
+
+CREATE TABLE audit_log_changes (
+    audit_id  NUMBER         NOT NULL REFERENCES audit_log (audit_id),
+    field     VARCHAR2(64)   NOT NULL,
+    old_value VARCHAR2(4000) CHECK (old_value IS JSON),
+    new_value VARCHAR2(4000) CHECK (new_value IS JSON),
+    CONSTRAINT audit_log_changes_pk PRIMARY KEY (audit_id, field)
+);
+
+CREATE INDEX audit_log_employee_ix ON audit_log (employee_id, occurred_at);
+CREATE INDEX audit_log_actor_ix ON audit_log (actor_username, occurred_at);
+CREATE INDEX audit_log_occurred_ix ON audit_log (occurred_at);
+CREATE INDEX audit_log_changes_field_ix ON audit_log_changes (field, audit_id);
+
+CREATE OR REPLACE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+BEGIN
+    RAISE_APPLICATION_ERROR(-20001, 'audit_log is append-only');
+END;
+/
+
+CREATE OR REPLACE TRIGGER audit_log_changes_append_only
+    BEFORE UPDATE OR DELETE ON audit_log_changes
+BEGIN
+    RAISE_APPLICATION_ERROR(-20001, 'audit_log_changes is append-only');
+END;
+/
@@ -0,0 +1,11 @@
+-- Logins for the API. Passwords are stored as bcrypt hashes only. employee_id links
+-- a user to their own employee record, which roles scoped to their reports need.
+CREATE TABLE app_users (
+    user_id       NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    username      VARCHAR2(100) NOT NULL CONSTRAINT app_users_username_uk UNIQUE,
+    password_hash VARCHAR2(100) NOT NULL,
+    role          VARCHAR2(32)  NOT NULL,
+    employee_id   NUMBER(6)     REFERENCES employees (employee_id) ON DELETE SET NULL,
+    created_at    TIMESTAMP     DEFAULT SYSTIMESTAMP NOT NULL,
+    updated_at    TIMESTAMP     DEFAULT SYSTIMESTAMP NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Disabled users keep their record but can no longer log in. password_changed_at
+-- records the last time the password was set by the user or reset by an admin.
+ALTER TABLE app_users ADD (
+    disabled            NUMBER(1) DEFAULT 0 NOT NULL CONSTRAINT app_users_disabled_ck CHECK (disabled IN (0, 1)),
+    password_changed_at TIMESTAMP DEFAULT SYSTIMESTAMP NOT NULL
+);
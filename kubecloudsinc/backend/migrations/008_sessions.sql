@@ -0,0 +1,30 @@
+-- Login sessions with rotating refresh tokens. Only SHA-256 hashes of refresh tokens
+-- are stored. A refresh token is used once; presenting it again revokes its session.
+CREATE TABLE auth_sessions (
+    session_id    VARCHAR2(32) PRIMARY KEY,
+    user_id       NUMBER       NOT NULL REFERENCES app_users (user_id) ON DELETE CASCADE,
+    created_at    TIMESTAMP    DEFAULT SYSTIMESTAMP NOT NULL,
+    expires_at    TIMESTAMP    NOT NULL,
+    revoked_at    TIMESTAMP,
+    revoke_reason VARCHAR2(32)
+);
+
+CREATE INDEX auth_sessions_user_ix ON auth_sessions (user_id);
+
+CREATE TABLE auth_refresh_tokens (
+    token_hash        VARCHAR2(64) PRIMARY KEY,
+    session_id        VARCHAR2(32) NOT NULL REFERENCES auth_sessions (session_id) ON DELETE CASCADE,
+    access_jti        VARCHAR2(32) NOT NULL,
+    access_expires_at TIMESTAMP    NOT NULL,
+    issued_at         TIMESTAMP    NOT NULL,
+    expires_at        TIMESTAMP    NOT NULL,
+    used_at           TIMESTAMP
+);
+
+CREATE INDEX auth_refresh_tokens_session_ix ON auth_refresh_tokens (session_id);
+
+-- Access tokens refused until they expire on their own
+CREATE TABLE auth_revoked_tokens (
+    jti        VARCHAR2(32) PRIMARY KEY,
+    expires_at TIMESTAMP    NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Users who sign in through the OpenID Connect provider are found by the subject (sub)
+-- of their ID token rather than by username, which the provider may let them change.
+-- Local users have no subject.
+ALTER TABLE app_users ADD (
+    oidc_subject VARCHAR2(255) CONSTRAINT app_users_oidc_subject_uk UNIQUE
+);
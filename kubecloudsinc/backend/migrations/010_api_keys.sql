@@ -0,0 +1,20 @@
+-- API keys of batch jobs and integrations. Only SHA-256 hashes of the keys are stored;
+-- prefix holds the first characters so admins can recognise a key. scopes lists the
+-- permissions the key may use, allowed_ips the addresses and CIDR ranges it may be
+-- used from (empty for any), both comma-separated. Revoked keys are kept for the record.
+CREATE TABLE api_keys (
+    key_id       NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name         VARCHAR2(100)  NOT NULL CONSTRAINT api_keys_name_uk UNIQUE,
+    prefix       VARCHAR2(16)   NOT NULL,
+    key_hash     VARCHAR2(64)   NOT NULL CONSTRAINT api_keys_hash_uk UNIQUE,
+    role         VARCHAR2(32)   NOT NULL,
+    scopes       VARCHAR2(1000) NOT NULL,
+    allowed_ips  VARCHAR2(1000),
+    rate_limit   NUMBER(6)      NOT NULL,
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR2(45),
+    created_by   VARCHAR2(100)  NOT NULL,
+    created_at   TIMESTAMP      DEFAULT SYSTIMESTAMP NOT NULL,
+    revoked_at   TIMESTAMP
+);
@@ -0,0 +1,15 @@
+-- Security events of /v2/login: failed logins, the lockouts they caused and admins
+-- lifting them. username holds the name that was tried, whether or not it belongs to
+-- a user, so it is not a foreign key.
+CREATE TABLE security_events (
+    event_id       NUMBER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    occurred_at    TIMESTAMP     DEFAULT SYSTIMESTAMP NOT NULL,
+    event_type     VARCHAR2(32)  NOT NULL,
+    username       VARCHAR2(100),
+    ip_address     VARCHAR2(45),
+    actor_username VARCHAR2(100),
+    detail         VARCHAR2(400) NOT NULL
+);
+
+CREATE INDEX security_events_time_ix ON security_events (occurred_at);
+CREATE INDEX security_events_username_ix ON security_events (username, occurred_at);
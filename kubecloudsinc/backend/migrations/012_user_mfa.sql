@@ -0,0 +1,19 @@
+-- TOTP second factor of users. secret is encrypted with AES-256-GCM under the key of
+-- MFA_ENCRYPTION_KEY_FILE; enabled_at stays empty until the enrollment is confirmed
+-- with a code. last_step is the time step of the last accepted code, so a code works
+-- once. Recovery codes are only stored as SHA-256 hashes.
+CREATE TABLE user_mfa (
+    user_id    NUMBER        PRIMARY KEY REFERENCES app_users (user_id) ON DELETE CASCADE,
+    secret     VARCHAR2(200) NOT NULL,
+    created_at TIMESTAMP     DEFAULT SYSTIMESTAMP NOT NULL,
+    enabled_at TIMESTAMP,
+    last_step  NUMBER(12)    DEFAULT 0 NOT NULL
+);
+
+CREATE TABLE user_recovery_codes (
+    code_hash VARCHAR2(64) PRIMARY KEY,
+    user_id   NUMBER       NOT NULL REFERENCES app_users (user_id) ON DELETE CASCADE,
+    used_at   TIMESTAMP
+);
+
+CREATE INDEX user_recovery_codes_user_ix ON user_recovery_codes (user_id);
@@ -0,0 +1,119 @@
+// Package oidc signs users in through an external OpenID Connect provider with the
+// authorization code flow and PKCE, and maps the provider's groups to roles.
+package oidc
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the format of OIDC_CONFIG_FILE. The client secret is not part of it; it
+// comes from OIDC_CLIENT_SECRET.
+type Config struct {
+	// Issuer is the provider's issuer URL, where its discovery document lives.
+	Issuer string `yaml:"issuer"`
+	// ClientId is the ID of this service at the provider; ID tokens must be issued for it.
+	ClientId string `yaml:"clientId"`
+	// ClientSecret authenticates this service at the token endpoint. Public clients
+	// leave it empty and rely on PKCE alone.
+	ClientSecret string `yaml:"-"`
+	// RedirectURL is the callback registered at the provider.
+	RedirectURL string `yaml:"redirectUrl"`
+	// Scopes are requested at the authorization endpoint; openid is always included.
+	Scopes []string `yaml:"scopes"`
+	// UsernameClaim names the ID token claim that becomes the username of new users.
+	UsernameClaim string `yaml:"usernameClaim"`
+	// GroupsClaim names the ID token claim that lists the user's groups.
+	GroupsClaim string `yaml:"groupsClaim"`
+	// GroupRoles map groups to roles. The first rule whose group the user is in wins.
+	GroupRoles []GroupRole `yaml:"groupRoles"`
+}
+
+// GroupRole grants Role to the members of Group.
+type GroupRole struct {
+	Group string `yaml:"group"`
+	Role  string `yaml:"role"`
+}
+
+// LoadConfig reads the YAML or JSON file at path. Roles are checked against
+// policy.CurrentGrants, which must be loaded first.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OIDC config: %v", err)
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig decodes a config, rejecting unknown keys and roles that are not granted,
+// and fills in the default scopes and claims.
+func ParseConfig(data []byte) (*Config, error) {
+	var c Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Issuer == "" || c.ClientId == "" || c.RedirectURL == "" {
+		return nil, errors.New("issuer, clientId and redirectUrl are required")
+	}
+	for _, raw := range []string{c.Issuer, c.RedirectURL} {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("%q is not an absolute http(s) URL", raw)
+		}
+	}
+	if len(c.GroupRoles) == 0 {
+		return nil, errors.New("groupRoles must map at least one group to a role")
+	}
+	for _, rule := range c.GroupRoles {
+		if rule.Group == "" {
+			return nil, errors.New("groupRoles: group is required")
+		}
+		if !policy.CurrentGrants.HasRole(rule.Role) {
+			return nil, fmt.Errorf("groupRoles: group %s: unknown role %q", rule.Group, rule.Role)
+		}
+	}
+
+	if !contains(c.Scopes, "openid") {
+		c.Scopes = append([]string{"openid"}, c.Scopes...)
+	}
+	if len(c.Scopes) == 1 {
+		c.Scopes = append(c.Scopes, "profile", "email")
+	}
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = "preferred_username"
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = "groups"
+	}
+	return &c, nil
+}
+
+// RoleFor returns the role of the first rule that matches one of groups.
+func (c *Config) RoleFor(groups []string) (string, bool) {
+	for _, rule := range c.GroupRoles {
+		if contains(groups, rule.Group) {
+			return rule.Role, true
+		}
+	}
+	return "", false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+// Package mockidp is an OpenID Connect provider for local development and testing of
+// the sign-in flow. It supports the authorization code flow with PKCE only, keeps
+// everything in memory and asks for no password: whoever opens its sign-in page can
+// be any of its users. Never expose it.
+package mockidp
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/oidc"
+	"autotools-golang-api/kubecloudsinc/backend/signing"
+	"bytes"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed users.yaml
+var defaultUsers []byte
+
+// codeLifetime is how long an authorization code can be exchanged.
+const codeLifetime = time.Minute
+
+// User is a user of the mock provider and the claims of their ID tokens.
+type User struct {
+	Username string   `yaml:"username"`
+	Subject  string   `yaml:"subject"`
+	Email    string   `yaml:"email"`
+	Groups   []string `yaml:"groups"`
+}
+
+// grant is an authorization code waiting to be exchanged.
+type grant struct {
+	user        User
+	redirectURI string
+	nonce       string
+	challenge   string
+	expiresAt   time.Time
+}
+
+// Server is the mock provider. Issuer must be the URL it is reached at.
+type Server struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+
+	users []User
+	keys  *signing.KeySet
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// LoadUsers reads the users file at path, or the built-in users when path is empty.
+func LoadUsers(path string) ([]User, error) {
+	data := defaultUsers
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read mock IdP users: %v", err)
+		}
+	}
+	var file struct {
+		Users []User `yaml:"users"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid mock IdP users %s: %v", path, err)
+	}
+	return file.Users, nil
+}
+
+// New returns a provider for clientId with a new signing key. An empty clientSecret
+// makes it accept a public client.
+func New(issuer, clientId, clientSecret string, users []User) (*Server, error) {
+	keys, err := signing.Generate()
+	if err != nil {
+		return nil, err
+	}
+	keys.Issuer, keys.Audience = issuer, clientId
+	return &Server{Issuer: strings.TrimSuffix(issuer, "/"), ClientId: clientId, ClientSecret: clientSecret, users: users, keys: keys, codes: make(map[string]grant)}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                s.Issuer,
+			"authorization_endpoint":                s.Issuer + "/authorize",
+			"token_endpoint":                        s.Issuer + "/token",
+			"jwks_uri":                              s.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{signing.AlgES256},
+			"code_challenge_methods_supported":      []string{"S256"},
+			"scopes_supported":                      []string{"openid", "profile", "email", "groups"},
+		})
+	case "/jwks":
+		writeJSON(w, http.StatusOK, s.keys.JWKS())
+	case "/authorize":
+		s.authorize(w, r)
+	case "/token":
+		s.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var signInPage = template.Must(template.New("signin").Parse(`<!DOCTYPE html>
+<html><head><title>Mock IdP</title></head><body>
+<h1>Mock identity provider</h1>
+<p>Sign in as:</p>
+<ul>{{range .Users}}<li><a href="{{$.Base}}&amp;login_hint={{.Username}}">{{.Username}}</a> ({{range $i, $g := .Groups}}{{if $i}}, {{end}}{{$g}}{{end}})</li>{{end}}</ul>
+</body></html>
+`))
+
+// authorize checks the request like a real provider and, once a user is picked with
+// login_hint, redirects back with a code.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	if query.Get("client_id") != s.ClientId || redirectURI == "" {
+		http.Error(w, "unknown client_id or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	back := func(params url.Values) {
+		params.Set("state", query.Get("state"))
+		http.Redirect(w, r, redirectURI+"?"+params.Encode(), http.StatusFound)
+	}
+	if query.Get("response_type") != "code" {
+		back(url.Values{"error": {"unsupported_response_type"}})
+		return
+	}
+	if !strings.Contains(" "+query.Get("scope")+" ", " openid ") {
+		back(url.Values{"error": {"invalid_scope"}, "error_description": {"openid is required"}})
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		back(url.Values{"error": {"invalid_request"}, "error_description": {"PKCE with S256 is required"}})
+		return
+	}
+
+	hint := query.Get("login_hint")
+	if hint == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		signInPage.Execute(w, map[string]interface{}{"Users": s.users, "Base": s.Issuer + "/authorize?" + query.Encode()})
+		return
+	}
+	var user *User
+	for i := range s.users {
+		if s.users[i].Username == hint {
+			user = &s.users[i]
+		}
+	}
+	if user == nil {
+		back(url.Values{"error": {"access_denied"}, "error_description": {"unknown user " + hint}})
+		return
+	}
+
+	code, err := oidc.NewSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.codes[code] = grant{user: *user, redirectURI: redirectURI, nonce: query.Get("nonce"), challenge: query.Get("code_challenge"), expiresAt: time.Now().Add(codeLifetime)}
+	s.mu.Unlock()
+	back(url.Values{"code": {code}})
+}
+
+// token exchanges a code once, checking the client, the redirect URI and the PKCE
+// code verifier, and answers with an ID token.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	clientId, secret, ok := r.BasicAuth()
+	if ok {
+		clientId, _ = url.QueryUnescape(clientId)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientId, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientId != s.ClientId || subtle.ConstantTimeCompare([]byte(secret), []byte(s.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	g, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	switch {
+	case !ok || time.Now().After(g.expiresAt):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown, used or expired code"})
+		return
+	case g.redirectURI != r.PostForm.Get("redirect_uri"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "redirect_uri does not match"})
+		return
+	case oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code_verifier does not match"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := s.keys.Sign(jwt.MapClaims{
+		"iss":                s.Issuer,
+		"sub":                g.user.Subject,
+		"aud":                s.ClientId,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              g.nonce,
+		"preferred_username": g.user.Username,
+		"email":              g.user.Email,
+		"groups":             g.user.Groups,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": idToken, "token_type": "Bearer", "expires_in": 300, "id_token": idToken})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
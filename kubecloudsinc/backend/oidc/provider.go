@@ -0,0 +1,268 @@
+package oidc
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/signing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ErrTokenRejected wraps the failures of the provider's answer rather than of reaching
+// it: a refused code exchange or an ID token that does not verify.
+var ErrTokenRejected = errors.New("ID token rejected")
+
+// keyRefreshInterval is the least time between two downloads of the provider's keys,
+// so tokens with unknown kids cannot make us hammer the provider.
+const keyRefreshInterval = time.Minute
+
+// Identity is what the ID token says about the user.
+type Identity struct {
+	Subject  string
+	Username string
+	Groups   []string
+}
+
+// metadata is the part of the discovery document the flow needs.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to the OpenID Connect provider of Config. The discovery document is
+// read on first use, so the service starts while the provider is unreachable, and
+// the provider's keys are downloaded again when a token names an unknown kid.
+type Provider struct {
+	Config *Config
+	Client *http.Client
+
+	mu          sync.Mutex
+	metadata    *metadata
+	keys        map[string]signing.Key
+	keysFetched time.Time
+}
+
+// NewProvider returns a Provider for config with a 10 second HTTP timeout.
+func NewProvider(config *Config) *Provider {
+	return &Provider{Config: config, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// AuthCodeURL returns the authorization endpoint URL that starts a sign-in, carrying
+// state, nonce and the S256 challenge of the code verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.Config.ClientId},
+		"redirect_uri":          {p.Config.RedirectURL},
+		"scope":                 {strings.Join(p.Config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return md.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades an authorization code and its code verifier for an ID token at the
+// token endpoint, and verifies the token against nonce.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.Config.RedirectURL},
+		"client_id":     {p.Config.ClientId},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientId), url.QueryEscape(p.Config.ClientSecret))
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid token response (status %d): %v", resp.StatusCode, err)
+	}
+	if body.Error != "" {
+		return nil, fmt.Errorf("%w: code exchange refused: %s %s", ErrTokenRejected, body.Error, body.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || body.IdToken == "" {
+		return nil, fmt.Errorf("token endpoint answered %d without an ID token", resp.StatusCode)
+	}
+	return p.Verify(ctx, body.IdToken, nonce)
+}
+
+// Verify checks an ID token: its RS256 or ES256 signature with one of the provider's
+// keys, exp, iat and nbf, that it was issued by the provider for our client ID, and
+// its nonce. It returns the user it describes.
+func (p *Provider) Verify(ctx context.Context, rawIdToken, nonce string) (*Identity, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := p.verificationKeys(ctx, rawIdToken)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{}
+	if _, err := signing.VerifyWith(keys, rawIdToken, claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenRejected, err)
+	}
+
+	if _, ok := claims["exp"].(float64); !ok {
+		return nil, fmt.Errorf("%w: exp is missing", ErrTokenRejected)
+	}
+	if iss, _ := claims["iss"].(string); iss != md.Issuer {
+		return nil, fmt.Errorf("%w: issued by %q", ErrTokenRejected, iss)
+	}
+	audiences := stringList(claims["aud"])
+	if !contains(audiences, p.Config.ClientId) {
+		return nil, fmt.Errorf("%w: not issued for client %s", ErrTokenRejected, p.Config.ClientId)
+	}
+	if azp, ok := claims["azp"].(string); (ok || len(audiences) > 1) && azp != p.Config.ClientId {
+		return nil, fmt.Errorf("%w: authorized party %q", ErrTokenRejected, azp)
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrTokenRejected)
+	}
+
+	identity := &Identity{Groups: stringList(claims[p.Config.GroupsClaim])}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Username, _ = claims[p.Config.UsernameClaim].(string)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: sub is missing", ErrTokenRejected)
+	}
+	return identity, nil
+}
+
+// discover reads the discovery document once, and again after a failure.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var md metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Config.Issuer, "/")+"/.well-known/openid-configuration", &md); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %v", err)
+	}
+	// The issuer of the document is the one ID tokens must carry
+	if md.Issuer != p.Config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery failed: the provider calls itself %q, not %q", md.Issuer, p.Config.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery failed: the document lacks an endpoint")
+	}
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// verificationKeys returns the provider's keys, downloading them when none are known
+// yet or when rawIdToken names a kid that is not among them.
+func (p *Provider) verificationKeys(ctx context.Context, rawIdToken string) (map[string]signing.Key, error) {
+	kid := headerKid(rawIdToken)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, known := p.keys[kid]; p.keys != nil && (known || kid == "" || time.Since(p.keysFetched) < keyRefreshInterval) {
+		return p.keys, nil
+	}
+
+	var jwks signing.JWKS
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to download the provider's keys: %v", err)
+	}
+	keys := make(map[string]signing.Key, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			log.Printf("Skipping key %s of the OIDC provider: %v", jwk.Kid, err)
+			continue
+		}
+		keys[key.Id] = key
+	}
+	p.keys, p.keysFetched = keys, time.Now()
+	return p.keys, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// headerKid reads the kid from the header of a JWT without verifying it.
+func headerKid(rawToken string) string {
+	token, _, err := new(jwt.Parser).ParseUnverified(rawToken, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+// stringList reads a claim that is a string or a list of strings, like aud.
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
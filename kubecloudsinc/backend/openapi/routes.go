@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// CheckRoutes compares the routes registered on r with the documented operations and
+// reports every route missing from the spec and every operation without a route.
+// Routes registered without methods, such as the pprof handlers, are not part of the
+// public API and are skipped.
+func (s *Spec) CheckRoutes(r *mux.Router) error {
+	registered := make(map[string]bool)
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		routeMethods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range routeMethods {
+			registered[method+" "+template] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var undocumented, unrouted []string
+	for key := range registered {
+		if _, ok := s.operations[key]; !ok {
+			undocumented = append(undocumented, key)
+		}
+	}
+	for key := range s.operations {
+		if !registered[key] {
+			unrouted = append(unrouted, key)
+		}
+	}
+	if len(undocumented) == 0 && len(unrouted) == 0 {
+		return nil
+	}
+
+	sort.Strings(undocumented)
+	sort.Strings(unrouted)
+	var problems []string
+	if len(undocumented) > 0 {
+		problems = append(problems, "routes missing from openapi.json: "+strings.Join(undocumented, ", "))
+	}
+	if len(unrouted) > 0 {
+		problems = append(problems, "documented operations without a route: "+strings.Join(unrouted, ", "))
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
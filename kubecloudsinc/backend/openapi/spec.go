@@ -0,0 +1,158 @@
+// Package openapi serves the OpenAPI 3.1 description of the REST API and checks
+// requests, responses and registered routes against it.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+//go:embed docs.html
+var docsHTML []byte
+
+const specURL = "openapi.json"
+
+// Spec holds the compiled request and response schemas of every operation, keyed by
+// "METHOD /path/{template}" exactly as the routes are registered with mux.
+type Spec struct {
+	operations map[string]*operation
+}
+
+type operation struct {
+	requestBody *jsonschema.Schema
+	// bodyOptional is set when requestBody is not required, so an empty body passes.
+	bodyOptional bool
+	// responses maps a status code to its JSON schema. Statuses documented without a
+	// JSON body map to nil.
+	responses map[string]*jsonschema.Schema
+}
+
+type document struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Responses map[string]json.RawMessage `json:"responses"`
+	} `json:"components"`
+}
+
+type operationDoc struct {
+	RequestBody *struct {
+		Required bool                       `json:"required"`
+		Content  map[string]json.RawMessage `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+var methods = []string{"get", "put", "post", "delete", "patch"}
+
+// Load parses the embedded document and compiles its schemas.
+func Load() (*Spec, error) {
+	var doc document
+	if err := json.Unmarshal(specJSON, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi.json: %v", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	if err := compiler.AddResource(specURL, bytes.NewReader(specJSON)); err != nil {
+		return nil, fmt.Errorf("failed to load openapi.json: %v", err)
+	}
+
+	spec := &Spec{operations: make(map[string]*operation)}
+	for path, item := range doc.Paths {
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var opDoc operationDoc
+			if err := json.Unmarshal(raw, &opDoc); err != nil {
+				return nil, fmt.Errorf("failed to parse %s %s: %v", method, path, err)
+			}
+
+			pointer := "/paths/" + escape(path) + "/" + method
+			op := &operation{responses: make(map[string]*jsonschema.Schema)}
+			if opDoc.RequestBody != nil {
+				if _, ok := opDoc.RequestBody.Content["application/json"]; ok {
+					schema, err := compiler.Compile(specURL + "#" + pointer + "/requestBody/content/application~1json/schema")
+					if err != nil {
+						return nil, fmt.Errorf("failed to compile request schema of %s %s: %v", method, path, err)
+					}
+					op.requestBody = schema
+					op.bodyOptional = !opDoc.RequestBody.Required
+				}
+			}
+			for status, response := range opDoc.Responses {
+				op.responses[status] = nil
+				responsePointer, ok := doc.jsonResponse(pointer+"/responses/"+status, response)
+				if !ok {
+					continue
+				}
+				schema, err := compiler.Compile(specURL + "#" + responsePointer + "/content/application~1json/schema")
+				if err != nil {
+					return nil, fmt.Errorf("failed to compile %s response schema of %s %s: %v", status, method, path, err)
+				}
+				op.responses[status] = schema
+			}
+			spec.operations[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+	return spec, nil
+}
+
+// MustLoad is Load for use at startup; the embedded document is part of the binary,
+// so a failure is a programming error.
+func MustLoad() *Spec {
+	spec, err := Load()
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+// jsonResponse follows a $ref to components/responses and returns the JSON pointer
+// of the response object, if it describes an application/json body.
+func (d *document) jsonResponse(pointer string, raw json.RawMessage) (string, bool) {
+	var response struct {
+		Ref     string                     `json:"$ref"`
+		Content map[string]json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return "", false
+	}
+	if response.Ref != "" {
+		name := strings.TrimPrefix(response.Ref, "#/components/responses/")
+		target, ok := d.Components.Responses[name]
+		if !ok {
+			return "", false
+		}
+		return d.jsonResponse("/components/responses/"+escape(name), target)
+	}
+	_, ok := response.Content["application/json"]
+	return pointer, ok
+}
+
+// escape encodes a path as a JSON pointer token.
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// ServeSpec serves the OpenAPI document.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
+
+// ServeDocs serves a Swagger UI page that renders /openapi.json.
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsHTML)
+}
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// maxBodySize bounds the request bodies read for validation.
+const maxBodySize = 1 << 20
+
+// operationFor finds the operation of the route mux matched for r.
+func (s *Spec) operationFor(r *http.Request) (*operation, string) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil, ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return nil, ""
+	}
+	key := r.Method + " " + template
+	return s.operations[key], key
+}
+
+// ValidateRequests is a mux middleware that rejects JSON request bodies that do not
+// match the operation's requestBody schema with a 400 listing every violation, so
+// misspelled or unknown field names fail loudly instead of being ignored.
+func (s *Spec) ValidateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, _ := s.operationFor(r)
+		if op == nil || op.requestBody == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "" && mediaType != "application/json" {
+			utils.SendErrorResponse(w, r, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q, expected application/json", mediaType), "unique_error_id", "UnsupportedMediaType", "ValidateRequest")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "ValidateRequest")
+			return
+		}
+		if len(body) > maxBodySize {
+			utils.SendErrorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", maxBodySize), "unique_error_id", "RequestBodyTooLarge", "ValidateRequest")
+			return
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			if op.bodyOptional {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				next.ServeHTTP(w, r)
+				return
+			}
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, errors.New("request body is required"), "unique_error_id", "InvalidRequestBody", "ValidateRequest")
+			return
+		}
+
+		if err := validate(op.requestBody, body); err != nil {
+			log.Printf("Request body rejected for %s %s: %v", r.Method, r.URL.Path, err)
+			utils.SendErrorResponse(w, r, http.StatusBadRequest, err, "unique_error_id", "InvalidRequestBody", "ValidateRequest")
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ValidateResponses is a mux middleware for development and staging that checks
+// every JSON response against the documented schema for its status code and logs a
+// drift message when the handler returns an undocumented status or shape. Responses
+// are passed through unchanged.
+func (s *Spec) ValidateResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, key := s.operationFor(r)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if err := op.checkResponse(rec.status, rec.json, rec.body.Bytes()); err != nil {
+			log.Printf("OpenAPI drift: %s %v", key, err)
+		}
+	})
+}
+
+// CheckResponse reports how a response of the operation registered as method and path
+// template drifts from the spec: an undocumented status, a JSON body where none is
+// documented, or a JSON body that does not match the schema. Bodies of other media
+// types are not checked.
+func (s *Spec) CheckResponse(method, template string, status int, contentType string, body []byte) error {
+	op, ok := s.operations[method+" "+template]
+	if !ok {
+		return fmt.Errorf("%s %s is not documented", method, template)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return op.checkResponse(status, mediaType == "application/json", body)
+}
+
+func (op *operation) checkResponse(status int, isJSON bool, body []byte) error {
+	schema, documented := op.responses[strconv.Itoa(status)]
+	if !documented {
+		schema, documented = op.responses["default"]
+	}
+	switch {
+	case !documented:
+		return fmt.Errorf("returned undocumented status %d", status)
+	case isJSON && schema == nil:
+		return fmt.Errorf("returned a JSON body for status %d, which documents none", status)
+	case isJSON:
+		if err := validate(schema, body); err != nil {
+			return fmt.Errorf("status %d response does not match the spec: %v", status, err)
+		}
+	}
+	return nil
+}
+
+// responseRecorder copies JSON response bodies aside while writing them through.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	json        bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.wroteHeader = true
+		rec.status = status
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		rec.json = mediaType == "application/json"
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if rec.json {
+		rec.body.Write(b)
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming handlers such as the event feed working behind the recorder.
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// validate decodes body and checks it against schema. Every violation is reported as
+// "<location>: <message>" so callers see all problems at once.
+func validate(schema *jsonschema.Schema, body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	err := schema.Validate(value)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	var problems []string
+	for _, e := range validationErr.BasicOutput().Errors {
+		// Skip the wrapper entries that only say "doesn't validate with ...".
+		if e.Error == "" || strings.HasPrefix(e.Error, "doesn't validate with") {
+			continue
+		}
+		location := e.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		problems = append(problems, location+": "+e.Error)
+	}
+	if len(problems) == 0 {
+		return err
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
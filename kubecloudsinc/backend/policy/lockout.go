@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed lockout-policy.yaml
+var defaultLockoutPolicy []byte
+
+// LockoutPolicy limits failed logins.
+type LockoutPolicy struct {
+	UsernameMaxFailures int           `yaml:"usernameMaxFailures"`
+	IPMaxFailures       int           `yaml:"ipMaxFailures"`
+	Window              time.Duration `yaml:"window"`
+	LockoutDuration     time.Duration `yaml:"lockoutDuration"`
+	DelayBase           time.Duration `yaml:"delayBase"`
+	MaxDelay            time.Duration `yaml:"maxDelay"`
+}
+
+// CurrentLockout is the lockout policy of /v2/login. It starts as the built-in policy
+// and is replaced at startup by LoadLockout.
+var CurrentLockout = MustParseLockout(defaultLockoutPolicy)
+
+// LoadLockout reads the lockout policy file at path, or the built-in policy when path
+// is empty, and makes it CurrentLockout.
+func LoadLockout(path string) error {
+	data := defaultLockoutPolicy
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read lockout policy: %v", err)
+		}
+	}
+	p, err := ParseLockout(data)
+	if err != nil {
+		return fmt.Errorf("invalid lockout policy %s: %v", path, err)
+	}
+	CurrentLockout = p
+	return nil
+}
+
+// ParseLockout decodes a lockout policy, rejecting unknown keys and limits that would
+// not protect anything.
+func ParseLockout(data []byte) (*LockoutPolicy, error) {
+	var p LockoutPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.UsernameMaxFailures < 1 || p.IPMaxFailures < 1 {
+		return nil, errors.New("usernameMaxFailures and ipMaxFailures must be at least 1")
+	}
+	if p.Window <= 0 || p.LockoutDuration <= 0 {
+		return nil, errors.New("window and lockoutDuration must be positive")
+	}
+	if p.DelayBase < 0 || p.MaxDelay < p.DelayBase {
+		return nil, errors.New("delayBase must not be negative nor above maxDelay")
+	}
+	return &p, nil
+}
+
+// MustParseLockout is ParseLockout for the built-in policy, which is part of the
+// binary.
+func MustParseLockout(data []byte) *LockoutPolicy {
+	p, err := ParseLockout(data)
+	if err != nil {
+		panic(fmt.Sprintf("policy: built-in lockout policy: %v", err))
+	}
+	return p
+}
+
+// Delay is how long to hold back the answer to a failed login after failures
+// failures: DelayBase, doubled for each failure after the first, up to MaxDelay.
+func (p *LockoutPolicy) Delay(failures int) time.Duration {
+	delay := p.DelayBase
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed mfa-policy.yaml
+var defaultMFAPolicy []byte
+
+// MFAPolicy decides who must use multi-factor authentication and how codes are
+// checked.
+type MFAPolicy struct {
+	Issuer            string        `yaml:"issuer"`
+	RequiredRoles     []string      `yaml:"requiredRoles"`
+	RecoveryCodes     int           `yaml:"recoveryCodes"`
+	SkewSteps         int           `yaml:"skewSteps"`
+	ChallengeLifetime time.Duration `yaml:"challengeLifetime"`
+}
+
+// CurrentMFA is the MFA policy. It starts as the built-in policy and is replaced at
+// startup by LoadMFA.
+var CurrentMFA = MustParseMFA(defaultMFAPolicy)
+
+// LoadMFA reads the MFA policy file at path, or the built-in policy when path is
+// empty, and makes it CurrentMFA. The required roles must be granted in
+// CurrentGrants, so LoadGrants runs first.
+func LoadMFA(path string) error {
+	data := defaultMFAPolicy
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read MFA policy: %v", err)
+		}
+	}
+	p, err := ParseMFA(data)
+	if err != nil {
+		return fmt.Errorf("invalid MFA policy %s: %v", path, err)
+	}
+	for _, role := range p.RequiredRoles {
+		if !CurrentGrants.HasRole(role) {
+			return fmt.Errorf("invalid MFA policy %s: unknown role %q", path, role)
+		}
+	}
+	CurrentMFA = p
+	return nil
+}
+
+// ParseMFA decodes an MFA policy, rejecting unknown keys and settings that would
+// weaken the codes.
+func ParseMFA(data []byte) (*MFAPolicy, error) {
+	var p MFAPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Issuer == "" {
+		return nil, errors.New("issuer is required")
+	}
+	if p.RecoveryCodes < 1 || p.RecoveryCodes > 20 {
+		return nil, errors.New("recoveryCodes must be between 1 and 20")
+	}
+	if p.SkewSteps < 0 || p.SkewSteps > 2 {
+		return nil, errors.New("skewSteps must be between 0 and 2")
+	}
+	if p.ChallengeLifetime < time.Minute || p.ChallengeLifetime > 15*time.Minute {
+		return nil, errors.New("challengeLifetime must be between 1m and 15m")
+	}
+	return &p, nil
+}
+
+// MustParseMFA is ParseMFA for the built-in policy, which is part of the binary.
+func MustParseMFA(data []byte) *MFAPolicy {
+	p, err := ParseMFA(data)
+	if err != nil {
+		panic(fmt.Sprintf("policy: built-in MFA policy: %v", err))
+	}
+	return p
+}
+
+// Requires reports whether users of role must use MFA.
+func (p *MFAPolicy) Requires(role string) bool {
+	return contains(p.RequiredRoles, role)
+}
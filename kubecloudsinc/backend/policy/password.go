@@ -0,0 +1,122 @@
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaxPasswordBytes is the longest password bcrypt hashes. It only reads the first 72
+// bytes, so longer passwords are refused rather than silently truncated.
+const MaxPasswordBytes = 72
+
+//go:embed password-policy.yaml
+var defaultPasswordPolicy []byte
+
+// PasswordPolicy is what new passwords must satisfy.
+type PasswordPolicy struct {
+	MinLength        int  `yaml:"minLength"`
+	RequireUppercase bool `yaml:"requireUppercase"`
+	RequireLowercase bool `yaml:"requireLowercase"`
+	RequireDigit     bool `yaml:"requireDigit"`
+	RequireSymbol    bool `yaml:"requireSymbol"`
+	RejectUsername   bool `yaml:"rejectUsername"`
+}
+
+// CurrentPassword is the password policy for new passwords. It starts as the built-in
+// policy and is replaced at startup by LoadPassword.
+var CurrentPassword = MustParsePassword(defaultPasswordPolicy)
+
+// LoadPassword reads the password policy file at path, or the built-in policy when
+// path is empty, and makes it CurrentPassword.
+func LoadPassword(path string) error {
+	data := defaultPasswordPolicy
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read password policy: %v", err)
+		}
+	}
+	p, err := ParsePassword(data)
+	if err != nil {
+		return fmt.Errorf("invalid password policy %s: %v", path, err)
+	}
+	CurrentPassword = p
+	return nil
+}
+
+// ParsePassword decodes a password policy, rejecting unknown keys and lengths bcrypt
+// cannot hash.
+func ParsePassword(data []byte) (*PasswordPolicy, error) {
+	var p PasswordPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.MinLength < 1 || p.MinLength > MaxPasswordBytes {
+		return nil, fmt.Errorf("minLength must be between 1 and %d", MaxPasswordBytes)
+	}
+	return &p, nil
+}
+
+// MustParsePassword is ParsePassword for the built-in policy, which is part of the
+// binary.
+func MustParsePassword(data []byte) *PasswordPolicy {
+	p, err := ParsePassword(data)
+	if err != nil {
+		panic(fmt.Sprintf("policy: built-in password policy: %v", err))
+	}
+	return p
+}
+
+// Check returns an error listing every rule password breaks as the new password of
+// username.
+func (p *PasswordPolicy) Check(username, password string) error {
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case !unicode.IsLetter(c):
+			symbol = true
+		}
+	}
+
+	var problems []string
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("be at least %d characters long", p.MinLength))
+	}
+	if len(password) > MaxPasswordBytes {
+		problems = append(problems, fmt.Sprintf("be at most %d bytes long", MaxPasswordBytes))
+	}
+	if p.RequireUppercase && !upper {
+		problems = append(problems, "contain an upper case letter")
+	}
+	if p.RequireLowercase && !lower {
+		problems = append(problems, "contain a lower case letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "contain a symbol")
+	}
+	if p.RejectUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		problems = append(problems, "not contain the username")
+	}
+	if len(problems) > 0 {
+		return errors.New("password must " + strings.Join(problems, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Permissions that routes and gRPC methods require.
+const (
+	EmployeeRead        = "employee:read"
+	EmployeeWrite       = "employee:write"
+	EmployeeDelete      = "employee:delete"
+	EmployeeHistory     = "employee:history"
+	ChangeRequestRead   = "change-request:read"
+	ChangeRequestReview = "change-request:review"
+	AuditRead           = "audit:read"
+	WebhookManage       = "webhook:manage"
+	UserManage          = "user:manage"
+	APIKeyManage        = "apikey:manage"
+)
+
+var permissions = map[string]bool{
+	EmployeeRead:        true,
+	EmployeeWrite:       true,
+	EmployeeDelete:      true,
+	EmployeeHistory:     true,
+	ChangeRequestRead:   true,
+	ChangeRequestReview: true,
+	AuditRead:           true,
+	WebhookManage:       true,
+	UserManage:          true,
+	APIKeyManage:        true,
+}
+
+// ScopeReports limits a role to the caller's own employee record and their reporting
+// tree.
+const ScopeReports = "reports"
+
+//go:embed roles.yaml
+var defaultGrants []byte
+
+// Grants maps role names to the permissions they hold and, for scoped roles, to the
+// employees those permissions reach.
+type Grants struct {
+	Roles  map[string][]string `yaml:"roles"`
+	Scopes map[string]string   `yaml:"scopes"`
+}
+
+// CurrentGrants is the role to permission table used for authorization. It starts as
+// the built-in table and is replaced at startup by LoadGrants.
+var CurrentGrants = MustParseGrants(defaultGrants)
+
+// LoadGrants reads the grants file at path, or the built-in grants when path is
+// empty, and makes it CurrentGrants.
+func LoadGrants(path string) error {
+	data := defaultGrants
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read role grants: %v", err)
+		}
+	}
+	g, err := ParseGrants(data)
+	if err != nil {
+		return fmt.Errorf("invalid role grants %s: %v", path, err)
+	}
+	CurrentGrants = g
+	return nil
+}
+
+// ParseGrants decodes a grants table, rejecting unknown keys, permissions and scopes.
+func ParseGrants(data []byte) (*Grants, error) {
+	var g Grants
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&g); err != nil {
+		return nil, err
+	}
+	for role, granted := range g.Roles {
+		for _, permission := range granted {
+			if !IsPermission(permission) {
+				return nil, fmt.Errorf("role %s: unknown permission %q", role, permission)
+			}
+		}
+	}
+	for role, scope := range g.Scopes {
+		if _, ok := g.Roles[role]; !ok {
+			return nil, fmt.Errorf("scopes: unknown role %s", role)
+		}
+		if scope != ScopeReports {
+			return nil, fmt.Errorf("role %s: unknown scope %q", role, scope)
+		}
+	}
+	return &g, nil
+}
+
+// MustParseGrants is ParseGrants for the built-in table, which is part of the binary.
+func MustParseGrants(data []byte) *Grants {
+	g, err := ParseGrants(data)
+	if err != nil {
+		panic(fmt.Sprintf("policy: built-in role grants: %v", err))
+	}
+	return g
+}
+
+// IsPermission reports whether permission is one the service checks.
+func IsPermission(permission string) bool {
+	return permissions[permission]
+}
+
+// Has reports whether role holds any of the given permissions.
+func (g *Grants) Has(role string, anyOf ...string) bool {
+	for _, granted := range g.Roles[role] {
+		for _, permission := range anyOf {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasRole reports whether role is listed in the grants.
+func (g *Grants) HasRole(role string) bool {
+	_, ok := g.Roles[role]
+	return ok
+}
+
+// Scope returns the scope role is limited to, or "" when it reaches every employee.
+func (g *Grants) Scope(role string) string {
+	return g.Scopes[role]
+}
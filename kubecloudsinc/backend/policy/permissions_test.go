@@ -0,0 +1,57 @@
+package policy
+
+import "testing"
+
+func TestBuiltInGrants(t *testing.T) {
+	g := MustParseGrants(defaultGrants)
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{"admin", UserManage, true},
+		{"admin", ChangeRequestReview, true},
+		{"editor", EmployeeWrite, true},
+		{"editor", ChangeRequestReview, false},
+		{"editor", EmployeeDelete, false},
+		{"manager", EmployeeHistory, true},
+		{"viewer", EmployeeRead, true},
+		{"viewer", EmployeeWrite, false},
+		{"auditor", EmployeeRead, false},
+	}
+	for _, tt := range tests {
+		if got := g.Has(tt.role, tt.permission); got != tt.want {
+			t.Errorf("Has(%s, %s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+	if g.HasRole("auditor") {
+		t.Error("the unknown role auditor is listed")
+	}
+	if got := g.Scope("manager"); got != ScopeReports {
+		t.Errorf("Scope(manager) = %q, want %q", got, ScopeReports)
+	}
+	if got := g.Scope("admin"); got != "" {
+		t.Errorf("Scope(admin) = %q, want none", got)
+	}
+}
+
+func TestParseGrants(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"new role", "roles:\n  auditor: [audit:read, employee:read]\n", false},
+		{"unknown permission", "roles:\n  auditor: [audit:write]\n", true},
+		{"scope of an unknown role", "roles:\n  auditor: [audit:read]\nscopes:\n  manager: reports\n", true},
+		{"unknown scope", "roles:\n  manager: [employee:read]\nscopes:\n  manager: department\n", true},
+		{"unknown key", "roles:\n  manager: [employee:read]\nscope:\n  manager: reports\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseGrants([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGrants(%q) error = %v, want error %v", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
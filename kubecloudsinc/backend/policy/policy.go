@@ -0,0 +1,191 @@
+// Package policy decides which employee fields a role may write. The rules live in a
+// YAML (or JSON) file so they can be reviewed without reading Go code; the file in this
+// directory is built in and used unless WRITE_POLICY_FILE names another one.
+package policy
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operations a policy grants per role.
+const (
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+)
+
+// Reasons a write is refused.
+const (
+	ReasonNotWritable      = "not_writable"
+	ReasonRequiresApproval = "requires_approval"
+	ReasonOutOfScope       = "out_of_scope"
+)
+
+//go:embed write-policy.yaml
+var defaultPolicy []byte
+
+// RolePolicy lists what one role may write.
+type RolePolicy struct {
+	Create             []string `yaml:"create"`
+	Update             []string `yaml:"update"`
+	UpdateWithApproval []string `yaml:"updateWithApproval"`
+	Delete             bool     `yaml:"delete"`
+}
+
+// Policy maps role names to their write permissions.
+type Policy struct {
+	Roles map[string]RolePolicy `yaml:"roles"`
+}
+
+// Current is the policy enforced by the service package. It starts as the built-in
+// policy and is replaced at startup by Load.
+var Current = MustParse(defaultPolicy)
+
+// employeeFields lists the JSON names of schema.Employee in declaration order.
+var employeeFields = func() []string {
+	t := reflect.TypeOf(schema.Employee{})
+	fields := make([]string, t.NumField())
+	for i := range fields {
+		fields[i] = strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return fields
+}()
+
+// Load reads the policy file at path, or the built-in policy when path is empty, and
+// makes it Current.
+func Load(path string) error {
+	data := defaultPolicy
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read write policy: %v", err)
+		}
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid write policy %s: %v", path, err)
+	}
+	Current = p
+	return nil
+}
+
+// Parse decodes a policy. Unknown keys and field names are rejected so a typo cannot
+// silently grant or withhold access.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+	for role, rp := range p.Roles {
+		for op, fields := range map[string][]string{Create: rp.Create, Update: rp.Update, "updateWithApproval": rp.UpdateWithApproval} {
+			for _, field := range fields {
+				if field != "*" && !isField(field) {
+					return nil, fmt.Errorf("role %s: unknown field %q in %s", role, field, op)
+				}
+			}
+		}
+	}
+	return &p, nil
+}
+
+// MustParse is Parse for the built-in policy, which is part of the binary.
+func MustParse(data []byte) *Policy {
+	p, err := Parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("policy: built-in write policy: %v", err))
+	}
+	return p
+}
+
+// Check returns every field of emp that role may not write in operation, in field
+// order. Deletes have no fields and emp is nil for them.
+func (p *Policy) Check(role, operation string, emp *schema.Employee) []schema.FieldViolation {
+	var fields []string
+	if emp != nil {
+		fields = SetFields(emp)
+	}
+	return p.CheckFields(role, operation, fields)
+}
+
+// CheckFields returns every one of fields that role may not write in operation. For
+// updates, fields the role may only change with approval are reported with
+// ReasonRequiresApproval. A refused delete is a single violation without a field.
+func (p *Policy) CheckFields(role, operation string, fields []string) []schema.FieldViolation {
+	rp, known := p.Roles[role]
+	if operation == Delete {
+		if rp.Delete {
+			return nil
+		}
+		return []schema.FieldViolation{{Operation: operation, Role: role, Reason: ReasonNotWritable}}
+	}
+
+	var allowed []string
+	switch operation {
+	case Create:
+		allowed = rp.Create
+	case Update:
+		allowed = rp.Update
+	}
+
+	var violations []schema.FieldViolation
+	for _, field := range fields {
+		if operation == Update && field == "employeeId" {
+			continue
+		}
+		if known && contains(allowed, field) {
+			continue
+		}
+		reason := ReasonNotWritable
+		if operation == Update && known && contains(rp.UpdateWithApproval, field) {
+			reason = ReasonRequiresApproval
+		}
+		violations = append(violations, schema.FieldViolation{Field: field, Operation: operation, Role: role, Reason: reason})
+	}
+	return violations
+}
+
+// RoleNames lists the roles the policy grants anything to, sorted.
+func (p *Policy) RoleNames() []string {
+	roles := make([]string, 0, len(p.Roles))
+	for role := range p.Roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// SetFields returns the JSON names of the fields of emp that are not nil.
+func SetFields(emp *schema.Employee) []string {
+	var fields []string
+	v := reflect.ValueOf(emp).Elem()
+	for i, field := range employeeFields {
+		if !v.Field(i).IsNil() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func isField(field string) bool {
+	return contains(employeeFields, field)
+}
+
+func contains(list []string, field string) bool {
+	for _, f := range list {
+		if f == field || f == "*" {
+			return true
+		}
+	}
+	return false
+}
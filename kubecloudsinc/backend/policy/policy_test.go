@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"reflect"
+	"testing"
+)
+
+func TestCheckFields(t *testing.T) {
+	p := MustParse(defaultPolicy)
+	tests := []struct {
+		name      string
+		role      string
+		operation string
+		fields    []string
+		want      []schema.FieldViolation
+	}{
+		{"admin updates everything", "admin", Update, []string{"salary", "departmentId", "email"}, nil},
+		{"editor updates contact fields", "editor", Update, []string{"firstName", "email", "jobId"}, nil},
+		{"employeeId is ignored on update", "editor", Update, []string{"employeeId"}, nil},
+		{"editor needs approval for salary", "editor", Update, []string{"email", "salary", "commissionPct"}, []schema.FieldViolation{
+			{Field: "salary", Operation: Update, Role: "editor", Reason: ReasonRequiresApproval},
+			{Field: "commissionPct", Operation: Update, Role: "editor", Reason: ReasonRequiresApproval},
+		}},
+		{"manager may not update salary", "manager", Update, []string{"departmentId", "salary"}, []schema.FieldViolation{
+			{Field: "salary", Operation: Update, Role: "manager", Reason: ReasonNotWritable},
+		}},
+		{"manager may not create", "manager", Create, []string{"firstName"}, []schema.FieldViolation{
+			{Field: "firstName", Operation: Create, Role: "manager", Reason: ReasonNotWritable},
+		}},
+		{"unknown role writes nothing", "auditor", Update, []string{"salary"}, []schema.FieldViolation{
+			{Field: "salary", Operation: Update, Role: "auditor", Reason: ReasonNotWritable},
+		}},
+		{"admin deletes", "admin", Delete, nil, nil},
+		{"editor may not delete", "editor", Delete, nil, []schema.FieldViolation{
+			{Operation: Delete, Role: "editor", Reason: ReasonNotWritable},
+		}},
+		{"unknown role may not delete", "auditor", Delete, nil, []schema.FieldViolation{
+			{Operation: Delete, Role: "auditor", Reason: ReasonNotWritable},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.CheckFields(tt.role, tt.operation, tt.fields); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("CheckFields(%s, %s, %v) = %+v, want %+v", tt.role, tt.operation, tt.fields, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckJudgesSetFields(t *testing.T) {
+	p := MustParse(defaultPolicy)
+	email, salary := "jane@example.com", 9000.0
+	emp := &schema.Employee{Email: &email, Salary: &salary}
+
+	want := []schema.FieldViolation{{Field: "salary", Operation: Update, Role: "editor", Reason: ReasonRequiresApproval}}
+	if got := p.Check("editor", Update, emp); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Check(editor) = %+v, want %+v", got, want)
+	}
+	if got := p.Check("admin", Update, emp); got != nil {
+		t.Fatalf("Check(admin) = %+v, want none", got)
+	}
+	if got := p.Check("editor", Delete, nil); len(got) != 1 || got[0].Field != "" {
+		t.Fatalf("Check(editor, delete) = %+v, want one violation without a field", got)
+	}
+}
+
+func TestParseRejectsUnknownFieldsAndKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown field", "roles:\n  editor:\n    update: [salry]\n"},
+		{"unknown approval field", "roles:\n  editor:\n    updateWithApproval: [bonus]\n"},
+		{"unknown key", "roles:\n  editor:\n    upsert: [salary]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); err == nil {
+				t.Fatalf("Parse accepted %q", tt.data)
+			}
+		})
+	}
+	if _, err := Parse([]byte("roles:\n  editor:\n    update: [\"*\"]\n")); err != nil {
+		t.Fatalf("Parse refused the wildcard: %v", err)
+	}
+}
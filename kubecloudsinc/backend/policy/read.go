@@ -0,0 +1,318 @@
+package policy
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Read masks.
+const (
+	MaskOmit   = "omit"
+	MaskRedact = "redact"
+	MaskBand   = "band"
+)
+
+//go:embed read-policy.yaml
+var defaultReadPolicy []byte
+
+// FieldMask says how one field is hidden from a role. Width is the size of a band.
+type FieldMask struct {
+	Mask  string  `yaml:"mask"`
+	Width float64 `yaml:"width"`
+}
+
+// ReadPolicy maps role names to the fields they may not read as stored.
+type ReadPolicy struct {
+	Roles map[string]map[string]FieldMask `yaml:"roles"`
+}
+
+// CurrentRead is the read policy applied to responses. It starts as the built-in
+// policy and is replaced at startup by LoadRead.
+var CurrentRead = MustParseRead(defaultReadPolicy)
+
+// LoadRead reads the read policy file at path, or the built-in policy when path is
+// empty, and makes it CurrentRead.
+func LoadRead(path string) error {
+	data := defaultReadPolicy
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read read policy: %v", err)
+		}
+	}
+	p, err := ParseRead(data)
+	if err != nil {
+		return fmt.Errorf("invalid read policy %s: %v", path, err)
+	}
+	CurrentRead = p
+	return nil
+}
+
+// ParseRead decodes a read policy, rejecting unknown keys, fields and masks.
+func ParseRead(data []byte) (*ReadPolicy, error) {
+	var p ReadPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+	for role, fields := range p.Roles {
+		for field, mask := range fields {
+			if !isField(field) {
+				return nil, fmt.Errorf("role %s: unknown field %q", role, field)
+			}
+			switch mask.Mask {
+			case MaskOmit, MaskRedact:
+			case MaskBand:
+				if employeeFieldType(field).Kind() != reflect.Float64 {
+					return nil, fmt.Errorf("role %s: %s cannot be banded", role, field)
+				}
+				if mask.Width <= 0 {
+					return nil, fmt.Errorf("role %s: band width for %s must be positive", role, field)
+				}
+			default:
+				return nil, fmt.Errorf("role %s: unknown mask %q for %s", role, mask.Mask, field)
+			}
+		}
+	}
+	return &p, nil
+}
+
+// MustParseRead is ParseRead for the built-in policy, which is part of the binary.
+func MustParseRead(data []byte) *ReadPolicy {
+	p, err := ParseRead(data)
+	if err != nil {
+		panic(fmt.Sprintf("policy: built-in read policy: %v", err))
+	}
+	return p
+}
+
+// Masks reports whether role has any field masked, so unmasked responses can skip
+// the work.
+func (p *ReadPolicy) Masks(role string) bool {
+	return len(p.Roles[role]) > 0
+}
+
+// Mask reports how role sees a field holding value: visible is false when the value
+// must not be returned, and band is set when a range is returned instead.
+func (p *ReadPolicy) Mask(role, field string, value *float64) (visible bool, band *string) {
+	mask, ok := p.Roles[role][field]
+	if !ok {
+		return true, nil
+	}
+	if mask.Mask == MaskBand && value != nil {
+		b := bandOf(*value, mask.Width)
+		return false, &b
+	}
+	return false, nil
+}
+
+func bandOf(value, width float64) string {
+	low := math.Floor(value/width) * width
+	return strconv.FormatFloat(low, 'f', -1, 64) + "-" + strconv.FormatFloat(low+width, 'f', -1, 64)
+}
+
+// MaskJSON applies role's masks to a JSON document, keeping key order. Every object
+// key naming a masked field is masked, and so are the before and after values of a
+// {"field": ..., "before": ..., "after": ...} change of a masked field.
+func (p *ReadPolicy) MaskJSON(role string, data []byte) ([]byte, error) {
+	masks := p.Roles[role]
+	if len(masks) == 0 {
+		return data, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	value, err := readValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	var buf bytes.Buffer
+	if err := writeValue(&buf, maskValue(masks, value)); err != nil {
+		return nil, err
+	}
+	// Keep the trailing newline json.Encoder writes
+	if bytes.HasSuffix(data, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// object is a decoded JSON object that keeps its key order.
+type object struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *object) get(key string) (interface{}, bool) {
+	for i, k := range o.keys {
+		if k == key {
+			return o.values[i], true
+		}
+	}
+	return nil, false
+}
+
+func readValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		o := &object{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			o.keys = append(o.keys, key.(string))
+			o.values = append(o.values, value)
+		}
+		_, err := decoder.Token()
+		return o, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := readValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+func writeValue(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case *object:
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			buf.Write(k)
+			buf.WriteByte(':')
+			if err := writeValue(buf, v.values[i]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeValue(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return nil
+}
+
+// omitted marks a list item that is left out.
+var omitted = &object{}
+
+func maskValue(masks map[string]FieldMask, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		list := v[:0]
+		for _, item := range v {
+			if item = maskValue(masks, item); item != omitted {
+				list = append(list, item)
+			}
+		}
+		return list
+	case *object:
+		// A field change carries the field's values under before and after; changes
+		// of omitted fields are left out of their list
+		if field, ok := v.get("field"); ok {
+			if name, ok := field.(string); ok {
+				if mask, ok := masks[name]; ok {
+					if mask.Mask == MaskOmit {
+						return omitted
+					}
+					return maskKeys(v, map[string]FieldMask{"before": mask, "after": mask}, false)
+				}
+			}
+		}
+		for i := range v.values {
+			v.values[i] = maskValue(masks, v.values[i])
+		}
+		return maskKeys(v, masks, true)
+	}
+	return value
+}
+
+// maskKeys masks the values of o's keys listed in masks. With named bands a banded
+// key is replaced by <key>Band; otherwise the band replaces the value.
+func maskKeys(o *object, masks map[string]FieldMask, namedBands bool) *object {
+	masked := &object{}
+	for i, key := range o.keys {
+		mask, ok := masks[key]
+		if !ok {
+			masked.keys = append(masked.keys, key)
+			masked.values = append(masked.values, o.values[i])
+			continue
+		}
+		switch mask.Mask {
+		case MaskRedact:
+			masked.keys = append(masked.keys, key)
+			masked.values = append(masked.values, nil)
+		case MaskBand:
+			var band interface{}
+			if n, ok := o.values[i].(json.Number); ok {
+				if f, err := n.Float64(); err == nil {
+					band = bandOf(f, mask.Width)
+				}
+			}
+			if namedBands {
+				key += "Band"
+			}
+			masked.keys = append(masked.keys, key)
+			masked.values = append(masked.values, band)
+		}
+	}
+	return masked
+}
+
+// employeeFieldType returns the value type of the schema.Employee field with the given
+// JSON name.
+func employeeFieldType(field string) reflect.Type {
+	t := reflect.TypeOf(schema.Employee{})
+	for i, f := range employeeFields {
+		if f == field {
+			return t.Field(i).Type.Elem()
+		}
+	}
+	return nil
+}
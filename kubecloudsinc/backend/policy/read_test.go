@@ -0,0 +1,73 @@
+package policy
+
+import "testing"
+
+func TestMaskJSON(t *testing.T) {
+	p := MustParseRead(defaultReadPolicy)
+	tests := []struct {
+		name string
+		role string
+		in   string
+		want string
+	}{
+		{"viewer sees a salary band and no commission", "viewer",
+			`{"employeeId":101,"salary":12000,"commissionPct":0.2,"email":"a@example.com"}`,
+			`{"employeeId":101,"salaryBand":"10000-15000","email":"a@example.com"}`},
+		{"bands start at the lower bound", "viewer", `{"salary":15000}`, `{"salaryBand":"15000-20000"}`},
+		{"a null salary has no band", "viewer", `{"salary":null}`, `{"salaryBand":null}`},
+		{"lists and nested records are masked", "viewer",
+			`[{"salary":4000,"jobs":[{"salary":6000,"commissionPct":0.1}]}]`,
+			`[{"salaryBand":"0-5000","jobs":[{"salaryBand":"5000-10000"}]}]`},
+		{"changes of masked fields", "viewer",
+			`{"changes":[{"field":"salary","before":9000,"after":11000},{"field":"commissionPct","before":0.1,"after":0.2},{"field":"email","before":"a","after":"b"}]}`,
+			`{"changes":[{"field":"salary","before":"5000-10000","after":"10000-15000"},{"field":"email","before":"a","after":"b"}]}`},
+		{"admin sees everything", "admin",
+			`{"salary":12000,"commissionPct":0.2}`,
+			`{"salary":12000,"commissionPct":0.2}`},
+		{"unknown role sees everything", "auditor", `{"salary":12000}`, `{"salary":12000}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.MaskJSON(tt.role, []byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("MaskJSON(%s, %s) = %s, want %s", tt.role, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskJSONRedacts(t *testing.T) {
+	p, err := ParseRead([]byte("roles:\n  auditor:\n    email: {mask: redact}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.MaskJSON("auditor", []byte("{\"email\":\"a@example.com\",\"phone\":\"515.123.4567\"}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"email\":null,\"phone\":\"515.123.4567\"}\n"; string(got) != want {
+		t.Fatalf("MaskJSON = %q, want %q", got, want)
+	}
+}
+
+func TestParseReadRejectsInvalidMasks(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown field", "roles:\n  viewer:\n    bonus: {mask: omit}\n"},
+		{"unknown mask", "roles:\n  viewer:\n    salary: {mask: hide}\n"},
+		{"band of a text field", "roles:\n  viewer:\n    email: {mask: band, width: 10}\n"},
+		{"band without a width", "roles:\n  viewer:\n    salary: {mask: band}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRead([]byte(tt.data)); err == nil {
+				t.Fatalf("ParseRead accepted %q", tt.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package schema
+
+import "time"
+
+// APIKey is a key of a service client. It acts with Role, but only for the
+// permissions in Scopes, from AllowedIps when any are set, and at most RateLimit
+// requests a minute. The key itself is only shown once, when it is created.
+type APIKey struct {
+	KeyId      int        `json:"keyId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Role       string     `json:"role"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIps []string   `json:"allowedIps"`
+	RateLimit  int        `json:"rateLimit"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	LastUsedIp *string    `json:"lastUsedIp"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	KeyHash    string     `json:"-"`
+}
+
+// NewAPIKey is the body of an API key creation. A missing rateLimit takes the
+// default, a missing expiresAt never expires.
+type NewAPIKey struct {
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIps []string   `json:"allowedIps"`
+	RateLimit  *int       `json:"rateLimit"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+// CreatedAPIKey is the response of an API key creation, the only one holding Key.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,27 @@
+package schema
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one employee mutation. Action is the change event type, for
+// example employee.updated, and Changes lists every field whose value differs
+// between the record before and after the mutation.
+type AuditEntry struct {
+	AuditId    int64         `json:"auditId"`
+	OccurredAt time.Time     `json:"occurredAt"`
+	Actor      Actor         `json:"actor"`
+	Endpoint   string        `json:"endpoint"`
+	Action     string        `json:"action"`
+	EmployeeId int           `json:"employeeId"`
+	Changes    []FieldChange `json:"changes"`
+}
+
+// FieldChange holds a field's value before and after a mutation; null means the field
+// was empty or the employee did not exist.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
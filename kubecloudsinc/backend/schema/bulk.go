@@ -0,0 +1,46 @@
+package schema
+
+// Per-employee outcomes of a bulk update. Valid means the change succeeded inside the
+// transaction but was rolled back, either because of dryRun or because another
+// employee in the same request failed.
+const (
+	BulkUpdated = "updated"
+	BulkValid   = "valid"
+	BulkFailed  = "failed"
+)
+
+// BulkUpdateRequest either lists employees with their own changes in Items, or
+// applies one set of Changes to every employee matching Filter.
+type BulkUpdateRequest struct {
+	Items   []BulkUpdateItem  `json:"items"`
+	Filter  *BulkUpdateFilter `json:"filter"`
+	Changes *Employee         `json:"changes"`
+	DryRun  bool              `json:"dryRun"`
+}
+
+type BulkUpdateItem struct {
+	EmployeeId int      `json:"employeeId"`
+	Changes    Employee `json:"changes"`
+}
+
+type BulkUpdateFilter struct {
+	DepartmentId *int    `json:"departmentId"`
+	ManagerId    *int    `json:"managerId"`
+	JobId        *string `json:"jobId"`
+}
+
+type BulkUpdateResult struct {
+	EmployeeId int       `json:"employeeId"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Before     *Employee `json:"before,omitempty"`
+	After      *Employee `json:"after,omitempty"`
+}
+
+type BulkUpdateResponse struct {
+	DryRun  bool               `json:"dryRun"`
+	Applied bool               `json:"applied"`
+	Matched int                `json:"matched"`
+	Failed  int                `json:"failed"`
+	Results []BulkUpdateResult `json:"results"`
+}
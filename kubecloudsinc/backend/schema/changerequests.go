@@ -0,0 +1,40 @@
+package schema
+
+import "time"
+
+// Change request states. Only pending requests can be approved or rejected.
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+)
+
+// ChangeRequest is an editor's update that touches restricted fields. Changes holds
+// the values of the ChangedFields only; a changed field that is unset in Changes is
+// cleared. They are merged into the employee as it is when the request is approved.
+type ChangeRequest struct {
+	ChangeRequestId  int        `json:"changeRequestId"`
+	EmployeeId       int        `json:"employeeId"`
+	Changes          Employee   `json:"changes"`
+	ChangedFields    []string   `json:"changedFields"`
+	RestrictedFields []string   `json:"restrictedFields"`
+	Status           string     `json:"status"`
+	RequestedBy      Actor      `json:"requestedBy"`
+	RequestedAt      time.Time  `json:"requestedAt"`
+	ReviewedBy       *string    `json:"reviewedBy"`
+	ReviewedAt       *time.Time `json:"reviewedAt"`
+	ReviewComment    *string    `json:"reviewComment"`
+}
+
+// ChangeRequestAccepted is returned with 202 when an update is held for review.
+type ChangeRequestAccepted struct {
+	ChangeRequestId  int      `json:"changeRequestId"`
+	Status           string   `json:"status"`
+	RestrictedFields []string `json:"restrictedFields"`
+	Message          string   `json:"message"`
+}
+
+// ChangeRequestReview carries the admin's comment when approving or rejecting.
+type ChangeRequestReview struct {
+	Comment *string `json:"comment"`
+}
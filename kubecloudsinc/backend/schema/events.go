@@ -0,0 +1,39 @@
+package schema
+
+import "time"
+
+// Change event types written to the outbox for every employee mutation.
+const (
+	EventEmployeeCreated = "employee.created"
+	EventEmployeeUpdated = "employee.updated"
+	EventEmployeeDeleted = "employee.deleted"
+
+	// EventDepartmentMembersChanged is emitted for both the old and the new
+	// department when an employee joins, leaves or moves between departments.
+	EventDepartmentMembersChanged = "department.members_changed"
+)
+
+// Actor identifies the authenticated user behind a mutation. Endpoint is the route or
+// RPC that was called; it is recorded in the audit log but not in change events.
+// EmployeeId links the user to their own employee record, 0 when there is none; it
+// decides what scoped roles reach and is not recorded.
+type Actor struct {
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	Endpoint   string `json:"-"`
+	EmployeeId int    `json:"-"`
+}
+
+// ChangeEvent is the structured record published to downstream systems.
+// Before is nil for creations and After is nil for deletions; department events
+// carry DepartmentId and no employee images.
+type ChangeEvent struct {
+	EventId      int64     `json:"eventId"`
+	Type         string    `json:"type"`
+	EmployeeId   int       `json:"employeeId"`
+	DepartmentId *int      `json:"departmentId,omitempty"`
+	Before       *Employee `json:"before"`
+	After        *Employee `json:"after"`
+	Actor        Actor     `json:"actor"`
+	OccurredAt   time.Time `json:"occurredAt"`
+}
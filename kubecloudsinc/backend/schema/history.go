@@ -0,0 +1,30 @@
+package schema
+
+import "time"
+
+// Sources of timeline events.
+const (
+	TimelineHire       = "hire"
+	TimelineJobHistory = "job_history"
+	TimelineAudit      = "audit"
+)
+
+// EmployeeAsOf is an employee record reconstructed for a past point in time. Complete
+// is false when that time predates the audit log: the job and department still come
+// from JOB_HISTORY, but other fields show the oldest value the audit log knows.
+type EmployeeAsOf struct {
+	AsOf     string   `json:"asOf"`
+	Employee Employee `json:"employee"`
+	Complete bool     `json:"complete"`
+}
+
+// TimelineEvent is one entry in an employee's history. Actor and Endpoint are only
+// known for audited mutations.
+type TimelineEvent struct {
+	OccurredAt time.Time     `json:"occurredAt"`
+	Source     string        `json:"source"`
+	Action     string        `json:"action"`
+	Actor      *Actor        `json:"actor,omitempty"`
+	Endpoint   string        `json:"endpoint,omitempty"`
+	Changes    []FieldChange `json:"changes"`
+}
@@ -0,0 +1,41 @@
+package schema
+
+// Flat records for the HR reference tables. They carry the foreign keys that the
+// nested profile types leave out so callers can resolve relations themselves.
+
+type JobRecord struct {
+	JobId     *string  `json:"jobId"`
+	JobTitle  *string  `json:"jobTitle"`
+	MinSalary *float64 `json:"minSalary"`
+	MaxSalary *float64 `json:"maxSalary"`
+}
+
+type DepartmentRecord struct {
+	DepartmentId   *int    `json:"departmentId"`
+	DepartmentName *string `json:"departmentName"`
+	ManagerId      *int    `json:"managerId"`
+	LocationId     *int    `json:"locationId"`
+}
+
+type LocationRecord struct {
+	LocationId    *int    `json:"locationId"`
+	StreetAddress *string `json:"streetAddress"`
+	PostalCode    *string `json:"postalCode"`
+	City          *string `json:"city"`
+	StateProvince *string `json:"stateProvince"`
+	CountryId     *string `json:"countryId"`
+}
+
+type CountryRecord struct {
+	CountryId   *string `json:"countryId"`
+	CountryName *string `json:"countryName"`
+	RegionId    *int    `json:"regionId"`
+}
+
+type JobHistoryRecord struct {
+	EmployeeId   *int    `json:"employeeId"`
+	StartDate    *string `json:"startDate"`
+	EndDate      *string `json:"endDate"`
+	JobId        *string `json:"jobId"`
+	DepartmentId *int    `json:"departmentId"`
+}
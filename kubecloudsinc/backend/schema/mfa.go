@@ -0,0 +1,49 @@
+package schema
+
+import "time"
+
+// UserMFA is the TOTP second factor of a user. A secret without EnabledAt belongs to
+// an enrollment that was not confirmed with a code yet. Required says whether the
+// user's role must use MFA.
+type UserMFA struct {
+	UserId            int        `json:"userId"`
+	Enabled           bool       `json:"enabled"`
+	Required          bool       `json:"required"`
+	EnabledAt         *time.Time `json:"enabledAt"`
+	RecoveryCodesLeft int        `json:"recoveryCodesLeft"`
+	Secret            string     `json:"-"`
+	LastStep          int64      `json:"-"`
+}
+
+// MFAEnrollment is a new TOTP secret. OtpauthUri is the provisioning URI to show as a
+// QR code; Secret is the same key for typing into the app.
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthUri string `json:"otpauthUri"`
+}
+
+// MFACode proves the second factor: a code of the authenticator app or, at login and
+// when turning MFA off, one of the recovery codes.
+type MFACode struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// RecoveryCodes are shown once, when they are issued.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFAChallenge is the answer to a correct password of a user who needs a second
+// factor. EnrollmentRequired means the user must enroll first.
+type MFAChallenge struct {
+	MfaToken           string `json:"mfaToken"`
+	ExpiresIn          int    `json:"expiresIn"`
+	EnrollmentRequired bool   `json:"enrollmentRequired"`
+}
+
+// MFALogin is the second step of a login.
+type MFALogin struct {
+	MfaToken string `json:"mfaToken"`
+	MFACode
+}
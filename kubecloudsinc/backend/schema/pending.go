@@ -0,0 +1,31 @@
+package schema
+
+import "time"
+
+// Pending change states. Failed changes could not be applied on their effective date;
+// the reason is kept in LastError.
+const (
+	PendingChangePending   = "pending"
+	PendingChangeApplied   = "applied"
+	PendingChangeCancelled = "cancelled"
+	PendingChangeFailed    = "failed"
+)
+
+// PendingChangeRequest schedules Changes for an employee on EffectiveDate (YYYY-MM-DD).
+type PendingChangeRequest struct {
+	EffectiveDate *string   `json:"effectiveDate"`
+	Changes       *Employee `json:"changes"`
+}
+
+type PendingChange struct {
+	ChangeId      int        `json:"changeId"`
+	EmployeeId    int        `json:"employeeId"`
+	EffectiveDate string     `json:"effectiveDate"`
+	Changes       Employee   `json:"changes"`
+	Status        string     `json:"status"`
+	CreatedBy     Actor      `json:"createdBy"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	CancelledBy   *string    `json:"cancelledBy"`
+	AppliedAt     *time.Time `json:"appliedAt"`
+	LastError     *string    `json:"lastError"`
+}
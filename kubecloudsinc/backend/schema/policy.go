@@ -0,0 +1,10 @@
+package schema
+
+// FieldViolation is one field a role may not write. Field is empty when the whole
+// operation is refused, as for deletes.
+type FieldViolation struct {
+	Field     string `json:"field,omitempty"`
+	Operation string `json:"operation"`
+	Role      string `json:"role"`
+	Reason    string `json:"reason"`
+}
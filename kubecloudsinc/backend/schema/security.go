@@ -0,0 +1,29 @@
+package schema
+
+import "time"
+
+// Types of security events.
+const (
+	SecurityEventLoginFailed   = "login_failed"
+	SecurityEventLoginLockout  = "login_lockout"
+	SecurityEventLoginUnlocked = "login_unlocked"
+)
+
+// SecurityEvent records a failed login, a lockout it caused or an admin lifting one.
+// Username is the name that was tried, which need not belong to a user; Actor is the
+// admin who unlocked.
+type SecurityEvent struct {
+	EventId    int64     `json:"eventId"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Type       string    `json:"type"`
+	Username   *string   `json:"username"`
+	IpAddress  *string   `json:"ipAddress"`
+	Actor      *string   `json:"actor"`
+	Detail     string    `json:"detail"`
+}
+
+// LoginUnlock is the body of a lockout removal: the username, the IP address or both.
+type LoginUnlock struct {
+	Username  string `json:"username"`
+	IpAddress string `json:"ipAddress"`
+}
@@ -0,0 +1,42 @@
+package schema
+
+import "time"
+
+// Reasons a session was revoked.
+const (
+	SessionRevokedLogout          = "logout"
+	SessionRevokedReuse           = "refresh_token_reuse"
+	SessionRevokedUserChanged     = "user_changed"
+	SessionRevokedUserDeleted     = "user_deleted"
+	SessionRevokedPasswordChanged = "password_changed"
+	SessionRevokedMFAReset        = "mfa_reset"
+)
+
+// Session is one login. Every refresh token issued since the login belongs to it, so
+// revoking the session ends the login on every token it produced.
+type Session struct {
+	SessionId    string     `json:"sessionId"`
+	UserId       int        `json:"userId"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+	RevokeReason *string    `json:"revokeReason,omitempty"`
+}
+
+// RefreshToken is the stored form of a refresh token: only the SHA-256 hash of the
+// token is kept. AccessJti is the jti of the access token issued with it, which is
+// denied when the session is revoked. UsedAt is set once the token was exchanged.
+type RefreshToken struct {
+	TokenHash       string     `json:"-"`
+	SessionId       string     `json:"sessionId"`
+	AccessJti       string     `json:"accessJti"`
+	AccessExpiresAt time.Time  `json:"accessExpiresAt"`
+	IssuedAt        time.Time  `json:"issuedAt"`
+	ExpiresAt       time.Time  `json:"expiresAt"`
+	UsedAt          *time.Time `json:"usedAt,omitempty"`
+}
+
+// TokenRefresh is the body of a token refresh.
+type TokenRefresh struct {
+	RefreshToken string `json:"refreshToken"`
+}
@@ -0,0 +1,46 @@
+package schema
+
+import "time"
+
+// User is a login. EmployeeId links it to the user's own employee record, which roles
+// scoped to their reports need. OidcSubject is set for users who sign in through the
+// OpenID Connect provider. The password hash never leaves the service.
+type User struct {
+	UserId            int       `json:"userId"`
+	Username          string    `json:"username"`
+	Role              string    `json:"role"`
+	EmployeeId        *int      `json:"employeeId,omitempty"`
+	Disabled          bool      `json:"disabled"`
+	OidcSubject       *string   `json:"oidcSubject,omitempty"`
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	PasswordHash      string    `json:"-"`
+}
+
+// NewUser is the body of a user creation.
+type NewUser struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Role       string `json:"role"`
+	EmployeeId *int   `json:"employeeId"`
+}
+
+// UserUpdate replaces the role, employee link and disabled flag of a user. A missing
+// employeeId unlinks the user from their employee record.
+type UserUpdate struct {
+	Role       string `json:"role"`
+	EmployeeId *int   `json:"employeeId"`
+	Disabled   bool   `json:"disabled"`
+}
+
+// PasswordReset is the body of an admin's password reset.
+type PasswordReset struct {
+	Password string `json:"password"`
+}
+
+// PasswordChange is the body of a user changing their own password.
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
@@ -0,0 +1,40 @@
+package schema
+
+import "time"
+
+// Webhook delivery states. In-flight deliveries are being sent by a worker until
+// their next attempt time, the end of its lease. Dead deliveries have exhausted their
+// retries and stay in the dead-letter list until they are redelivered.
+const (
+	DeliveryPending   = "pending"
+	DeliveryInFlight  = "in_flight"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type WebhookSubscription struct {
+	SubscriptionId *int       `json:"subscriptionId"`
+	URL            *string    `json:"url"`
+	EventTypes     []string   `json:"eventTypes"`
+	Secret         *string    `json:"secret,omitempty"`
+	Active         *bool      `json:"active"`
+	CreatedBy      *string    `json:"createdBy"`
+	CreatedAt      *time.Time `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	DeliveryId     int        `json:"deliveryId"`
+	SubscriptionId int        `json:"subscriptionId"`
+	EventId        int64      `json:"eventId"`
+	EventType      string     `json:"eventType"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode *int       `json:"lastStatusCode"`
+	LastError      *string    `json:"lastError"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+	URL            string     `json:"-"`
+	Secret         string     `json:"-"`
+	Payload        string     `json:"-"`
+}
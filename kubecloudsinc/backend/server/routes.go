@@ -2,11 +2,23 @@ package server
 
 import (
 	// Adjust this import path to your project structure
+	"autotools-golang-api/kubecloudsinc/backend/apikeys"
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/events"
+	"autotools-golang-api/kubecloudsinc/backend/gql"
 	"autotools-golang-api/kubecloudsinc/backend/handler"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/mfa"
 	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/oidc"
+	"autotools-golang-api/kubecloudsinc/backend/openapi"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
 	"log"
 	"net/http"
 	"net/http/pprof"
+	"os"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -14,16 +26,95 @@ import (
 )
 
 // Initialize and return a new HTTP router
-func NewRouter(app *newrelic.Application) *mux.Router {
+func NewRouter(app *newrelic.Application, broker *events.Broker, userStore users.Store, sessionStore sessions.Store, oidcProvider *oidc.Provider, apiKeyStore apikeys.Store, loginGuard *loginguard.Guard, mfaStore mfa.Store) *mux.Router {
 	r := mux.NewRouter()
 
-	r.HandleFunc("/v2/login", middleware.Login).Methods("POST")
-	r.HandleFunc("/v2/employees", middleware.IsAuthorized("admin", "editor", "viewer")(handler.GetEmployees)).Methods("GET")
-	r.HandleFunc("/v2/employee", middleware.IsAuthorized("admin", "editor", "viewer")(handler.GetEmployee)).Methods("GET")
-	r.HandleFunc("/v2/employee/{employeeId}", middleware.IsAuthorized("admin", "editor", "viewer")(handler.GetEmployeeProfile)).Methods("GET")
-	r.HandleFunc("/v2/employee", middleware.IsAuthorized("admin", "editor")(handler.AddEmployee)).Methods("POST")
-	r.HandleFunc("/v2/employee/{employeeId}", middleware.IsAuthorized("admin", "editor")(handler.UpdateEmployee)).Methods("PUT")
-	r.HandleFunc("/v2/employee/{employeeId}", middleware.IsAuthorized("admin")(handler.DeleteEmployee)).Methods("DELETE")
+	// Request bodies are checked against openapi.json before they reach the handlers
+	spec := openapi.MustLoad()
+	r.Use(spec.ValidateRequests)
+	if os.Getenv("OPENAPI_VALIDATE_RESPONSES") == "true" {
+		r.Use(spec.ValidateResponses)
+	}
+
+	// API description
+	r.HandleFunc("/openapi.json", openapi.ServeSpec).Methods("GET")
+	r.HandleFunc("/docs", openapi.ServeDocs).Methods("GET")
+
+	// Public keys that verify the access tokens
+	r.HandleFunc("/.well-known/jwks.json", middleware.ServeJWKS).Methods("GET")
+
+	r.HandleFunc("/v2/login", middleware.Login(userStore, sessionStore, mfaStore, loginGuard)).Methods("POST")
+	// Second step of the login for users with MFA, and enrollment when their role forces it
+	r.HandleFunc("/v2/login/mfa", middleware.LoginMFA(userStore, sessionStore, mfaStore, loginGuard)).Methods("POST")
+	r.HandleFunc("/v2/login/mfa/enroll", middleware.LoginMFAEnroll(userStore, mfaStore)).Methods("POST")
+	r.HandleFunc("/v2/token/refresh", middleware.RefreshToken(userStore, sessionStore)).Methods("POST")
+	// Sign-in through the OpenID Connect provider, when one is configured
+	r.HandleFunc("/v2/auth/oidc/login", middleware.OIDCLogin(oidcProvider)).Methods("GET")
+	r.HandleFunc("/v2/auth/oidc/callback", middleware.OIDCCallback(oidcProvider, userStore, sessionStore)).Methods("GET")
+	r.HandleFunc("/v2/logout", middleware.RequireLogin(middleware.Logout(sessionStore))).Methods("POST")
+	r.HandleFunc("/v2/employees", middleware.RequirePermission(policy.EmployeeRead)(middleware.MaskReads(handler.GetEmployees))).Methods("GET")
+	r.HandleFunc("/v2/employee", middleware.RequirePermission(policy.EmployeeRead)(middleware.MaskReads(handler.GetEmployee))).Methods("GET")
+	r.HandleFunc("/v2/employee/{employeeId}", middleware.RequirePermission(policy.EmployeeRead)(middleware.MaskReads(handler.GetEmployeeProfile))).Methods("GET")
+	r.HandleFunc("/v2/employee", middleware.RequirePermission(policy.EmployeeWrite)(handler.AddEmployee)).Methods("POST")
+	r.HandleFunc("/v2/employee/{employeeId}", middleware.RequirePermission(policy.EmployeeWrite)(handler.UpdateEmployee)).Methods("PUT")
+	r.HandleFunc("/v2/employee/{employeeId}", middleware.RequirePermission(policy.EmployeeDelete)(handler.DeleteEmployee)).Methods("DELETE")
+	r.HandleFunc("/v2/employees/bulk-update", middleware.RequirePermission(policy.EmployeeWrite)(middleware.MaskReads(handler.BulkUpdateEmployees))).Methods("POST")
+
+	// Changes scheduled for a future effective date
+	r.HandleFunc("/v2/employee/{employeeId}/pending-changes", middleware.RequirePermission(policy.EmployeeWrite)(middleware.MaskReads(handler.AddPendingChange))).Methods("POST")
+	r.HandleFunc("/v2/employee/{employeeId}/pending-changes", middleware.RequirePermission(policy.EmployeeWrite)(middleware.MaskReads(handler.GetPendingChanges))).Methods("GET")
+	r.HandleFunc("/v2/employee/{employeeId}/pending-changes/{changeId}", middleware.RequirePermission(policy.EmployeeWrite)(handler.CancelPendingChange)).Methods("DELETE")
+
+	// Editor updates to restricted fields wait here for an admin's decision
+	r.HandleFunc("/v2/change-requests", middleware.RequirePermission(policy.ChangeRequestReview)(middleware.MaskReads(handler.GetChangeRequests))).Methods("GET")
+	r.HandleFunc("/v2/change-requests/{changeRequestId}", middleware.RequirePermission(policy.ChangeRequestReview, policy.ChangeRequestRead)(middleware.MaskReads(handler.GetChangeRequest))).Methods("GET")
+	r.HandleFunc("/v2/change-requests/{changeRequestId}/approve", middleware.RequirePermission(policy.ChangeRequestReview)(middleware.MaskReads(handler.ApproveChangeRequest))).Methods("POST")
+	r.HandleFunc("/v2/change-requests/{changeRequestId}/reject", middleware.RequirePermission(policy.ChangeRequestReview)(middleware.MaskReads(handler.RejectChangeRequest))).Methods("POST")
+
+	// Append-only audit log of employee mutations
+	r.HandleFunc("/v2/audit", middleware.RequirePermission(policy.AuditRead)(middleware.MaskReads(handler.GetAuditLog))).Methods("GET")
+	r.HandleFunc("/v2/employee/{employeeId}/timeline", middleware.RequirePermission(policy.EmployeeHistory)(middleware.MaskReads(handler.GetEmployeeTimeline))).Methods("GET")
+
+	// Server-sent change feed
+	r.HandleFunc("/v2/events", middleware.RequirePermission(policy.EmployeeRead)(handler.StreamEvents(broker))).Methods("GET")
+
+	// GraphQL over the HR domain
+	r.HandleFunc("/v2/graphql", middleware.RequirePermission(policy.EmployeeRead)(handler.GraphQL(gql.MustNewSchema(dbs.DB)))).Methods("POST")
+
+	// Webhook subscriptions and deliveries
+	r.HandleFunc("/v2/webhooks", middleware.RequirePermission(policy.WebhookManage)(handler.AddWebhook)).Methods("POST")
+	r.HandleFunc("/v2/webhooks", middleware.RequirePermission(policy.WebhookManage)(handler.GetWebhooks)).Methods("GET")
+	r.HandleFunc("/v2/webhooks/deliveries", middleware.RequirePermission(policy.WebhookManage)(handler.GetWebhookDeliveries)).Methods("GET")
+	r.HandleFunc("/v2/webhooks/deliveries/{deliveryId}/redeliver", middleware.RequirePermission(policy.WebhookManage)(handler.RedeliverWebhook)).Methods("POST")
+	r.HandleFunc("/v2/webhooks/{subscriptionId}", middleware.RequirePermission(policy.WebhookManage)(handler.DeleteWebhook)).Methods("DELETE")
+
+	// User administration and self-service password changes
+	r.HandleFunc("/v2/users", middleware.RequirePermission(policy.UserManage)(handler.AddUser(userStore))).Methods("POST")
+	r.HandleFunc("/v2/users", middleware.RequirePermission(policy.UserManage)(handler.GetUsers(userStore))).Methods("GET")
+	r.HandleFunc("/v2/users/{userId}", middleware.RequirePermission(policy.UserManage)(handler.GetUser(userStore))).Methods("GET")
+	r.HandleFunc("/v2/users/{userId}", middleware.RequirePermission(policy.UserManage)(handler.UpdateUser(userStore, sessionStore))).Methods("PUT")
+	r.HandleFunc("/v2/users/{userId}", middleware.RequirePermission(policy.UserManage)(handler.DeleteUser(userStore, sessionStore))).Methods("DELETE")
+	r.HandleFunc("/v2/users/{userId}/password", middleware.RequirePermission(policy.UserManage)(handler.ResetUserPassword(userStore, sessionStore))).Methods("POST")
+	r.HandleFunc("/v2/me/password", middleware.RequireLogin(handler.ChangeOwnPassword(userStore, sessionStore, loginGuard))).Methods("PUT")
+
+	// TOTP multi-factor authentication of the caller, and its reset by admins
+	r.HandleFunc("/v2/me/mfa", middleware.RequireLogin(handler.GetOwnMFA(userStore, mfaStore))).Methods("GET")
+	r.HandleFunc("/v2/me/mfa", middleware.RequireLogin(handler.EnrollOwnMFA(userStore, mfaStore))).Methods("POST")
+	r.HandleFunc("/v2/me/mfa/confirm", middleware.RequireLogin(handler.ConfirmOwnMFA(userStore, mfaStore))).Methods("POST")
+	r.HandleFunc("/v2/me/mfa/disable", middleware.RequireLogin(handler.DisableOwnMFA(userStore, mfaStore))).Methods("POST")
+	r.HandleFunc("/v2/me/mfa/recovery-codes", middleware.RequireLogin(handler.RegenerateRecoveryCodes(userStore, mfaStore))).Methods("POST")
+	r.HandleFunc("/v2/users/{userId}/mfa", middleware.RequirePermission(policy.UserManage)(handler.GetUserMFA(userStore, mfaStore))).Methods("GET")
+	r.HandleFunc("/v2/users/{userId}/mfa", middleware.RequirePermission(policy.UserManage)(handler.ResetUserMFA(userStore, mfaStore, sessionStore))).Methods("DELETE")
+
+	// Failed logins, lockouts and their removal
+	r.HandleFunc("/v2/security-events", middleware.RequirePermission(policy.AuditRead)(handler.GetSecurityEvents(loginGuard.Events))).Methods("GET")
+	r.HandleFunc("/v2/login-lockouts/unlock", middleware.RequirePermission(policy.UserManage)(handler.UnlockLogin(loginGuard))).Methods("POST")
+
+	// API keys of service clients
+	r.HandleFunc("/v2/api-keys", middleware.RequirePermission(policy.APIKeyManage)(handler.AddAPIKey(apiKeyStore))).Methods("POST")
+	r.HandleFunc("/v2/api-keys", middleware.RequirePermission(policy.APIKeyManage)(handler.GetAPIKeys(apiKeyStore))).Methods("GET")
+	r.HandleFunc("/v2/api-keys/{keyId}", middleware.RequirePermission(policy.APIKeyManage)(handler.GetAPIKey(apiKeyStore))).Methods("GET")
+	r.HandleFunc("/v2/api-keys/{keyId}", middleware.RequirePermission(policy.APIKeyManage)(handler.RevokeAPIKey(apiKeyStore))).Methods("DELETE")
 
 	// Manually register pprof handlers
 	r.HandleFunc("/debug/pprof/", pprof.Index)
@@ -35,19 +126,29 @@ func NewRouter(app *newrelic.Application) *mux.Router {
 	// Register other pprof handlers
 	r.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
 
+	// Every API route must be documented, and every documented operation routed
+	if err := spec.CheckRoutes(r); err != nil {
+		log.Fatalf("openapi.json is out of sync with the router: %v", err)
+	}
+
 	return r
 }
 
 // StartServer starts the HTTP server on a specified port
-func StartServer(port string, app *newrelic.Application) error {
-	r := NewRouter(app)
+func StartServer(port string, app *newrelic.Application, broker *events.Broker, userStore users.Store, sessionStore sessions.Store, oidcProvider *oidc.Provider, apiKeyStore apikeys.Store, loginGuard *loginguard.Guard, mfaStore mfa.Store) error {
+	r := NewRouter(app, broker, userStore, sessionStore, oidcProvider, apiKeyStore, loginGuard, mfaStore)
 	//loggedRouter := handlers.LoggingHandler(os.Stdout, r)
 	// Setup CORS
-	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
+	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "Last-Event-ID", middleware.APIKeyHeader, middleware.CSRFHeader, middleware.SessionModeHeader})
 	originsOk := handlers.AllowedOrigins([]string{"http://localhost:3000"}) // The frontend origin
-	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"})
+	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"})
+	options := []handlers.CORSOption{originsOk, headersOk, methodsOk}
+	// The frontend sends its session cookies along
+	if middleware.Cookies != nil {
+		options = append(options, handlers.AllowCredentials())
+	}
 
 	//http.Handle("/", r)
 	log.Printf("Server starting on port %s", port)
-	return http.ListenAndServe(port, handlers.CORS(originsOk, headersOk, methodsOk)(r))
+	return http.ListenAndServe(port, handlers.CORS(options...)(r))
 }
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/apikeys"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/mfa"
+	"autotools-golang-api/kubecloudsinc/backend/middleware"
+	"autotools-golang-api/kubecloudsinc/backend/openapi"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/signing"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const testPassword = "correct-horse-battery"
+
+// apiClient drives the routed handlers in memory and fails the test on every response
+// that drifts from openapi.json.
+type apiClient struct {
+	t      *testing.T
+	router *mux.Router
+	spec   *openapi.Spec
+}
+
+func newAPIClient(t *testing.T) *apiClient {
+	t.Helper()
+	keys, err := signing.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Issuer, keys.Audience = "employee-api-test", "employee-api-test"
+	middleware.SigningKeys = keys
+
+	hash, err := users.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usersFile := filepath.Join(t.TempDir(), "users.yaml")
+	seed := fmt.Sprintf("users:\n  - username: mazda\n    passwordHash: %q\n    role: admin\n  - username: honda\n    passwordHash: %q\n    role: viewer\n", hash, hash)
+	if err := os.WriteFile(usersFile, []byte(seed), 0600); err != nil {
+		t.Fatal(err)
+	}
+	userStore, err := users.LoadMemoryStore(usersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionStore := sessions.NewMemoryStore()
+	middleware.Denylist = sessionStore
+	apiKeyStore := apikeys.NewMemoryStore()
+	middleware.APIKeys = apikeys.NewAuthenticator(apiKeyStore)
+	guard := loginguard.NewGuard(loginguard.NewMemoryCounters(), loginguard.NewMemoryEventStore())
+
+	return &apiClient{
+		t:      t,
+		router: NewRouter(nil, nil, userStore, sessionStore, nil, apiKeyStore, guard, mfa.NewMemoryStore()),
+		spec:   openapi.MustLoad(),
+	}
+}
+
+// call sends a request with the bearer token, unless it is empty, checks the status and
+// the response against the spec, and decodes a JSON response into out, unless it is nil.
+func (c *apiClient) call(method, path, token, body string, wantStatus int, out interface{}) {
+	c.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	c.send(req, wantStatus, out)
+}
+
+// send is call for a request built by the caller. It returns the recorded response.
+func (c *apiClient) send(req *http.Request, wantStatus int, out interface{}) *httptest.ResponseRecorder {
+	c.t.Helper()
+	method, path := req.Method, req.URL.Path
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+
+	if rec.Code != wantStatus {
+		c.t.Fatalf("%s %s returned %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body.String())
+	}
+	var match mux.RouteMatch
+	if !c.router.Match(req, &match) {
+		c.t.Fatalf("%s %s matched no route", method, path)
+	}
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.spec.CheckResponse(method, template, rec.Code, rec.Header().Get("Content-Type"), rec.Body.Bytes()); err != nil {
+		c.t.Errorf("%s %s drifted from openapi.json: %v", method, path, err)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			c.t.Fatalf("%s %s returned an invalid body: %v", method, path, err)
+		}
+	}
+	return rec
+}
+
+type tokens struct {
+	Token         string   `json:"token"`
+	RefreshToken  string   `json:"refreshToken"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+func TestSessionResponsesMatchTheSpec(t *testing.T) {
+	c := newAPIClient(t)
+
+	c.call("POST", "/v2/login", "", `{"username":"honda","password":"wrong-password"}`, http.StatusUnauthorized, nil)
+	var session tokens
+	c.call("POST", "/v2/login", "", `{"username":"honda","password":"`+testPassword+`"}`, http.StatusOK, &session)
+
+	c.call("GET", "/v2/me/mfa", session.Token, "", http.StatusOK, nil)
+	c.call("PUT", "/v2/me/password", session.Token, `{"currentPassword":"wrong-password","newPassword":"another-long-password"}`, http.StatusForbidden, nil)
+	c.call("GET", "/v2/users", session.Token, "", http.StatusForbidden, nil)
+
+	var refreshed tokens
+	c.call("POST", "/v2/token/refresh", "", `{"refreshToken":"`+session.RefreshToken+`"}`, http.StatusOK, &refreshed)
+	c.call("POST", "/v2/logout", refreshed.Token, "", http.StatusOK, nil)
+	c.call("GET", "/v2/me/mfa", refreshed.Token, "", http.StatusUnauthorized, nil)
+	c.call("POST", "/v2/token/refresh", "", `{"refreshToken":"`+refreshed.RefreshToken+`"}`, http.StatusUnauthorized, nil)
+}
+
+// adminSession logs the admin in. Admins must use MFA, so the first login enrolls.
+func (c *apiClient) adminSession() tokens {
+	c.t.Helper()
+	var challenge struct {
+		MfaToken string `json:"mfaToken"`
+	}
+	c.call("POST", "/v2/login", "", `{"username":"mazda","password":"`+testPassword+`"}`, http.StatusAccepted, &challenge)
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	c.call("POST", "/v2/login/mfa/enroll", "", `{"mfaToken":"`+challenge.MfaToken+`"}`, http.StatusOK, &enrollment)
+	code, err := mfa.Code(enrollment.Secret, mfa.Step(time.Now()))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var session tokens
+	c.call("POST", "/v2/login/mfa", "", `{"mfaToken":"`+challenge.MfaToken+`","code":"`+code+`"}`, http.StatusOK, &session)
+	if len(session.RecoveryCodes) == 0 {
+		c.t.Fatal("the enrollment returned no recovery codes")
+	}
+	return session
+}
+
+func TestAdminResponsesMatchTheSpec(t *testing.T) {
+	c := newAPIClient(t)
+	session := c.adminSession()
+
+	c.call("GET", "/v2/users", session.Token, "", http.StatusOK, nil)
+	var user struct {
+		UserId int `json:"userId"`
+	}
+	c.call("POST", "/v2/users", session.Token, `{"username":"toyota","password":"another-long-password","role":"viewer"}`, http.StatusCreated, &user)
+	c.call("POST", "/v2/users", session.Token, `{"username":"toyota","password":"another-long-password","role":"viewer"}`, http.StatusConflict, nil)
+	userPath := fmt.Sprintf("/v2/users/%d", user.UserId)
+	c.call("GET", userPath, session.Token, "", http.StatusOK, nil)
+	c.call("PUT", userPath, session.Token, `{"role":"editor"}`, http.StatusOK, nil)
+	c.call("GET", userPath+"/mfa", session.Token, "", http.StatusOK, nil)
+	c.call("GET", "/v2/users/999", session.Token, "", http.StatusNotFound, nil)
+	c.call("DELETE", userPath, session.Token, "", http.StatusOK, nil)
+
+	var key struct {
+		KeyId int `json:"keyId"`
+	}
+	c.call("POST", "/v2/api-keys", session.Token, `{"name":"payroll-sync","role":"viewer","scopes":["employee:read"]}`, http.StatusCreated, &key)
+	c.call("GET", "/v2/api-keys", session.Token, "", http.StatusOK, nil)
+	c.call("GET", fmt.Sprintf("/v2/api-keys/%d", key.KeyId), session.Token, "", http.StatusOK, nil)
+	c.call("DELETE", fmt.Sprintf("/v2/api-keys/%d", key.KeyId), session.Token, "", http.StatusOK, nil)
+
+	c.call("GET", "/v2/security-events", session.Token, "", http.StatusOK, nil)
+	c.call("POST", "/v2/login-lockouts/unlock", session.Token, `{"username":"honda"}`, http.StatusOK, nil)
+	c.call("POST", "/v2/login-lockouts/unlock", session.Token, `{}`, http.StatusBadRequest, nil)
+}
+
+func TestAPIKeyIsLimitedToItsScopes(t *testing.T) {
+	c := newAPIClient(t)
+	session := c.adminSession()
+
+	apiKey := func(name, scope string) string {
+		var key struct {
+			Key string `json:"key"`
+		}
+		c.call("POST", "/v2/api-keys", session.Token, `{"name":"`+name+`","role":"admin","scopes":["`+scope+`"]}`, http.StatusCreated, &key)
+		return key.Key
+	}
+	withKey := func(method, path, key string, wantStatus int) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(middleware.APIKeyHeader, key)
+		c.send(req, wantStatus, nil)
+	}
+
+	userManager := apiKey("user-sync", "user:manage")
+	withKey("GET", "/v2/users", userManager, http.StatusOK)
+	// The admin role would allow the route, but the key is not scoped to it
+	withKey("GET", "/v2/api-keys", userManager, http.StatusForbidden)
+	withKey("GET", "/v2/users", apiKey("employee-sync", "employee:read"), http.StatusForbidden)
+	// API keys are not users
+	withKey("GET", "/v2/me/mfa", userManager, http.StatusForbidden)
+	withKey("GET", "/v2/users", "kck_unknown", http.StatusUnauthorized)
+}
+
+func TestCookieSessionRefreshesWithoutABody(t *testing.T) {
+	middleware.Cookies = &middleware.CookieConfig{Secure: true, SameSite: http.SameSiteStrictMode}
+	defer func() { middleware.Cookies = nil }()
+	c := newAPIClient(t)
+
+	login := httptest.NewRequest("POST", "/v2/login", strings.NewReader(`{"username":"honda","password":"`+testPassword+`"}`))
+	login.Header.Set("Content-Type", "application/json")
+	login.Header.Set(middleware.SessionModeHeader, "cookie")
+	var session struct {
+		CSRFToken string `json:"csrfToken"`
+	}
+	rec := c.send(login, http.StatusOK, &session)
+
+	refresh := httptest.NewRequest("POST", "/v2/token/refresh", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		refresh.AddCookie(cookie)
+	}
+	refresh.Header.Set(middleware.CSRFHeader, session.CSRFToken)
+	rec = c.send(refresh, http.StatusOK, nil)
+	if len(rec.Result().Cookies()) == 0 {
+		t.Fatal("the refresh set no cookies")
+	}
+
+	c.call("POST", "/v2/token/refresh", "", "", http.StatusBadRequest, nil)
+}
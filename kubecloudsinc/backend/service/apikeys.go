@@ -0,0 +1,96 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/apikeys"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ListAPIKeys returns every API key, without the keys themselves.
+func ListAPIKeys(ctx context.Context, store apikeys.Store) ([]schema.APIKey, error) {
+	return store.List(ctx)
+}
+
+// GetAPIKey returns one API key.
+func GetAPIKey(ctx context.Context, store apikeys.Store, keyId int) (*schema.APIKey, error) {
+	return store.Get(ctx, keyId)
+}
+
+// CreateAPIKey issues a key that acts with input.Role for the permissions in
+// input.Scopes, which the role must hold. Roles limited to a reporting tree need a
+// user's employee record and cannot be given to keys. The key is returned once and
+// only its hash is stored.
+func CreateAPIKey(ctx context.Context, store apikeys.Store, input schema.NewAPIKey, actor schema.Actor) (*schema.CreatedAPIKey, error) {
+	if !validUsername.MatchString(input.Name) {
+		return nil, &ValidationError{errors.New("name must be 1 to 100 letters, digits or . _ @ -")}
+	}
+	if !policy.CurrentGrants.HasRole(input.Role) {
+		return nil, &ValidationError{fmt.Errorf("unknown role %q", input.Role)}
+	}
+	if policy.CurrentGrants.Scope(input.Role) != "" {
+		return nil, &ValidationError{fmt.Errorf("role %s is limited to a reporting tree and cannot be given to an API key", input.Role)}
+	}
+	if len(input.Scopes) == 0 {
+		return nil, &ValidationError{errors.New("scopes must list at least one permission")}
+	}
+	for _, scope := range input.Scopes {
+		if !policy.IsPermission(scope) {
+			return nil, &ValidationError{fmt.Errorf("unknown permission %q", scope)}
+		}
+		if !policy.CurrentGrants.Has(input.Role, scope) {
+			return nil, &ValidationError{fmt.Errorf("role %s does not hold %s", input.Role, scope)}
+		}
+	}
+	allowedIps, err := apikeys.NormalizeIPs(input.AllowedIps)
+	if err != nil {
+		return nil, &ValidationError{err}
+	}
+	rateLimit := apikeys.DefaultRateLimit
+	if input.RateLimit != nil {
+		rateLimit = *input.RateLimit
+	}
+	if rateLimit < 1 || rateLimit > apikeys.MaxRateLimit {
+		return nil, &ValidationError{fmt.Errorf("rateLimit must be between 1 and %d requests a minute", apikeys.MaxRateLimit)}
+	}
+	now := time.Now().UTC()
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(now) {
+		return nil, &ValidationError{errors.New("expiresAt must be in the future")}
+	}
+
+	key, hash, prefix, err := apikeys.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	record := schema.APIKey{Name: input.Name, Prefix: prefix, KeyHash: hash, Role: input.Role, Scopes: input.Scopes,
+		AllowedIps: allowedIps, RateLimit: rateLimit, ExpiresAt: input.ExpiresAt, CreatedBy: actor.Username, CreatedAt: now}
+	keyId, err := store.Create(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("API key %s created with role %s and scopes %v by %s", input.Name, input.Role, input.Scopes, actor.Username)
+
+	created, err := store.Get(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+	return &schema.CreatedAPIKey{APIKey: *created, Key: key}, nil
+}
+
+// RevokeAPIKey stops a key from working at once; revoking a revoked key does nothing.
+// The record stays for the audit trail.
+func RevokeAPIKey(ctx context.Context, store apikeys.Store, keyId int, actor schema.Actor) error {
+	key, err := store.Get(ctx, keyId)
+	if err != nil || key.RevokedAt != nil {
+		return err
+	}
+	if err := store.Revoke(ctx, keyId); err != nil {
+		return err
+	}
+	log.Printf("API key %s revoked by %s", key.Name, actor.Username)
+	return nil
+}
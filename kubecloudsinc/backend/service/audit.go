@@ -0,0 +1,48 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// maxAuditEntries bounds a single audit log query.
+const maxAuditEntries = 1000
+
+// QueryAuditLog returns the newest audit entries matching filter. Limit defaults to
+// 100 entries.
+func QueryAuditLog(txn *newrelic.Transaction, filter dbs.AuditFilter, limit int) ([]schema.AuditEntry, error) {
+	if filter.Field != "" && !dbs.IsAuditField(filter.Field) {
+		return nil, &ValidationError{fmt.Errorf("unknown field %q", filter.Field)}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, &ValidationError{errors.New("from must be before to")}
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	if limit < 1 || limit > maxAuditEntries {
+		return nil, &ValidationError{fmt.Errorf("limit must be between 1 and %d", maxAuditEntries)}
+	}
+	return dbs.QueryAuditLog(txn, dbs.DB, filter, limit)
+}
+
+// ParseAuditTime accepts an RFC 3339 timestamp or a date. A date used as the end of a
+// range includes that whole day.
+func ParseAuditTime(value string, end bool) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, &ValidationError{fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC 3339", value)}
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
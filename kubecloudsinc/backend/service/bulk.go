@@ -0,0 +1,137 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"errors"
+	"fmt"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// maxBulkUpdate bounds the number of employees a single bulk update may touch.
+const maxBulkUpdate = 500
+
+// BulkUpdateEmployees applies partial changes to many employees in one transaction.
+// Changes are checked against the same role rules as UpdateEmployee before anything
+// is read, then merged into each employee's current record, so fields that are not
+// part of the change keep their stored values. Employees outside the actor's scope
+// are not updated, whether listed or matched by the filter.
+func BulkUpdateEmployees(txn *newrelic.Transaction, req *schema.BulkUpdateRequest, actor schema.Actor) (*schema.BulkUpdateResponse, error) {
+	hasItems := len(req.Items) > 0
+	hasFilter := req.Filter != nil || req.Changes != nil
+	if hasItems == hasFilter {
+		return nil, &ValidationError{errors.New("send either items, or filter with changes")}
+	}
+	scope, err := scopeFor(actor, policy.Update)
+	if err != nil {
+		return nil, err
+	}
+
+	var employeeIds []int
+	var filter dbs.EmployeeFilter
+	changesById := make(map[int]schema.Employee)
+	if hasItems {
+		if len(req.Items) > maxBulkUpdate {
+			return nil, &ValidationError{fmt.Errorf("at most %d employees can be updated at once", maxBulkUpdate)}
+		}
+		for _, item := range req.Items {
+			if item.EmployeeId <= 0 {
+				return nil, &ValidationError{errors.New("every item needs an employeeId")}
+			}
+			if _, exists := changesById[item.EmployeeId]; exists {
+				return nil, &ValidationError{fmt.Errorf("employee %d is listed more than once", item.EmployeeId)}
+			}
+			changes := item.Changes
+			if err := checkChanges(txn, &changes, actor); err != nil {
+				return nil, err
+			}
+			changesById[item.EmployeeId] = changes
+			employeeIds = append(employeeIds, item.EmployeeId)
+		}
+	} else {
+		if req.Filter == nil || req.Changes == nil {
+			return nil, &ValidationError{errors.New("filter and changes are both required")}
+		}
+		if req.Filter.DepartmentId != nil {
+			filter.DepartmentId = *req.Filter.DepartmentId
+		}
+		if req.Filter.ManagerId != nil {
+			filter.ManagerId = *req.Filter.ManagerId
+		}
+		if req.Filter.JobId != nil {
+			filter.JobId = *req.Filter.JobId
+		}
+		if filter.IsZero() {
+			return nil, &ValidationError{errors.New("filter must set departmentId, managerId or jobId")}
+		}
+		if err := checkChanges(txn, req.Changes, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	filter.Scope = scope
+
+	apply := func(before schema.Employee) (dbs.Employees, error) {
+		changes, ok := changesById[*before.EmployeeId]
+		if !ok {
+			changes = *req.Changes
+		}
+		return mergeChanges(before, changes), nil
+	}
+
+	resp, err := dbs.BulkUpdateEmployees(txn, dbs.DB, employeeIds, filter, maxBulkUpdate, req.DryRun, actor, apply)
+	if errors.Is(err, dbs.ErrBulkLimitExceeded) {
+		return nil, &ValidationError{err}
+	}
+	return resp, err
+}
+
+// checkChanges validates a change set and checks it against the write policy and, for
+// a new manager, the actor's scope.
+func checkChanges(txn *newrelic.Transaction, changes *schema.Employee, actor schema.Actor) error {
+	if err := validateChanges(changes); err != nil {
+		return &ValidationError{err}
+	}
+	if err := checkWritePolicy(actor, policy.Update, changes); err != nil {
+		return err
+	}
+	return checkManagerScope(txn, actor, policy.Update, changes.ManagerId)
+}
+
+// mergeChanges overlays the set fields of changes on the stored record. The hire date
+// is only written by the update path when set, so it is carried over from changes
+// alone rather than re-sending the stored value.
+func mergeChanges(before, changes schema.Employee) dbs.Employees {
+	merged := before
+	merged.HireDate = changes.HireDate
+	if changes.FirstName != nil {
+		merged.FirstName = changes.FirstName
+	}
+	if changes.LastName != nil {
+		merged.LastName = changes.LastName
+	}
+	if changes.Email != nil {
+		merged.Email = changes.Email
+	}
+	if changes.Phone != nil {
+		merged.Phone = changes.Phone
+	}
+	if changes.JobId != nil {
+		merged.JobId = changes.JobId
+	}
+	if changes.Salary != nil {
+		merged.Salary = changes.Salary
+	}
+	if changes.CommissionPct != nil {
+		merged.CommissionPct = changes.CommissionPct
+	}
+	if changes.ManagerId != nil {
+		merged.ManagerId = changes.ManagerId
+	}
+	if changes.DepartmentId != nil {
+		merged.DepartmentId = changes.DepartmentId
+	}
+	return dbs.Employees(merged)
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// ListChangeRequests lists change requests with the given status, or all of them when
+// status is empty, optionally for one employee.
+func ListChangeRequests(txn *newrelic.Transaction, status string, employeeId int) ([]schema.ChangeRequest, error) {
+	switch status {
+	case "", schema.ChangeRequestPending, schema.ChangeRequestApproved, schema.ChangeRequestRejected:
+	default:
+		return nil, &ValidationError{fmt.Errorf("invalid status %q", status)}
+	}
+	return dbs.QueryChangeRequests(txn, dbs.DB, status, employeeId)
+}
+
+// GetChangeRequest returns a change request. Callers who may not review change
+// requests only see their own.
+func GetChangeRequest(txn *newrelic.Transaction, changeRequestId int, actor schema.Actor) (*schema.ChangeRequest, error) {
+	request, err := dbs.GetChangeRequest(txn, dbs.DB, changeRequestId)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.CurrentGrants.Has(actor.Role, policy.ChangeRequestReview) && request.RequestedBy.Username != actor.Username {
+		return nil, dbs.ErrChangeRequestNotFound
+	}
+	return request, nil
+}
+
+// ReviewChangeRequest approves or rejects a pending change request. Only approval
+// applies the update; a rejection needs a comment explaining why.
+func ReviewChangeRequest(txn *newrelic.Transaction, changeRequestId int, approve bool, review *schema.ChangeRequestReview, actor schema.Actor) (*schema.ChangeRequest, error) {
+	comment := review.Comment
+	if comment != nil && *comment == "" {
+		comment = nil
+	}
+	if comment != nil && len(*comment) > 2000 {
+		return nil, &ValidationError{errors.New("comment cannot be longer than 2000 characters")}
+	}
+	if approve {
+		// The reviewer must be allowed to make the update directly
+		request, err := dbs.GetChangeRequest(txn, dbs.DB, changeRequestId)
+		if err != nil {
+			return nil, err
+		}
+		if violations := policy.Current.CheckFields(actor.Role, policy.Update, request.ChangedFields); len(violations) > 0 {
+			return nil, &PermissionError{Violations: violations}
+		}
+		if err := checkEmployeeScope(txn, actor, policy.Update, request.EmployeeId); err != nil {
+			return nil, err
+		}
+		if slices.Contains(request.ChangedFields, "managerId") {
+			if err := checkManagerScope(txn, actor, policy.Update, request.Changes.ManagerId); err != nil {
+				return nil, err
+			}
+		}
+		apply := func(before schema.Employee, request schema.ChangeRequest) (dbs.Employees, error) {
+			return mergeChangeSet(before, request.Changes, request.ChangedFields), nil
+		}
+		return dbs.ApproveChangeRequest(txn, dbs.DB, changeRequestId, comment, actor, apply)
+	}
+	if comment == nil {
+		return nil, &ValidationError{errors.New("a comment is required to reject a change request")}
+	}
+	return dbs.RejectChangeRequest(txn, dbs.DB, changeRequestId, comment, actor)
+}
+
+// changeSet returns the values emp gives fields, with every other field unset.
+func changeSet(emp *schema.Employee, fields []string) schema.Employee {
+	var changes schema.Employee
+	copyFields(&changes, emp, fields)
+	return changes
+}
+
+// mergeChangeSet merges a change set into the stored record like mergeChanges, and
+// clears the fields that are listed but unset in changes.
+func mergeChangeSet(before, changes schema.Employee, fields []string) dbs.Employees {
+	merged := schema.Employee(mergeChanges(before, changes))
+	copyFields(&merged, &changes, fields)
+	return dbs.Employees(merged)
+}
+
+// copyFields sets the fields of dst named by their JSON names to their value in src.
+func copyFields(dst, src *schema.Employee, fields []string) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		name, _, _ := strings.Cut(d.Type().Field(i).Tag.Get("json"), ",")
+		if slices.Contains(fields, name) {
+			d.Field(i).Set(s.Field(i))
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// The service package holds the employee business rules shared by the REST
+// handlers and the gRPC server: input validation, role checks and the calls into
+// the dbs package. Transports only translate requests and map errors to status codes.
+
+// ValidationError reports invalid input; transports map it to 400/InvalidArgument.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// PermissionError reports what the write policy refused the caller's role; transports
+// map it to 403/PermissionDenied and include the violations.
+type PermissionError struct {
+	Violations []schema.FieldViolation
+}
+
+func (e *PermissionError) Error() string {
+	if len(e.Violations) == 1 && e.Violations[0].Field == "" {
+		return fmt.Sprintf("You don't have enough permissions to %s employees", e.Violations[0].Operation)
+	}
+	fields := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		fields[i] = v.Field
+	}
+	return fmt.Sprintf("You don't have enough permissions to %s these fields: %s", e.Violations[0].Operation, strings.Join(fields, ", "))
+}
+
+// FieldViolations lets transports report the violations without depending on this type.
+func (e *PermissionError) FieldViolations() []schema.FieldViolation { return e.Violations }
+
+// ListEmployees lists the employees actor may read.
+func ListEmployees(txn *newrelic.Transaction, actor schema.Actor) ([]dbs.Employees, error) {
+	scope, err := ReadScope(actor)
+	if err != nil {
+		return nil, err
+	}
+	return dbs.QueryEmployees(txn, dbs.DB, scope)
+}
+
+// FindEmployees looks employees up by ID, by (partial) last name, or both, among the
+// employees actor may read.
+func FindEmployees(txn *newrelic.Transaction, employeeId int, lastName string, actor schema.Actor) ([]dbs.Employees, error) {
+	log.Printf("Fetching employee with ID: %d and lastName: %s", employeeId, lastName)
+	scope, err := ReadScope(actor)
+	if err != nil {
+		return nil, err
+	}
+	return dbs.QueryEmployee(txn, dbs.DB, employeeId, lastName, scope)
+}
+
+func GetEmployeeProfile(txn *newrelic.Transaction, employeeId int, actor schema.Actor) (*dbs.EmployeeProfile, error) {
+	log.Printf("Attempting to get employee profile with ID: %d", employeeId)
+	if err := checkEmployeeScope(txn, actor, readOperation, employeeId); err != nil {
+		return nil, err
+	}
+	return dbs.GetEmployeeProfile(txn, dbs.DB, employeeId)
+}
+
+// CreateEmployee validates and normalizes emp in place, then inserts it.
+func CreateEmployee(txn *newrelic.Transaction, emp *schema.Employee, actor schema.Actor) (int, error) {
+	if err := validateAddEmployeeInput(emp); err != nil {
+		return 0, &ValidationError{err}
+	}
+	if err := checkWritePolicy(actor, policy.Create, emp); err != nil {
+		return 0, err
+	}
+
+	employeeId, err := dbs.InsertEmployee(txn, dbs.DB, dbs.Employees(*emp), actor)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("Employee added with ID: %d", employeeId)
+	return employeeId, nil
+}
+
+// UpdateEmployee validates emp and replaces the stored employee. The write policy
+// judges the fields whose value the update changes on the locked row, so a concurrent
+// write cannot slip a field past it. When it changes fields the actor's role may only
+// change with approval, nothing is changed yet: the changes are stored as a change
+// request for an admin to review, which is returned instead. Fields the role may not
+// change at all are refused.
+func UpdateEmployee(txn *newrelic.Transaction, employeeId int, emp *schema.Employee, actor schema.Actor) (*schema.ChangeRequestAccepted, error) {
+	if err := validateUpdateEmployeeInput(emp); err != nil {
+		return nil, &ValidationError{err}
+	}
+	if err := checkEmployeeScope(txn, actor, policy.Update, employeeId); err != nil {
+		return nil, err
+	}
+
+	var changes schema.Employee
+	var changed, restrictedFields []string
+	check := func(before schema.Employee) (bool, error) {
+		changed = changedFields(&before, emp)
+		if slices.Contains(changed, "managerId") {
+			if err := checkManagerScope(txn, actor, policy.Update, emp.ManagerId); err != nil {
+				return false, err
+			}
+		}
+		violations := policy.Current.CheckFields(actor.Role, policy.Update, changed)
+		for _, v := range violations {
+			if v.Reason != policy.ReasonRequiresApproval {
+				return false, &PermissionError{Violations: violations}
+			}
+			restrictedFields = append(restrictedFields, v.Field)
+		}
+		changes = changeSet(emp, changed)
+		return len(restrictedFields) == 0, nil
+	}
+	if err := dbs.UpdateEmployeeDB(txn, dbs.DB, employeeId, dbs.Employees(*emp), actor, check); err != nil {
+		log.Printf("Error updating employee with ID %d: %v", employeeId, err)
+		return nil, err
+	}
+	if len(restrictedFields) == 0 {
+		log.Printf("Employee with ID %d successfully updated", employeeId)
+		return nil, nil
+	}
+
+	log.Printf("Update of employee %d by %s touches %s, holding it for approval", employeeId, actor.Username, strings.Join(restrictedFields, ", "))
+	changeRequestId, err := dbs.InsertChangeRequest(txn, dbs.DB, employeeId, changes, changed, restrictedFields, actor)
+	if err != nil {
+		return nil, err
+	}
+	return &schema.ChangeRequestAccepted{
+		ChangeRequestId:  changeRequestId,
+		Status:           schema.ChangeRequestPending,
+		RestrictedFields: restrictedFields,
+		Message:          fmt.Sprintf("Update of employee %d is waiting for admin approval", employeeId),
+	}, nil
+}
+
+func DeleteEmployee(txn *newrelic.Transaction, employeeId int, actor schema.Actor) error {
+	log.Printf("Attempting to delete employee with ID: %d", employeeId)
+	if err := checkWritePolicy(actor, policy.Delete, nil); err != nil {
+		return err
+	}
+	if err := checkEmployeeScope(txn, actor, policy.Delete, employeeId); err != nil {
+		return err
+	}
+	if err := dbs.DeleteEmployeeByID(txn, dbs.DB, employeeId, actor); err != nil {
+		log.Printf("Error deleting employee with ID %d: %v", employeeId, err)
+		return err
+	}
+	log.Printf("Employee with ID %d successfully deleted", employeeId)
+	return nil
+}
+
+// ListEmployeesProjected lists the employees actor may read with only the requested
+// fields and expansions, or finds them when employeeId or lastName is set.
+func ListEmployeesProjected(txn *newrelic.Transaction, employeeId int, lastName string, fields, expand []string, actor schema.Actor) ([]dbs.Record, error) {
+	projection, err := dbs.NewEmployeeProjection(fields, expand)
+	if err != nil {
+		return nil, &ValidationError{err}
+	}
+	scope, err := ReadScope(actor)
+	if err != nil {
+		return nil, err
+	}
+	return dbs.QueryEmployeesProjected(txn, dbs.DB, employeeId, lastName, scope, projection)
+}
+
+// GetEmployeeProfileProjected returns the requested profile fields and only the
+// expanded parts of job_details.
+func GetEmployeeProfileProjected(txn *newrelic.Transaction, employeeId int, fields, expand []string, actor schema.Actor) (dbs.Record, error) {
+	projection, err := dbs.NewProfileProjection(fields, expand)
+	if err != nil {
+		return nil, &ValidationError{err}
+	}
+	log.Printf("Attempting to get projected employee profile with ID: %d", employeeId)
+	if err := checkEmployeeScope(txn, actor, readOperation, employeeId); err != nil {
+		return nil, err
+	}
+	return dbs.GetEmployeeProfileProjected(txn, dbs.DB, employeeId, projection)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// GetEmployeeAsOf reconstructs an employee as of asOf, an RFC 3339 timestamp or a
+// date. A date means the end of that day, so the record includes its changes. Scoped
+// actors only reach employees currently in their scope.
+func GetEmployeeAsOf(txn *newrelic.Transaction, employeeId int, asOf string, actor schema.Actor) (*schema.EmployeeAsOf, error) {
+	cutoff, err := ParseAuditTime(asOf, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEmployeeScope(txn, actor, readOperation, employeeId); err != nil {
+		return nil, err
+	}
+	emp, err := dbs.GetEmployeeAsOf(txn, dbs.DB, employeeId, *cutoff)
+	if err != nil {
+		return nil, err
+	}
+	emp.AsOf = asOf
+	return emp, nil
+}
+
+// GetEmployeeTimeline returns an employee's history in chronological order: the hire,
+// job changes and audited mutations. Scoped actors only reach employees currently in
+// their scope, and then see the whole timeline, including the time before.
+func GetEmployeeTimeline(txn *newrelic.Transaction, employeeId int, actor schema.Actor) ([]schema.TimelineEvent, error) {
+	if err := checkEmployeeScope(txn, actor, readOperation, employeeId); err != nil {
+		return nil, err
+	}
+	return dbs.QueryEmployeeTimeline(txn, dbs.DB, employeeId)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/mfa"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrInvalidMFACode is returned for wrong, reused and unknown codes alike.
+var ErrInvalidMFACode = errors.New("invalid MFA code")
+
+// ErrMFARequired is returned when turning off MFA that the user's role requires.
+var ErrMFARequired = errors.New("MFA is required for your role and cannot be turned off")
+
+// GetMFAStatus returns whether a user has MFA and whether their role requires it.
+func GetMFAStatus(ctx context.Context, store mfa.Store, user *schema.User) (*schema.UserMFA, error) {
+	status, err := store.Get(ctx, user.UserId)
+	if errors.Is(err, dbs.ErrMFANotFound) {
+		status = &schema.UserMFA{UserId: user.UserId}
+	} else if err != nil {
+		return nil, err
+	}
+	// A pending enrollment is not MFA yet
+	if !status.Enabled {
+		status.RecoveryCodesLeft = 0
+	}
+	status.Required = policy.CurrentMFA.Requires(user.Role)
+	return status, nil
+}
+
+// BeginMFAEnrollment gives a user a new TOTP secret, which takes effect once
+// ConfirmMFAEnrollment checks a code of it. Starting again replaces the secret. Users
+// who sign in through OIDC never see the challenge, so they cannot enroll.
+func BeginMFAEnrollment(ctx context.Context, store mfa.Store, user *schema.User) (*schema.MFAEnrollment, error) {
+	if user.OidcSubject != nil {
+		return nil, &ValidationError{errors.New("single sign-on users set up MFA at their identity provider")}
+	}
+	secret, err := mfa.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SetSecret(ctx, user.UserId, secret); err != nil {
+		return nil, err
+	}
+	return &schema.MFAEnrollment{
+		Secret:     secret,
+		OtpauthUri: mfa.ProvisioningURI(policy.CurrentMFA.Issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment enables the second factor of a user once code matches the new
+// secret, and returns the recovery codes, which are not shown again.
+func ConfirmMFAEnrollment(ctx context.Context, store mfa.Store, user *schema.User, code string) (*schema.RecoveryCodes, error) {
+	if code == "" {
+		return nil, &ValidationError{errors.New("code is required")}
+	}
+	pending, err := store.Get(ctx, user.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if pending.Enabled {
+		return nil, dbs.ErrMFAEnabled
+	}
+	step, ok := mfa.Verify(pending.Secret, code, time.Now(), policy.CurrentMFA.SkewSteps)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	codes, hashes, err := mfa.NewRecoveryCodes(policy.CurrentMFA.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Enable(ctx, user.UserId, step, hashes); err != nil {
+		return nil, err
+	}
+	log.Printf("User %s enabled MFA", user.Username)
+	return &schema.RecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// VerifyMFA checks the second factor of a user: a code of their authenticator app,
+// which works once, or an unused recovery code, which is spent.
+func VerifyMFA(ctx context.Context, store mfa.Store, user *schema.User, input schema.MFACode) error {
+	factor, err := store.Get(ctx, user.UserId)
+	if errors.Is(err, dbs.ErrMFANotFound) || (err == nil && !factor.Enabled) {
+		return ErrInvalidMFACode
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case input.Code != "":
+		step, ok := mfa.Verify(factor.Secret, input.Code, time.Now(), policy.CurrentMFA.SkewSteps)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		err = store.UseStep(ctx, user.UserId, step)
+		if errors.Is(err, dbs.ErrMFACodeUsed) {
+			return ErrInvalidMFACode
+		}
+		return err
+	case input.RecoveryCode != "":
+		err = store.UseRecoveryCode(ctx, user.UserId, mfa.HashRecoveryCode(input.RecoveryCode))
+		if errors.Is(err, dbs.ErrRecoveryCodeInvalid) {
+			return ErrInvalidMFACode
+		}
+		if err == nil {
+			log.Printf("User %s used a recovery code", user.Username)
+		}
+		return err
+	default:
+		return &ValidationError{errors.New("code or recoveryCode is required")}
+	}
+}
+
+// DisableMFA turns off the caller's second factor after checking it, unless their
+// role requires MFA.
+func DisableMFA(ctx context.Context, store mfa.Store, user *schema.User, input schema.MFACode) error {
+	if policy.CurrentMFA.Requires(user.Role) {
+		return ErrMFARequired
+	}
+	if err := VerifyMFA(ctx, store, user, input); err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, user.UserId); err != nil {
+		return err
+	}
+	log.Printf("User %s turned off MFA", user.Username)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking a code
+// of their authenticator app.
+func RegenerateRecoveryCodes(ctx context.Context, store mfa.Store, user *schema.User, input schema.MFACode) (*schema.RecoveryCodes, error) {
+	if input.Code == "" {
+		return nil, &ValidationError{errors.New("code is required")}
+	}
+	if err := VerifyMFA(ctx, store, user, schema.MFACode{Code: input.Code}); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := mfa.NewRecoveryCodes(policy.CurrentMFA.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.ReplaceRecoveryCodes(ctx, user.UserId, hashes); err != nil {
+		return nil, err
+	}
+	log.Printf("User %s replaced their recovery codes", user.Username)
+	return &schema.RecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// ResetMFA removes the second factor of a user other than the caller, for a lost
+// device, and ends their sessions. A role that requires MFA enrolls again at the next
+// login.
+func ResetMFA(ctx context.Context, store users.Store, mfaStore mfa.Store, sessionStore sessions.Store, userId int, actor schema.Actor) error {
+	user, err := store.Get(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.Username == actor.Username {
+		return &ValidationError{errors.New("you cannot reset your own MFA")}
+	}
+	if err := mfaStore.Delete(ctx, userId); err != nil {
+		return err
+	}
+	if err := sessionStore.RevokeUser(ctx, userId, schema.SessionRevokedMFAReset); err != nil {
+		return err
+	}
+	log.Printf("MFA of user %s reset by %s", user.Username, actor.Username)
+	return nil
+}
+
+// OwnUser returns the user the caller is logged in as.
+func OwnUser(ctx context.Context, store users.Store, actor schema.Actor) (*schema.User, error) {
+	user, err := store.GetByUsername(ctx, actor.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, users.ErrUserDisabled
+	}
+	return user, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/oidc"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"context"
+	"errors"
+	"log"
+)
+
+// ErrNoMappedRole is returned when none of the user's groups is mapped to a role.
+var ErrNoMappedRole = errors.New("none of your groups grants access to this service")
+
+// SignInWithOIDC returns the user the provider vouched for, creating them on their
+// first sign-in. Users are found by subject; a new user takes the username claim and
+// fails with dbs.ErrUserExists when a local user already has it. The groups decide
+// the role at every sign-in, and a changed role ends the user's earlier sessions.
+func SignInWithOIDC(ctx context.Context, store users.Store, sessionStore sessions.Store, config *oidc.Config, identity *oidc.Identity) (*schema.User, error) {
+	role, ok := config.RoleFor(identity.Groups)
+	if !ok {
+		log.Printf("OIDC sign-in refused for subject %s: no mapped group in %v", identity.Subject, identity.Groups)
+		return nil, ErrNoMappedRole
+	}
+
+	user, err := store.GetBySubject(ctx, identity.Subject)
+	if errors.Is(err, dbs.ErrUserNotFound) {
+		if !validUsername.MatchString(identity.Username) {
+			return nil, &ValidationError{errors.New("the provider's username claim is missing or not a valid username")}
+		}
+		subject := identity.Subject
+		userId, err := store.Create(ctx, schema.User{Username: identity.Username, Role: role, PasswordHash: users.NoPassword, OidcSubject: &subject})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("User %s created with role %s by OIDC sign-in", identity.Username, role)
+		return store.Get(ctx, userId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, users.ErrUserDisabled
+	}
+
+	if user.Role != role {
+		log.Printf("Role of user %s changed from %s to %s by their groups", user.Username, user.Role, role)
+		user.Role = role
+		if err := store.Update(ctx, *user); err != nil {
+			return nil, err
+		}
+		if err := sessionStore.RevokeUser(ctx, user.UserId, schema.SessionRevokedUserChanged); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// SchedulePendingChange stores a partial change to be applied on its effective date.
+// The same role and scope rules as UpdateEmployee apply when the change is submitted;
+// the scheduler later applies it on behalf of the submitter.
+func SchedulePendingChange(txn *newrelic.Transaction, employeeId int, req *schema.PendingChangeRequest, actor schema.Actor) (int, error) {
+	if req.EffectiveDate == nil || *req.EffectiveDate == "" {
+		return 0, &ValidationError{errors.New("effective date is required")}
+	}
+	// Whether the date is in the past is decided by the database, whose date the
+	// scheduler compares with
+	if _, err := time.Parse("2006-01-02", *req.EffectiveDate); err != nil {
+		return 0, &ValidationError{fmt.Errorf("invalid date format for effective date: %v", err)}
+	}
+	if req.Changes == nil {
+		return 0, &ValidationError{errors.New("changes are required")}
+	}
+	if err := checkEmployeeScope(txn, actor, policy.Update, employeeId); err != nil {
+		return 0, err
+	}
+	if err := checkChanges(txn, req.Changes, actor); err != nil {
+		return 0, err
+	}
+
+	changeId, err := dbs.InsertPendingChange(txn, dbs.DB, employeeId, *req.EffectiveDate, *req.Changes, actor)
+	if errors.Is(err, dbs.ErrEffectiveDateInPast) {
+		return 0, &ValidationError{err}
+	}
+	return changeId, err
+}
+
+// ListPendingChanges lists an employee's scheduled changes with the given status, or
+// all of them when status is empty.
+func ListPendingChanges(txn *newrelic.Transaction, employeeId int, status string, actor schema.Actor) ([]schema.PendingChange, error) {
+	switch status {
+	case "", schema.PendingChangePending, schema.PendingChangeApplied, schema.PendingChangeCancelled, schema.PendingChangeFailed:
+	default:
+		return nil, &ValidationError{fmt.Errorf("invalid status %q", status)}
+	}
+	if err := checkEmployeeScope(txn, actor, readOperation, employeeId); err != nil {
+		return nil, err
+	}
+	return dbs.QueryPendingChanges(txn, dbs.DB, employeeId, status)
+}
+
+// CancelPendingChange cancels a change that has not been applied yet. Callers may
+// only cancel changes they would be allowed to submit.
+func CancelPendingChange(txn *newrelic.Transaction, employeeId, changeId int, actor schema.Actor) error {
+	if err := checkEmployeeScope(txn, actor, policy.Update, employeeId); err != nil {
+		return err
+	}
+	change, err := dbs.GetPendingChange(txn, dbs.DB, employeeId, changeId)
+	if err != nil {
+		return err
+	}
+	if err := checkWritePolicy(actor, policy.Update, &change.Changes); err != nil {
+		return err
+	}
+	return dbs.CancelPendingChange(txn, dbs.DB, employeeId, changeId, actor)
+}
+
+// PendingChangeScheduler applies pending changes once their effective date is reached.
+// Several instances may run against the same database; each change is applied once.
+type PendingChangeScheduler struct {
+	DB        *sql.DB
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Run applies due changes until ctx is cancelled. A full batch is followed immediately
+// by another run so a backlog, for example after downtime, drains without waiting.
+func (s *PendingChangeScheduler) Run(ctx context.Context) {
+	log.Printf("Pending change scheduler started, polling every %s", s.Interval)
+	for {
+		applied, err := dbs.ApplyDuePendingChanges(s.DB, s.BatchSize, func(before, changes schema.Employee) (dbs.Employees, error) {
+			return mergeChanges(before, changes), nil
+		})
+		if err != nil {
+			log.Printf("Pending change scheduler error: %v", err)
+		}
+		if applied > 0 {
+			log.Printf("Pending change scheduler applied %d change(s)", applied)
+		}
+		if err == nil && applied == s.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Pending change scheduler stopped")
+			return
+		case <-time.After(s.Interval):
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"errors"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// readOperation names reads in the violations of scoped roles; the write policy only
+// has write operations.
+const readOperation = "read"
+
+// ReadScope returns the employees actor may read. Roles scoped to their reports reach
+// the actor's own record and their reporting tree, so the token must link the actor
+// to an employee.
+func ReadScope(actor schema.Actor) (dbs.Scope, error) {
+	return scopeFor(actor, readOperation)
+}
+
+// scopeFor returns the employees actor may reach in operation. Scoped roles may read
+// their own record but not write it, which would let a manager move themselves.
+func scopeFor(actor schema.Actor, operation string) (dbs.Scope, error) {
+	if policy.CurrentGrants.Scope(actor.Role) == "" {
+		return dbs.Scope{}, nil
+	}
+	if actor.EmployeeId == 0 {
+		return dbs.Scope{}, &PermissionError{Violations: []schema.FieldViolation{{Operation: operation, Role: actor.Role, Reason: policy.ReasonOutOfScope}}}
+	}
+	return dbs.Scope{Root: actor.EmployeeId, ReportsOnly: operation != readOperation}, nil
+}
+
+// checkEmployeeScope returns dbs.ErrEmployeeNotFound when employeeId is outside what
+// actor may reach in operation, so scoped callers cannot probe for other employees.
+func checkEmployeeScope(txn *newrelic.Transaction, actor schema.Actor, operation string, employeeId int) error {
+	scope, err := scopeFor(actor, operation)
+	if err != nil || scope.IsZero() {
+		return err
+	}
+	return dbs.EmployeeInScope(txn, dbs.DB, employeeId, scope)
+}
+
+// checkManagerScope refuses to move an employee under a manager that actor cannot
+// read, which would take the employee out of the actor's reporting tree.
+func checkManagerScope(txn *newrelic.Transaction, actor schema.Actor, operation string, managerId *int) error {
+	if managerId == nil {
+		return nil
+	}
+	err := checkEmployeeScope(txn, actor, readOperation, *managerId)
+	if errors.Is(err, dbs.ErrEmployeeNotFound) {
+		return &PermissionError{Violations: []schema.FieldViolation{{Field: "managerId", Operation: operation, Role: actor.Role, Reason: policy.ReasonOutOfScope}}}
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+// maxSecurityEvents bounds a single security event query.
+const maxSecurityEvents = 1000
+
+// QuerySecurityEvents returns the newest security events matching filter. Limit
+// defaults to 100 events.
+func QuerySecurityEvents(ctx context.Context, store loginguard.EventStore, filter dbs.SecurityEventFilter, limit int) ([]schema.SecurityEvent, error) {
+	if filter.Type != "" && !loginguard.IsEventType(filter.Type) {
+		return nil, &ValidationError{fmt.Errorf("unknown event type %q", filter.Type)}
+	}
+	if filter.IpAddress != "" {
+		ip := net.ParseIP(filter.IpAddress)
+		if ip == nil {
+			return nil, &ValidationError{fmt.Errorf("%q is not an IP address", filter.IpAddress)}
+		}
+		filter.IpAddress = ip.String()
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, &ValidationError{errors.New("from must be before to")}
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	if limit < 1 || limit > maxSecurityEvents {
+		return nil, &ValidationError{fmt.Errorf("limit must be between 1 and %d", maxSecurityEvents)}
+	}
+	return store.Query(ctx, filter, limit)
+}
+
+// UnlockLogin lifts the login lockout of a username, an IP address or both, before it
+// runs out.
+func UnlockLogin(ctx context.Context, guard *loginguard.Guard, input schema.LoginUnlock, actor schema.Actor) error {
+	if input.Username == "" && input.IpAddress == "" {
+		return &ValidationError{errors.New("username or ipAddress is required")}
+	}
+	ipAddress := ""
+	if input.IpAddress != "" {
+		ip := net.ParseIP(input.IpAddress)
+		if ip == nil {
+			return &ValidationError{fmt.Errorf("%q is not an IP address", input.IpAddress)}
+		}
+		ipAddress = ip.String()
+	}
+	if err := guard.Unlock(ctx, input.Username, ipAddress, actor.Username); err != nil {
+		return err
+	}
+	log.Printf("Login lockout lifted by %s: username %q, address %q", actor.Username, input.Username, ipAddress)
+	return nil
+}
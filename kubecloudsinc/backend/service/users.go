@@ -0,0 +1,192 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/loginguard"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"autotools-golang-api/kubecloudsinc/backend/sessions"
+	"autotools-golang-api/kubecloudsinc/backend/users"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// validUsername matches the usernames that can be created: letters, digits and
+// . _ @ -, up to the width of app_users.username.
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,100}$`)
+
+// ListUsers returns every user, without password hashes.
+func ListUsers(ctx context.Context, store users.Store) ([]schema.User, error) {
+	return store.List(ctx)
+}
+
+// GetUser returns one user.
+func GetUser(ctx context.Context, store users.Store, userId int) (*schema.User, error) {
+	return store.Get(ctx, userId)
+}
+
+// CreateUser adds a user with a role from the grants and a password that satisfies the
+// password policy, optionally linked to an existing employee.
+func CreateUser(ctx context.Context, store users.Store, input schema.NewUser, actor schema.Actor) (*schema.User, error) {
+	if !validUsername.MatchString(input.Username) {
+		return nil, &ValidationError{errors.New("username must be 1 to 100 letters, digits or . _ @ -")}
+	}
+	if err := checkUserLinks(ctx, input.Role, input.EmployeeId); err != nil {
+		return nil, err
+	}
+	if err := users.ValidatePassword(input.Username, input.Password); err != nil {
+		return nil, &ValidationError{err}
+	}
+	hash, err := users.HashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := store.Create(ctx, schema.User{Username: input.Username, Role: input.Role, EmployeeId: input.EmployeeId, PasswordHash: hash})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("User %s created with role %s by %s", input.Username, input.Role, actor.Username)
+	return store.Get(ctx, userId)
+}
+
+// UpdateUser assigns a role, links or unlinks an employee and disables or enables a
+// user. Callers cannot disable themselves or change their own role, so the last admin
+// cannot lock everyone out by accident. Tokens carry the role and employee link, so
+// the user's sessions end when either changes or the user is disabled.
+func UpdateUser(ctx context.Context, store users.Store, sessionStore sessions.Store, userId int, update schema.UserUpdate, actor schema.Actor) (*schema.User, error) {
+	user, err := store.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUserLinks(ctx, update.Role, update.EmployeeId); err != nil {
+		return nil, err
+	}
+	if user.Username == actor.Username && (update.Disabled || update.Role != user.Role) {
+		return nil, &ValidationError{errors.New("you cannot disable yourself or change your own role")}
+	}
+
+	endSessions := update.Disabled || update.Role != user.Role || !equalValues(update.EmployeeId, user.EmployeeId)
+	user.Role, user.EmployeeId, user.Disabled = update.Role, update.EmployeeId, update.Disabled
+	if err := store.Update(ctx, *user); err != nil {
+		return nil, err
+	}
+	if endSessions {
+		if err := sessionStore.RevokeUser(ctx, userId, schema.SessionRevokedUserChanged); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("User %s updated by %s: role=%s disabled=%t", user.Username, actor.Username, user.Role, user.Disabled)
+	return store.Get(ctx, userId)
+}
+
+// DeleteUser ends the sessions of a user other than the caller and removes the user.
+func DeleteUser(ctx context.Context, store users.Store, sessionStore sessions.Store, userId int, actor schema.Actor) error {
+	user, err := store.Get(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.Username == actor.Username {
+		return &ValidationError{errors.New("you cannot delete yourself")}
+	}
+	if err := sessionStore.RevokeUser(ctx, userId, schema.SessionRevokedUserDeleted); err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, userId); err != nil {
+		return err
+	}
+	log.Printf("User %s deleted by %s", user.Username, actor.Username)
+	return nil
+}
+
+// ResetPassword sets a new password for a user without knowing the old one.
+func ResetPassword(ctx context.Context, store users.Store, sessionStore sessions.Store, userId int, reset schema.PasswordReset, actor schema.Actor) error {
+	user, err := store.Get(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if err := setPassword(ctx, store, sessionStore, user, reset.Password); err != nil {
+		return err
+	}
+	log.Printf("Password of user %s reset by %s", user.Username, actor.Username)
+	return nil
+}
+
+// LockedOutError is returned while failed password checks lock the caller out; the
+// check is not tried until RetryAfter has passed.
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string { return "too many failed password checks, try again later" }
+
+// ChangeOwnPassword replaces the caller's password after checking the current one.
+// The check counts against the same login lockout as /v2/login, so a stolen token
+// cannot be used to guess the password. A wrong current password fails with
+// users.ErrInvalidCredentials, after the delay of the failure.
+func ChangeOwnPassword(ctx context.Context, store users.Store, sessionStore sessions.Store, guard *loginguard.Guard, ip string, change schema.PasswordChange, actor schema.Actor) error {
+	retryAfter, attempts := guard.Attempt(ctx, actor.Username, ip)
+	if retryAfter > 0 {
+		return &LockedOutError{RetryAfter: retryAfter}
+	}
+	user, err := users.Authenticate(ctx, store, actor.Username, change.CurrentPassword)
+	switch {
+	case errors.Is(err, users.ErrInvalidCredentials):
+		loginguard.Wait(ctx, guard.Failed(ctx, actor.Username, ip, "invalid current password", attempts))
+		return err
+	case errors.Is(err, users.ErrUserDisabled):
+		guard.Refused(ctx, actor.Username, ip, "user disabled")
+		return err
+	case err != nil:
+		guard.Cancel(ctx, actor.Username, ip)
+		return err
+	}
+	guard.Succeeded(ctx, actor.Username, ip)
+
+	if change.NewPassword == change.CurrentPassword {
+		return &ValidationError{errors.New("the new password must differ from the current one")}
+	}
+	if err := setPassword(ctx, store, sessionStore, user, change.NewPassword); err != nil {
+		return err
+	}
+	log.Printf("User %s changed their password", user.Username)
+	return nil
+}
+
+// setPassword stores a new password and ends the user's sessions, which may have been
+// started with the old one.
+func setPassword(ctx context.Context, store users.Store, sessionStore sessions.Store, user *schema.User, password string) error {
+	if err := users.ValidatePassword(user.Username, password); err != nil {
+		return &ValidationError{err}
+	}
+	hash, err := users.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := store.SetPassword(ctx, user.UserId, hash); err != nil {
+		return err
+	}
+	return sessionStore.RevokeUser(ctx, user.UserId, schema.SessionRevokedPasswordChanged)
+}
+
+// checkUserLinks checks that role is granted in policy.CurrentGrants and that
+// employeeId, when set, names an existing employee.
+func checkUserLinks(ctx context.Context, role string, employeeId *int) error {
+	if !policy.CurrentGrants.HasRole(role) {
+		return &ValidationError{fmt.Errorf("unknown role %q", role)}
+	}
+	if employeeId == nil {
+		return nil
+	}
+	err := dbs.EmployeeInScope(newrelic.FromContext(ctx), dbs.DB, *employeeId, dbs.Scope{})
+	if errors.Is(err, dbs.ErrEmployeeNotFound) {
+		return &ValidationError{fmt.Errorf("employee %d does not exist", *employeeId)}
+	}
+	return err
+}
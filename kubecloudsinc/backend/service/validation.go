@@ -0,0 +1,237 @@
+package service
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// validateEmployeeInput validates the fields of Employee
+func validateAddEmployeeInput(emp *schema.Employee) error {
+	validJobIDs := validJobIDs()
+	if emp.FirstName == nil || *emp.FirstName == "" {
+		return errors.New("first name is required")
+	}
+	if emp.LastName == nil || *emp.LastName == "" {
+		return errors.New("last name is required")
+	}
+	if emp.Email == nil || *emp.Email == "" {
+		return errors.New("email is required")
+	} else if err := validateEmail(*emp.Email); err != nil {
+		return err
+	}
+	if emp.Phone == nil || *emp.Phone == "" {
+		return errors.New("phone number is required")
+	} else {
+		normalizedPhone, err := normalizePhoneNumber(*emp.Phone)
+		if err != nil {
+			return err
+		}
+		*emp.Phone = normalizedPhone // Update the employee's phone number to the normalized format
+	}
+	if emp.HireDate == nil || *emp.HireDate == "" {
+		return errors.New("hire date is required")
+	} else if _, err := time.Parse("2006-01-02 15:04:05", *emp.HireDate); err != nil {
+		_, err := time.Parse("2006-01-02", *emp.HireDate)
+		if err != nil {
+			return fmt.Errorf("invalid date format for hire date: %v", err)
+		}
+	}
+	if emp.JobId == nil || *emp.JobId == "" {
+		return errors.New("job ID is required")
+	} else if _, exists := validJobIDs[*emp.JobId]; !exists {
+		return fmt.Errorf("invalid job ID: %s", *emp.JobId)
+	}
+	if emp.Salary == nil {
+		return errors.New("salary is required")
+	}
+	return nil
+}
+
+func validateUpdateEmployeeInput(emp *schema.Employee) error {
+	validJobIDs := validJobIDs()
+	if emp.Email != nil && *emp.Email != "" {
+		if err := validateEmail(*emp.Email); err != nil {
+			return err
+		}
+	}
+
+	if emp.Phone != nil && *emp.Phone != "" {
+		normalizedPhone, err := normalizePhoneNumber(*emp.Phone)
+		if err != nil {
+			return err
+		}
+		*emp.Phone = normalizedPhone
+	}
+
+	if emp.HireDate != nil && *emp.HireDate != "" {
+		_, err := time.Parse("2006-01-02 15:04:05", *emp.HireDate)
+		if err != nil {
+			_, err := time.Parse("2006-01-02", *emp.HireDate)
+			if err != nil {
+				return fmt.Errorf("invalid date format for hire date: %v", err)
+			}
+		}
+	}
+
+	if emp.JobId == nil || *emp.JobId == "" {
+		return errors.New("job ID is required")
+	} else if _, exists := validJobIDs[*emp.JobId]; !exists {
+		return fmt.Errorf("invalid job ID: %s", *emp.JobId)
+	}
+
+	return nil
+}
+
+// validateEmail checks if the email address is valid
+func validateEmail(email string) error {
+	// Regular expression to validate email according to the specified rules
+	regexPattern := `^(?:[a-zA-Z0-9]+[_\.-]?)*[a-zA-Z0-9]+@[a-zA-Z0-9-]+(?:\.[a-zA-Z]{2,})+$`
+	match, _ := regexp.MatchString(regexPattern, email)
+	if !match {
+		return errors.New("invalid email format")
+	}
+	return nil
+}
+
+// validatePhone checks if the phone number is valid
+func normalizePhoneNumber(phone string) (string, error) {
+	reg, err := regexp.Compile("[^0-9]+")
+	if err != nil {
+		return "", err
+	}
+	normalizedPhone := reg.ReplaceAllString(phone, "")
+
+	if len(normalizedPhone) != 10 {
+		return "", fmt.Errorf("phone number does not have 10 digits: %s", normalizedPhone)
+	}
+
+	reformattedPhone := fmt.Sprintf("%s.%s.%s", normalizedPhone[0:3], normalizedPhone[3:6], normalizedPhone[6:])
+	return reformattedPhone, nil
+}
+
+func validJobIDs() map[string]bool {
+	return map[string]bool{
+		"AC_MGR":     true,
+		"AC_ACCOUNT": true,
+		"AD_ASST":    true,
+		"AD_PRES":    true,
+		"AD_VP":      true,
+		"FI_ACCOUNT": true,
+		"FI_MGR":     true,
+		"HR_REP":     true,
+		"IT_PROG":    true,
+		"MK_MAN":     true,
+		"MK_REP":     true,
+		"PR_REP":     true,
+		"PU_CLERK":   true,
+		"PU_MAN":     true,
+		"SA_MAN":     true,
+		"SA_REP":     true,
+		"SH_CLERK":   true,
+		"ST_CLERK":   true,
+		"ST_MAN":     true,
+	}
+}
+
+// checkWritePolicy refuses the write when the actor's role may not write every field
+// of emp in operation. Fields that need approval are refused too.
+func checkWritePolicy(actor schema.Actor, operation string, emp *schema.Employee) error {
+	if violations := policy.Current.Check(actor.Role, operation, emp); len(violations) > 0 {
+		return &PermissionError{Violations: violations}
+	}
+	return nil
+}
+
+// changedFields returns the JSON names of the fields the full-record update emp changes
+// on the stored record before. A field left out of emp is cleared by the update, so it
+// changes when a value is stored; the hire date is only written when it is set.
+func changedFields(before, emp *schema.Employee) []string {
+	var fields []string
+	add := func(field string, changed bool) {
+		if changed {
+			fields = append(fields, field)
+		}
+	}
+	add("firstName", !equalValues(before.FirstName, emp.FirstName))
+	add("lastName", !equalValues(before.LastName, emp.LastName))
+	add("email", !equalValues(before.Email, emp.Email))
+	add("phone", !equalValues(before.Phone, emp.Phone))
+	add("hireDate", emp.HireDate != nil && *emp.HireDate != "" && !sameHireDate(before.HireDate, *emp.HireDate))
+	add("jobId", !equalValues(before.JobId, emp.JobId))
+	add("salary", !equalValues(before.Salary, emp.Salary))
+	add("commissionPct", !equalValues(before.CommissionPct, emp.CommissionPct))
+	add("managerId", !equalValues(before.ManagerId, emp.ManagerId))
+	add("departmentId", !equalValues(before.DepartmentId, emp.DepartmentId))
+	return fields
+}
+
+func equalValues[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// sameHireDate compares a stored hire date, read back as RFC 3339, with one in either
+// input format.
+func sameHireDate(stored *string, hireDate string) bool {
+	if stored == nil {
+		return false
+	}
+	before, err := time.Parse(time.RFC3339, *stored)
+	if err != nil {
+		return *stored == hireDate
+	}
+	after, err := time.Parse("2006-01-02 15:04:05", hireDate)
+	if err != nil {
+		after, err = time.Parse("2006-01-02", hireDate)
+	}
+	return err == nil && before.Format("2006-01-02 15:04:05") == after.Format("2006-01-02 15:04:05")
+}
+
+// validateChanges validates the fields set in a partial update and normalizes the
+// phone number. Unset fields are left alone.
+func validateChanges(changes *schema.Employee) error {
+	if changes.EmployeeId != nil {
+		return errors.New("employeeId cannot be changed")
+	}
+	if changes.FirstName != nil && *changes.FirstName == "" {
+		return errors.New("first name cannot be empty")
+	}
+	if changes.LastName != nil && *changes.LastName == "" {
+		return errors.New("last name cannot be empty")
+	}
+	if changes.Email != nil {
+		if err := validateEmail(*changes.Email); err != nil {
+			return err
+		}
+	}
+	if changes.Phone != nil {
+		normalizedPhone, err := normalizePhoneNumber(*changes.Phone)
+		if err != nil {
+			return err
+		}
+		*changes.Phone = normalizedPhone
+	}
+	if changes.HireDate != nil {
+		if _, err := time.Parse("2006-01-02 15:04:05", *changes.HireDate); err != nil {
+			if _, err := time.Parse("2006-01-02", *changes.HireDate); err != nil {
+				return fmt.Errorf("invalid date format for hire date: %v", err)
+			}
+		}
+	}
+	if changes.JobId != nil {
+		if _, exists := validJobIDs()[*changes.JobId]; !exists {
+			return fmt.Errorf("invalid job ID: %s", *changes.JobId)
+		}
+	}
+	if changes.FirstName == nil && changes.LastName == nil && changes.Email == nil && changes.Phone == nil && changes.HireDate == nil &&
+		changes.JobId == nil && changes.Salary == nil && changes.CommissionPct == nil && changes.ManagerId == nil && changes.DepartmentId == nil {
+		return errors.New("changes must set at least one field")
+	}
+	return nil
+}
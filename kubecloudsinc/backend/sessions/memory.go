@@ -0,0 +1,132 @@
+package sessions
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in memory, next to a users.MemoryStore for local
+// development. Its contents are lost on restart, which logs everyone out.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]schema.Session
+	tokens   map[string]schema.RefreshToken
+	revoked  map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]schema.Session),
+		tokens:   make(map[string]schema.RefreshToken),
+		revoked:  make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *MemoryStore) Create(ctx context.Context, session schema.Session, token schema.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.SessionId] = session
+	token.SessionId = session.SessionId
+	s.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *MemoryStore) Rotate(ctx context.Context, tokenHash string, next schema.RefreshToken) (*schema.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return nil, dbs.ErrRefreshTokenInvalid
+	}
+	session := s.sessions[token.SessionId]
+	now := time.Now().UTC()
+	if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return nil, dbs.ErrRefreshTokenInvalid
+	}
+	if token.UsedAt != nil {
+		s.revokeLocked(func(candidate schema.Session) bool { return candidate.SessionId == session.SessionId }, schema.SessionRevokedReuse)
+		log.Printf("Refresh token reused in session %s of user %d; session revoked", session.SessionId, session.UserId)
+		return nil, dbs.ErrRefreshTokenReused
+	}
+	if !now.Before(token.ExpiresAt) {
+		return nil, dbs.ErrRefreshTokenInvalid
+	}
+
+	token.UsedAt = &now
+	s.tokens[tokenHash] = token
+	next.SessionId = session.SessionId
+	s.tokens[next.TokenHash] = next
+	return &session, nil
+}
+
+func (s *MemoryStore) Revoke(ctx context.Context, sessionId, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeLocked(func(session schema.Session) bool { return session.SessionId == sessionId }, reason)
+	return nil
+}
+
+func (s *MemoryStore) RevokeUser(ctx context.Context, userId int, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeLocked(func(session schema.Session) bool { return session.UserId == userId }, reason)
+	return nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	var deleted int64
+	for jti, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, jti)
+			deleted++
+		}
+	}
+	for sessionId, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) || (session.RevokedAt != nil && session.RevokedAt.Before(now.Add(-24*time.Hour))) {
+			delete(s.sessions, sessionId)
+			deleted++
+		}
+	}
+	for hash, token := range s.tokens {
+		_, live := s.sessions[token.SessionId]
+		if !live || (!now.Before(token.ExpiresAt) && !now.Before(token.AccessExpiresAt)) {
+			delete(s.tokens, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// revokeLocked revokes the sessions that match and denies their unexpired access
+// tokens. The caller holds s.mu.
+func (s *MemoryStore) revokeLocked(match func(schema.Session) bool, reason string) {
+	now := time.Now().UTC()
+	for sessionId, session := range s.sessions {
+		if !match(session) {
+			continue
+		}
+		for _, token := range s.tokens {
+			if token.SessionId == sessionId && now.Before(token.AccessExpiresAt) {
+				s.revoked[token.AccessJti] = token.AccessExpiresAt
+			}
+		}
+		if session.RevokedAt == nil {
+			session.RevokedAt, session.RevokeReason = &now, &reason
+			s.sessions[sessionId] = session
+		}
+	}
+}
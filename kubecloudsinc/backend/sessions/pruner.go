@@ -0,0 +1,35 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Pruner deletes expired sessions, refresh tokens and denied access tokens, so the
+// denylist only holds tokens that would otherwise still be accepted.
+type Pruner struct {
+	Store    Store
+	Interval time.Duration
+}
+
+// Run prunes every Interval until ctx is cancelled.
+func (p *Pruner) Run(ctx context.Context) {
+	log.Printf("Session pruner started, running every %s", p.Interval)
+	for {
+		deleted, err := p.Store.Prune(ctx)
+		if err != nil {
+			log.Printf("Session pruner error: %v", err)
+		}
+		if deleted > 0 {
+			log.Printf("Session pruner deleted %d expired record(s)", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Session pruner stopped")
+			return
+		case <-time.After(p.Interval):
+		}
+	}
+}
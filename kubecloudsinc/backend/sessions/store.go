@@ -0,0 +1,65 @@
+// Package sessions keeps login sessions, their rotating refresh tokens and the access
+// tokens that are denied before they expire. Refresh tokens are only ever kept as
+// SHA-256 hashes.
+package sessions
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// Denylist says whether an access token was revoked before its expiry.
+type Denylist interface {
+	// IsRevoked reports whether the access token with the given jti is denied.
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Store keeps sessions. Refreshes fail with dbs.ErrRefreshTokenInvalid and
+// dbs.ErrRefreshTokenReused whatever the implementation.
+type Store interface {
+	Denylist
+	// Create starts session with its first refresh token.
+	Create(ctx context.Context, session schema.Session, token schema.RefreshToken) error
+	// Rotate exchanges the refresh token with hash tokenHash for next, in the same
+	// session, and returns the session. Presenting a token twice revokes its session.
+	Rotate(ctx context.Context, tokenHash string, next schema.RefreshToken) (*schema.Session, error)
+	// Revoke ends a session and denies the access tokens issued in it.
+	Revoke(ctx context.Context, sessionId, reason string) error
+	// RevokeUser ends every session of a user.
+	RevokeUser(ctx context.Context, userId int, reason string) error
+	// Prune deletes what expired and returns how many records it deleted.
+	Prune(ctx context.Context) (int64, error)
+}
+
+// SQLStore keeps sessions in the tables created by migrations/008_sessions.sql.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+func (s *SQLStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return dbs.IsAccessTokenRevoked(newrelic.FromContext(ctx), s.DB, jti)
+}
+
+func (s *SQLStore) Create(ctx context.Context, session schema.Session, token schema.RefreshToken) error {
+	return dbs.InsertSession(newrelic.FromContext(ctx), s.DB, session, token)
+}
+
+func (s *SQLStore) Rotate(ctx context.Context, tokenHash string, next schema.RefreshToken) (*schema.Session, error) {
+	return dbs.RotateRefreshToken(newrelic.FromContext(ctx), s.DB, tokenHash, next)
+}
+
+func (s *SQLStore) Revoke(ctx context.Context, sessionId, reason string) error {
+	return dbs.RevokeSession(newrelic.FromContext(ctx), s.DB, sessionId, reason)
+}
+
+func (s *SQLStore) RevokeUser(ctx context.Context, userId int, reason string) error {
+	return dbs.RevokeUserSessions(newrelic.FromContext(ctx), s.DB, userId, reason)
+}
+
+func (s *SQLStore) Prune(ctx context.Context) (int64, error) {
+	return dbs.PruneSessions(newrelic.FromContext(ctx), s.DB)
+}
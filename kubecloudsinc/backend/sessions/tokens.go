@@ -0,0 +1,59 @@
+package sessions
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// Token lifetimes. An access token is short-lived; the refresh token issued with it
+// lasts RefreshTokenLifetime from its issue, and no refresh extends a session beyond
+// SessionLifetime from the login.
+const (
+	AccessTokenLifetime  = 15 * time.Minute
+	RefreshTokenLifetime = 7 * 24 * time.Hour
+	SessionLifetime      = 30 * 24 * time.Hour
+)
+
+// NewId returns a random 128-bit ID in hex, used for session IDs and jti claims.
+func NewId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewRefreshToken returns a random refresh token and the record to store for it, with
+// the jti and expiry of the access token to issue alongside. The Store fills in the
+// SessionId when it stores the record.
+func NewRefreshToken() (string, schema.RefreshToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", schema.RefreshToken{}, err
+	}
+	jti, err := NewId()
+	if err != nil {
+		return "", schema.RefreshToken{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now().UTC()
+	record := schema.RefreshToken{
+		TokenHash:       HashToken(token),
+		AccessJti:       jti,
+		AccessExpiresAt: now.Add(AccessTokenLifetime),
+		IssuedAt:        now,
+		ExpiresAt:       now.Add(RefreshTokenLifetime),
+	}
+	return token, record, nil
+}
+
+// HashToken returns the SHA-256 hash of a refresh token, in hex. Refresh tokens are
+// random, so a fast hash is enough to keep them useless when the table leaks.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,170 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// JWK is the public part of a key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every verification key, so verifiers keep accepting tokens of the
+// previous signing key during a rotation.
+func (s *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.Keys() {
+		jwk := publicJWK(key.Public)
+		jwk.Kid, jwk.Use, jwk.Alg = key.Id, "sig", key.Alg
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// Key returns the verification key of jwk, for keys published by other issuers. The
+// key must be one this package signs with, and its alg, when given, must match.
+func (jwk JWK) Key() (Key, error) {
+	var public crypto.PublicKey
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return Key{}, fmt.Errorf("invalid n: %v", err)
+		}
+		e, err := decode(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return Key{}, errors.New("invalid e")
+		}
+		public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		if jwk.Crv != elliptic.P256().Params().Name {
+			return Key{}, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, errX := decode(jwk.X)
+		y, errY := decode(jwk.Y)
+		if errX != nil || errY != nil {
+			return Key{}, errors.New("invalid x or y")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return Key{}, errors.New("point is not on the curve")
+		}
+		public = key
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	key, err := newKey(public)
+	if err != nil {
+		return Key{}, err
+	}
+	if jwk.Alg != "" && jwk.Alg != key.Alg {
+		return Key{}, fmt.Errorf("unsupported algorithm %q", jwk.Alg)
+	}
+	if jwk.Kid != "" {
+		key.Id = jwk.Kid
+	}
+	return key, nil
+}
+
+// VerifyWith parses tokenString like KeySet.Parse, with the key named by its kid among
+// keys. A token without a kid may use the only key.
+func VerifyWith(keys map[string]Key, tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	parser := &jwt.Parser{ValidMethods: []string{AlgRS256, AlgES256}}
+	return parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return lookup(keys, token)
+	})
+}
+
+// lookup returns the public key that verifies token: the key named by its kid, used
+// with that key's own algorithm.
+func lookup(keys map[string]Key, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys[kid]
+	if !ok && kid == "" && len(keys) == 1 {
+		for _, only := range keys {
+			key, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Alg {
+		return nil, fmt.Errorf("key %s is not used with %s", kid, token.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// publicJWK returns the key type and key material of a public key.
+func publicJWK(public crypto.PublicKey) JWK {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: encode(k.N.Bytes()), E: encode(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return JWK{Kty: "EC", Crv: k.Curve.Params().Name, X: encode(k.X.FillBytes(make([]byte, size))), Y: encode(k.Y.FillBytes(make([]byte, size)))}
+	}
+	return JWK{}
+}
+
+// thumbprint is the RFC 7638 thumbprint of a public key, used as its kid: the SHA-256
+// of its required members in lexicographic order.
+func thumbprint(public crypto.PublicKey) (string, error) {
+	jwk := publicJWK(public)
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", public)
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return encode(sum[:]), nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,120 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func newTestKeySet(t *testing.T, alg string) *KeySet {
+	t.Helper()
+	var signer crypto.Signer
+	var err error
+	switch alg {
+	case AlgRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, minRSABits)
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := newKeySet(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func testClaims() jwt.StandardClaims {
+	return jwt.StandardClaims{Subject: "mazda", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+}
+
+func TestKeysRoundTripThroughJWKS(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgES256} {
+		t.Run(alg, func(t *testing.T) {
+			set := newTestKeySet(t, alg)
+			token, err := set.Sign(testClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Another service reads the published document
+			data, err := json.Marshal(set.JWKS())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var jwks JWKS
+			if err := json.Unmarshal(data, &jwks); err != nil {
+				t.Fatal(err)
+			}
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Alg != alg || jwks.Keys[0].Kid != set.SigningKeyId() {
+				t.Fatalf("JWKS is %s, want the %s signing key", data, alg)
+			}
+			key, err := jwks.Keys[0].Key()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var claims jwt.StandardClaims
+			if _, err := VerifyWith(map[string]Key{key.Id: key}, token, &claims); err != nil {
+				t.Fatalf("the token does not verify with the published key: %v", err)
+			}
+			if claims.Subject != "mazda" {
+				t.Fatalf("subject is %q, want mazda", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestUnknownKidIsRejected(t *testing.T) {
+	set := newTestKeySet(t, AlgES256)
+	other := newTestKeySet(t, AlgES256)
+	token, err := other.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Parse(token, &jwt.StandardClaims{}); err == nil {
+		t.Fatal("a token of an unknown key was accepted")
+	}
+
+	// Naming a known kid does not help when another key signed it
+	forged := jwt.NewWithClaims(jwt.SigningMethodES256, testClaims())
+	forged.Header["kid"] = set.SigningKeyId()
+	forgedString, err := forged.SignedString(other.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Parse(forgedString, &jwt.StandardClaims{}); err == nil {
+		t.Fatal("a token signed by another key under a known kid was accepted")
+	}
+
+	// Tokens without a kid are refused too
+	unnamed, err := jwt.NewWithClaims(jwt.SigningMethodES256, testClaims()).SignedString(set.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Parse(unnamed, &jwt.StandardClaims{}); err == nil {
+		t.Fatal("a token without a kid was accepted")
+	}
+}
+
+func TestKeyIsUsedWithItsOwnAlgorithm(t *testing.T) {
+	set := newTestKeySet(t, AlgRS256)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, testClaims())
+	token.Header["kid"] = set.SigningKeyId()
+	tokenString, err := token.SignedString(set.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Parse(tokenString, &jwt.StandardClaims{}); err == nil {
+		t.Fatal("an RS512 token was accepted")
+	}
+}
@@ -0,0 +1,214 @@
+// Package signing holds the keys that sign and verify access tokens. Tokens are signed
+// with RS256 or ES256 by one private key and verified with any of several public keys,
+// found by the kid header, so keys can be rotated without invalidating live tokens.
+// The public keys are published as a JWKS for other services.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Signing algorithms. RS256 needs an RSA key of at least 2048 bits, ES256 a P-256 key.
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// minRSABits is the smallest RSA key accepted.
+const minRSABits = 2048
+
+// Key is a public key that verifies tokens carrying its Id as kid.
+type Key struct {
+	Id     string
+	Alg    string
+	Public crypto.PublicKey
+}
+
+// KeySet signs tokens as Issuer for Audience with the signing key and verifies tokens
+// signed by any of its keys.
+type KeySet struct {
+	Issuer   string
+	Audience string
+
+	signer  crypto.Signer
+	signing Key
+	keys    map[string]Key
+}
+
+// Load reads the PEM private key that signs new tokens from signingKeyFile and the PEM
+// keys that also verify tokens from verificationKeyFiles, such as the previous signing
+// key during a rotation. A verification file may hold a public key, a certificate or
+// a private key.
+func Load(signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	data, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %v", err)
+	}
+	signer, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %s: %v", signingKeyFile, err)
+	}
+	set, err := newKeySet(signer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %s: %v", signingKeyFile, err)
+	}
+
+	for _, path := range verificationKeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read verification key: %v", err)
+		}
+		public, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid verification key %s: %v", path, err)
+		}
+		key, err := newKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("invalid verification key %s: %v", path, err)
+		}
+		set.keys[key.Id] = key
+	}
+	return set, nil
+}
+
+// Generate returns a KeySet with a new ES256 key that only lives in memory, for local
+// development. Its tokens stop verifying when the process exits, and other instances
+// cannot verify them.
+func Generate() (*KeySet, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newKeySet(private)
+}
+
+func newKeySet(signer crypto.Signer) (*KeySet, error) {
+	key, err := newKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &KeySet{signer: signer, signing: key, keys: map[string]Key{key.Id: key}}, nil
+}
+
+// newKey picks the algorithm of a public key and derives its kid.
+func newKey(public crypto.PublicKey) (Key, error) {
+	var alg string
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return Key{}, fmt.Errorf("RSA keys must have at least %d bits", minRSABits)
+		}
+		alg = AlgRS256
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return Key{}, errors.New("EC keys must use the P-256 curve")
+		}
+		alg = AlgES256
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", public)
+	}
+	id, err := thumbprint(public)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{Id: id, Alg: alg, Public: public}, nil
+}
+
+// SigningKeyId returns the kid of the key that signs new tokens.
+func (s *KeySet) SigningKeyId() string {
+	return s.signing.Id
+}
+
+// Keys returns the verification keys ordered by kid, the signing key among them.
+func (s *KeySet) Keys() []Key {
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+	return keys
+}
+
+// Sign returns claims as a JWT signed with the signing key, with its kid in the header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(s.signing.Alg), claims)
+	token.Header["kid"] = s.signing.Id
+	return token.SignedString(s.signer)
+}
+
+// Parse verifies the signature of tokenString with the key named by its kid, which
+// must be used with its own algorithm, validates exp, iat and nbf, and decodes the
+// claims. The issuer and audience are left to the caller.
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	parser := &jwt.Parser{ValidMethods: []string{AlgRS256, AlgES256}}
+	return parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Header["kid"].(string); !ok {
+			return nil, errors.New("token has no kid")
+		}
+		return lookup(s.keys, token)
+	})
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not PEM encoded")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not PEM encoded")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		signer, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+}
@@ -0,0 +1,181 @@
+package users
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MemoryStore keeps users in memory, for local development without the users table.
+// Its contents are lost on restart.
+type MemoryStore struct {
+	mu     sync.Mutex
+	users  map[string]schema.User
+	nextId int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[string]schema.User), nextId: 1}
+}
+
+// seedFile is the format of USERS_FILE: users with their bcrypt hashes, which
+// `hash-password` prints.
+type seedFile struct {
+	Users []struct {
+		Username     string `yaml:"username"`
+		PasswordHash string `yaml:"passwordHash"`
+		Role         string `yaml:"role"`
+		EmployeeId   *int   `yaml:"employeeId"`
+		Disabled     bool   `yaml:"disabled"`
+	} `yaml:"users"`
+}
+
+// LoadMemoryStore returns a MemoryStore holding the users in the YAML or JSON file at
+// path.
+func LoadMemoryStore(path string) (*MemoryStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users: %v", err)
+	}
+	var seed seedFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&seed); err != nil {
+		return nil, fmt.Errorf("invalid users file %s: %v", path, err)
+	}
+
+	store := NewMemoryStore()
+	for _, u := range seed.Users {
+		if !IsHash(u.PasswordHash) {
+			return nil, fmt.Errorf("invalid users file %s: user %s: passwordHash is not a bcrypt hash", path, u.Username)
+		}
+		user := schema.User{Username: u.Username, PasswordHash: u.PasswordHash, Role: u.Role, EmployeeId: u.EmployeeId, Disabled: u.Disabled}
+		if _, err := store.Create(context.Background(), user); err != nil {
+			return nil, fmt.Errorf("invalid users file %s: user %s: %v", path, u.Username, err)
+		}
+	}
+	return store, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]schema.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := make([]schema.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, userId int) (*schema.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	username, err := s.usernameOf(userId)
+	if err != nil {
+		return nil, err
+	}
+	user := s.users[username]
+	return &user, nil
+}
+
+func (s *MemoryStore) GetByUsername(ctx context.Context, username string) (*schema.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[username]
+	if !ok {
+		return nil, dbs.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (s *MemoryStore) GetBySubject(ctx context.Context, subject string) (*schema.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.OidcSubject != nil && *user.OidcSubject == subject {
+			return &user, nil
+		}
+	}
+	return nil, dbs.ErrUserNotFound
+}
+
+func (s *MemoryStore) Create(ctx context.Context, user schema.User) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.users[user.Username]; exists {
+		return 0, dbs.ErrUserExists
+	}
+	if user.OidcSubject != nil {
+		for _, other := range s.users {
+			if other.OidcSubject != nil && *other.OidcSubject == *user.OidcSubject {
+				return 0, dbs.ErrUserExists
+			}
+		}
+	}
+	user.UserId = s.nextId
+	user.CreatedAt = time.Now().UTC()
+	user.UpdatedAt = user.CreatedAt
+	user.PasswordChangedAt = user.CreatedAt
+	s.users[user.Username] = user
+	s.nextId++
+	return user.UserId, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, user schema.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	username, err := s.usernameOf(user.UserId)
+	if err != nil {
+		return err
+	}
+	stored := s.users[username]
+	stored.Role, stored.EmployeeId, stored.Disabled = user.Role, user.EmployeeId, user.Disabled
+	stored.UpdatedAt = time.Now().UTC()
+	s.users[username] = stored
+	return nil
+}
+
+func (s *MemoryStore) SetPassword(ctx context.Context, userId int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	username, err := s.usernameOf(userId)
+	if err != nil {
+		return err
+	}
+	stored := s.users[username]
+	stored.PasswordHash = passwordHash
+	stored.PasswordChangedAt = time.Now().UTC()
+	stored.UpdatedAt = stored.PasswordChangedAt
+	s.users[username] = stored
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	username, err := s.usernameOf(userId)
+	if err != nil {
+		return err
+	}
+	delete(s.users, username)
+	return nil
+}
+
+// usernameOf finds the username of a user ID. The caller holds s.mu.
+func (s *MemoryStore) usernameOf(userId int) (string, error) {
+	for username, user := range s.users {
+		if user.UserId == userId {
+			return username, nil
+		}
+	}
+	return "", dbs.ErrUserNotFound
+}
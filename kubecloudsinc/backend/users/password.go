@@ -0,0 +1,77 @@
+package users
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/policy"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned for an unknown username or a wrong password alike.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// ErrUserDisabled is returned for the right password of a disabled user.
+var ErrUserDisabled = errors.New("user is disabled")
+
+// NoPassword is the password hash of users created by an OpenID Connect sign-in. It is
+// not a bcrypt hash, so no password matches it until an admin sets one.
+const NoPassword = "!"
+
+// hashCost is the bcrypt work factor of new hashes. Existing hashes keep the cost
+// they were created with.
+const hashCost = 12
+
+// ValidatePassword refuses new passwords of username that break
+// policy.CurrentPassword.
+func ValidatePassword(username, password string) error {
+	return policy.CurrentPassword.Check(username, password)
+}
+
+// HashPassword returns the bcrypt hash of password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), hashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHash reports whether hash looks like a bcrypt hash.
+func IsHash(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// Authenticate returns the user when password matches their stored hash. bcrypt
+// compares in constant time, and unknown usernames are checked against a dummy hash
+// so the response time does not reveal whether a username exists. Disabled users fail
+// with ErrUserDisabled, but only once their password is right.
+func Authenticate(ctx context.Context, store Store, username, password string) (*schema.User, error) {
+	user, err := store.GetByUsername(ctx, username)
+	if errors.Is(err, dbs.ErrUserNotFound) {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), hashCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+	return user, nil
+}
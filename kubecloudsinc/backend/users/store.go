@@ -0,0 +1,70 @@
+// Package users stores the logins of the API and verifies their passwords. Passwords
+// are only ever kept as bcrypt hashes.
+package users
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"context"
+	"database/sql"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+// Store keeps users. Lookups of unknown usernames fail with dbs.ErrUserNotFound and
+// duplicate usernames with dbs.ErrUserExists, whatever the implementation.
+type Store interface {
+	// List returns every user ordered by username.
+	List(ctx context.Context) ([]schema.User, error)
+	// Get returns the user with the given ID including its password hash.
+	Get(ctx context.Context, userId int) (*schema.User, error)
+	// GetByUsername returns the user including its password hash.
+	GetByUsername(ctx context.Context, username string) (*schema.User, error)
+	// GetBySubject returns the user linked to an OpenID Connect subject.
+	GetBySubject(ctx context.Context, subject string) (*schema.User, error)
+	// Create stores user, whose PasswordHash must be set, and returns its ID.
+	Create(ctx context.Context, user schema.User) (int, error)
+	// Update replaces the role, employee link and disabled flag of user.UserId.
+	Update(ctx context.Context, user schema.User) error
+	// SetPassword replaces the password hash of a user.
+	SetPassword(ctx context.Context, userId int, passwordHash string) error
+	// Delete removes a user.
+	Delete(ctx context.Context, userId int) error
+}
+
+// SQLStore keeps users in the app_users table created by migrations/006_users.sql.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+func (s *SQLStore) List(ctx context.Context) ([]schema.User, error) {
+	return dbs.QueryUsers(newrelic.FromContext(ctx), s.DB)
+}
+
+func (s *SQLStore) Get(ctx context.Context, userId int) (*schema.User, error) {
+	return dbs.GetUser(newrelic.FromContext(ctx), s.DB, userId)
+}
+
+func (s *SQLStore) GetByUsername(ctx context.Context, username string) (*schema.User, error) {
+	return dbs.GetUserByUsername(newrelic.FromContext(ctx), s.DB, username)
+}
+
+func (s *SQLStore) GetBySubject(ctx context.Context, subject string) (*schema.User, error) {
+	return dbs.GetUserBySubject(newrelic.FromContext(ctx), s.DB, subject)
+}
+
+func (s *SQLStore) Create(ctx context.Context, user schema.User) (int, error) {
+	return dbs.InsertUser(newrelic.FromContext(ctx), s.DB, user)
+}
+
+func (s *SQLStore) Update(ctx context.Context, user schema.User) error {
+	return dbs.UpdateUser(newrelic.FromContext(ctx), s.DB, user)
+}
+
+func (s *SQLStore) SetPassword(ctx context.Context, userId int, passwordHash string) error {
+	return dbs.UpdateUserPassword(newrelic.FromContext(ctx), s.DB, userId, passwordHash)
+}
+
+func (s *SQLStore) Delete(ctx context.Context, userId int) error {
+	return dbs.DeleteUser(newrelic.FromContext(ctx), s.DB, userId)
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 )
@@ -14,11 +16,12 @@ type ErrorResponse struct {
 		Status            int    `json:"status"`
 		Method            string `json:"method"`
 		AdditionalDetails struct {
-			Description   string `json:"description"`
-			StatusCode    int    `json:"statusCode"`
-			Code          string `json:"code"`
-			EsrxRequestID string `json:"esrxRequestId"`
-			ErrorLocation string `json:"errorLocation"`
+			Description   string                  `json:"description"`
+			StatusCode    int                     `json:"statusCode"`
+			Code          string                  `json:"code"`
+			EsrxRequestID string                  `json:"esrxRequestId"`
+			ErrorLocation string                  `json:"errorLocation"`
+			Violations    []schema.FieldViolation `json:"violations,omitempty"`
 		} `json:"AdditionalDetails"`
 	} `json:"metadata"`
 }
@@ -36,6 +39,14 @@ func SendErrorResponse(w http.ResponseWriter, r *http.Request, statusCode int, e
 	resp.Metadata.AdditionalDetails.EsrxRequestID = "someUniqueRequestID" // This should be dynamically generated or passed as an argument
 	resp.Metadata.AdditionalDetails.ErrorLocation = errorLocation
 
+	// Write policy refusals list every field that was refused
+	var violationErr interface {
+		FieldViolations() []schema.FieldViolation
+	}
+	if errors.As(err, &violationErr) {
+		resp.Metadata.AdditionalDetails.Violations = violationErr.FieldViolations()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	if encodeErr := json.NewEncoder(w).Encode(resp); encodeErr != nil {
@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublicAddress is returned for webhook hosts that are, or resolve to, an
+// address inside the service's own network.
+var ErrNonPublicAddress = errors.New("webhook host is not a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which net.IP does not
+// count as private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Public reports whether ip is a unicast address on the public internet. Loopback,
+// private (RFC 1918 and fc00::/7), link-local, which holds cloud metadata services
+// such as 169.254.169.254, multicast and unspecified addresses are not.
+func Public(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// CheckHost resolves host and returns ErrNonPublicAddress if any of its addresses is
+// not public. A host that does not resolve yet is accepted; the dispatcher checks the
+// address again on every connection.
+func CheckHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !Public(ip) {
+			return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !Public(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrNonPublicAddress, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// NewClient returns the HTTP client deliveries are sent with. It refuses to connect to
+// addresses that are not public, checked on the resolved address when dialing so a
+// DNS answer that changes after the subscription was created cannot reach internal
+// services, and it does not follow redirects: a 3xx counts as a failed delivery.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !Public(ip) {
+				return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the dialer check the proxy instead of the webhook host
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700::1111", true},
+		{"169.254.169.254", false},
+		{"10.0.0.5", false},
+		{"192.168.1.1", false},
+		{"127.0.0.1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		if got := Public(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Public(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCheckHostRejectsInternalLiterals(t *testing.T) {
+	for _, host := range []string{"169.254.169.254", "127.0.0.1", "::1"} {
+		if err := CheckHost(context.Background(), host); !errors.Is(err, ErrNonPublicAddress) {
+			t.Errorf("CheckHost(%s) = %v, want ErrNonPublicAddress", host, err)
+		}
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	client := NewClient(0)
+	if _, err := client.Get("http://127.0.0.1:1/"); !errors.Is(err, ErrNonPublicAddress) {
+		t.Fatalf("Get(127.0.0.1) = %v, want ErrNonPublicAddress", err)
+	}
+	if err := client.CheckRedirect(nil, nil); err != http.ErrUseLastResponse {
+		t.Fatalf("CheckRedirect = %v, want http.ErrUseLastResponse", err)
+	}
+}
@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/dbs"
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Signature headers sent with every delivery. Receivers verify the request by
+// computing HMAC-SHA256(secret, timestamp + "." + body) and comparing it with the
+// hex digest in SignatureHeader.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Dispatcher fans change events out to webhook subscribers. It implements
+// events.Publisher so the outbox relay can feed it, and Run delivers the queued
+// requests with exponential backoff. Lease is how long a claimed batch stays with one
+// worker; it must outlast the requests of a whole batch, or another worker sends them
+// again.
+type Dispatcher struct {
+	DB          *sql.DB
+	Client      *http.Client
+	Interval    time.Duration
+	BatchSize   int
+	Lease       time.Duration
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewDispatcher(db *sql.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Client:      NewClient(10 * time.Second),
+		Interval:    5 * time.Second,
+		BatchSize:   50,
+		Lease:       15 * time.Minute,
+		MaxAttempts: 8,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  time.Hour,
+	}
+}
+
+// Publish queues a delivery for every subscription interested in the event.
+func (d *Dispatcher) Publish(ctx context.Context, event schema.ChangeEvent) error {
+	n, err := dbs.EnqueueWebhookDeliveries(d.DB, event)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Queued %d webhook delivery(ies) for event %d", n, event.EventId)
+	}
+	return nil
+}
+
+func (d *Dispatcher) Close() error {
+	return nil
+}
+
+// Run delivers due webhooks until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	log.Printf("Webhook dispatcher started, polling every %s", d.Interval)
+	for {
+		processed, err := dbs.ProcessWebhookDeliveries(d.DB, d.BatchSize, d.Lease, d.deliver, d.nextAttempt)
+		if err != nil {
+			log.Printf("Webhook dispatcher error: %v", err)
+		}
+		if err == nil && processed == d.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Webhook dispatcher stopped")
+			return
+		case <-time.After(d.Interval):
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(delivery schema.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("invalid webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kubecloudsinc-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.DeliveryId))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// nextAttempt returns when a delivery that has failed attempts times should be retried,
+// or nil once the retry budget is exhausted.
+func (d *Dispatcher) nextAttempt(attempts int) *time.Time {
+	if attempts >= d.MaxAttempts {
+		return nil
+	}
+	backoff := d.BaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > d.MaxBackoff {
+		backoff = d.MaxBackoff
+	}
+	next := time.Now().Add(backoff)
+	return &next
+}
+
+// Sign computes the hex-encoded HMAC-SHA256 signature for a webhook body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
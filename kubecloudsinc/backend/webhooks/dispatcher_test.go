@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"autotools-golang-api/kubecloudsinc/backend/schema"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 over "1700000000." followed by the body, keyed with the secret
+	got := Sign("0123456789abcdef", "1700000000", []byte(`{"eventId":42,"type":"employee.updated"}`))
+	if want := "ae993b308ad3109928243b925947158fde6844e6adcff278b01c5856855db51c"; got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestDeliverSignsTheBody(t *testing.T) {
+	payload := `{"eventId":42,"type":"employee.updated"}`
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(nil)
+	// The delivery client refuses loopback addresses
+	d.Client = &http.Client{}
+	status, err := d.deliver(schema.WebhookDelivery{DeliveryId: 7, EventType: "employee.updated", URL: server.URL, Secret: "0123456789abcdef", Payload: payload})
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("deliver = %d, %v, want 204", status, err)
+	}
+	want := "sha256=" + Sign("0123456789abcdef", header.Get(TimestampHeader), []byte(payload))
+	if got := header.Get(SignatureHeader); got != want {
+		t.Fatalf("%s = %s, want %s", SignatureHeader, got, want)
+	}
+	if got := header.Get(DeliveryHeader); got != "7" {
+		t.Fatalf("%s = %s, want 7", DeliveryHeader, got)
+	}
+}
+
+func TestNextAttemptBacksOffUntilDead(t *testing.T) {
+	d := NewDispatcher(nil)
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 16 * time.Minute},
+		{7, 32 * time.Minute},
+	}
+	for _, tt := range tests {
+		before := time.Now()
+		next := d.nextAttempt(tt.attempts)
+		if next == nil {
+			t.Fatalf("nextAttempt(%d) = dead, want a retry after %s", tt.attempts, tt.want)
+		}
+		if got := next.Sub(before); got < tt.want || got > tt.want+time.Second {
+			t.Errorf("nextAttempt(%d) retries after %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+	if next := d.nextAttempt(d.MaxAttempts); next != nil {
+		t.Fatalf("nextAttempt(%d) = %s, want dead", d.MaxAttempts, next)
+	}
+}
+
+func TestNextAttemptIsCapped(t *testing.T) {
+	d := NewDispatcher(nil)
+	d.MaxAttempts = 100
+	for _, attempts := range []int{8, 40, 70} {
+		before := time.Now()
+		next := d.nextAttempt(attempts)
+		if next == nil {
+			t.Fatalf("nextAttempt(%d) = dead, want a retry", attempts)
+		}
+		if got := next.Sub(before); got < d.MaxBackoff || got > d.MaxBackoff+time.Second {
+			t.Errorf("nextAttempt(%d) retries after %s, want %s", attempts, got, d.MaxBackoff)
+		}
+	}
+}
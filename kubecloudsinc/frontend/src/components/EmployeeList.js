@@ -1,10 +1,12 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState, useEffect, useCallback } from 'react';
+
+const API_URL = 'http://192.168.1.31:8080';
 
 function EmployeeList() {
   const [employees, setEmployees] = useState([]);
   const [error, setError] = useState('');
 
-  useEffect(() => {
+  const loadEmployees = useCallback(() => {
     const token = localStorage.getItem('token'); // Get the token from local storage
 
     if (!token) {
@@ -12,7 +14,7 @@ function EmployeeList() {
       return;
     }
 
-    fetch('http://192.168.1.31:8080/v2/employees', {
+    fetch(`${API_URL}/v2/employees`, {
       method: 'GET',
       headers: {
         'Authorization': `Bearer ${token}`, // Use the token for authorization
@@ -35,6 +37,38 @@ function EmployeeList() {
     });
   }, []);
 
+  useEffect(() => {
+    loadEmployees();
+  }, [loadEmployees]);
+
+  // Apply change events from the server instead of reloading the whole list
+  useEffect(() => {
+    const token = localStorage.getItem('token');
+    if (!token) {
+      return;
+    }
+
+    const source = new EventSource(`${API_URL}/v2/events?types=employee.created,employee.updated,employee.deleted&access_token=${encodeURIComponent(token)}`);
+    const upsert = (event) => {
+      const change = JSON.parse(event.data);
+      setEmployees(current => {
+        if (!change.after) {
+          return current.filter(e => e.employeeId !== change.employeeId);
+        }
+        const exists = current.some(e => e.employeeId === change.employeeId);
+        return exists
+          ? current.map(e => (e.employeeId === change.employeeId ? change.after : e))
+          : [...current, change.after];
+      });
+    };
+    source.addEventListener('employee.created', upsert);
+    source.addEventListener('employee.updated', upsert);
+    source.addEventListener('employee.deleted', upsert);
+    source.addEventListener('reset', loadEmployees);
+
+    return () => source.close();
+  }, [loadEmployees]);
+
   return (
     <div>
       <h1>Employee List</h1>